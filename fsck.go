@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"cube-go/internal/services/auditService"
+	"cube-go/internal/services/scrubService"
+	"cube-go/pkg/oss"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// fsck 执行一次完整性巡检并输出 JSON 报告。
+// 返回 0 表示没有问题，1 表示存在未修复的问题，2 表示巡检失败。
+func fsck(args []string) int {
+	flags := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	bucket := flags.String("bucket", "", "只巡检指定存储桶")
+	fix := flags.Bool("fix", false, "修复可修复的问题")
+	thumbnails := flags.Bool("thumbnails", true, "检查缩略图缓存")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// 标准输出只保留 JSON 报告，日志改写到标准错误
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	zap.ReplaceGlobals(zap.New(zapcore.NewCore(zapcore.NewConsoleEncoder(encoderConfig), zapcore.Lock(os.Stderr), zap.InfoLevel)))
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	// 不启动后台任务，避免命令行工具清理回收站、迁移或复制对象
+	if err := oss.Init(ctx, oss.InitOptions{}); err != nil {
+		if errors.Is(err, oss.ErrDatabaseLocked) {
+			_, _ = fmt.Fprintln(os.Stderr, "server is running; use POST /api/admin/scrub")
+		}
+		_, _ = fmt.Fprintln(os.Stderr, "init oss:", err)
+		return 2
+	}
+	defer func() { _ = oss.Close() }()
+	if *fix {
+		// 修复会改写对象，与服务端相同记录审计日志
+		if err := auditService.Init(); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "init audit:", err)
+			return 2
+		}
+		defer func() { _ = auditService.Close() }()
+	}
+
+	report, err := scrubService.Run(ctx, scrubService.Options{Bucket: *bucket, Fix: *fix, Thumbnails: *thumbnails, Actor: "fsck"})
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "fsck:", err)
+		return 2
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(report)
+
+	if len(report.Errors) > 0 {
+		return 2
+	}
+	for _, result := range report.Buckets {
+		for _, issue := range result.Issues {
+			if !issue.Fixed {
+				return 1
+			}
+		}
+	}
+	if report.Thumbnails != nil {
+		for _, issue := range report.Thumbnails.Issues {
+			if !issue.Fixed {
+				return 1
+			}
+		}
+	}
+	return 0
+}
@@ -18,15 +18,31 @@ type Error struct {
 
 // 自定义错误
 var (
-	ServerError         = NewError(200500, log.LevelError, "系统异常，请稍后重试")
-	ParamError          = NewError(200501, log.LevelInfo, "参数错误")
-	UploadFileError     = NewError(200502, log.LevelError, "上传文件失败")
-	FileSizeExceedError = NewError(200503, log.LevelInfo, "文件大小超限")
-	FileNotImageError   = NewError(200504, log.LevelInfo, "上传的文件不是图片")
-	ResourceNotFound    = NewError(200505, log.LevelInfo, "资源不存在")
-	NoPermission        = NewError(200506, log.LevelInfo, "权限不足")
-	FileAlreadyExists   = NewError(200507, log.LevelInfo, "该文件已存在")
-	BucketNotFound      = NewError(200508, log.LevelInfo, "存储桶不存在")
+	ServerError          = NewError(200500, log.LevelError, "系统异常，请稍后重试")
+	ParamError           = NewError(200501, log.LevelInfo, "参数错误")
+	UploadFileError      = NewError(200502, log.LevelError, "上传文件失败")
+	FileSizeExceedError  = NewError(200503, log.LevelInfo, "文件大小超限")
+	FileNotImageError    = NewError(200504, log.LevelInfo, "上传的文件不是图片")
+	ResourceNotFound     = NewError(200505, log.LevelInfo, "资源不存在")
+	NoPermission         = NewError(200506, log.LevelInfo, "权限不足")
+	FileAlreadyExists    = NewError(200507, log.LevelInfo, "该文件已存在")
+	BucketNotFound       = NewError(200508, log.LevelInfo, "存储桶不存在")
+	QuotaExceeded        = NewError(200509, log.LevelInfo, "存储空间配额不足")
+	TooManyRequests      = NewError(200510, log.LevelWarn, "请求过于频繁，请稍后重试")
+	TrashDisabled        = NewError(200511, log.LevelInfo, "该存储桶未启用回收站")
+	VersioningDisabled   = NewError(200512, log.LevelInfo, "该存储桶未启用多版本")
+	PreconditionFailed   = NewError(200513, log.LevelInfo, "文件已被修改，请刷新后重试")
+	MetadataNotSupported = NewError(200514, log.LevelInfo, "该存储桶不支持自定义元数据")
+	ChecksumMismatch     = NewError(200515, log.LevelInfo, "文件校验和不匹配")
+	ScrubRunning         = NewError(200516, log.LevelInfo, "已有巡检正在进行")
+	EncryptionDisabled   = NewError(200517, log.LevelInfo, "该存储桶未启用加密")
+	NotMirrorBucket      = NewError(200518, log.LevelInfo, "该存储桶不是镜像存储桶")
+	NotTieredBucket      = NewError(200519, log.LevelInfo, "该存储桶不是分层存储桶")
+	IndexDisabled        = NewError(200520, log.LevelInfo, "该存储桶未启用对象索引")
+	IndexRebuilding      = NewError(200521, log.LevelInfo, "对象索引正在重建")
+	ArchiveTooLarge      = NewError(200522, log.LevelInfo, "打包文件总大小超限")
+	ExtractLimitExceeded = NewError(200523, log.LevelInfo, "压缩包条目数或解压后大小超限")
+	RotationRunning      = NewError(200524, log.LevelInfo, "已有密钥轮换正在进行")
 
 	NotFound = NewError(200404, log.LevelWarn, http.StatusText(http.StatusNotFound))
 )
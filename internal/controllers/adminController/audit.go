@@ -0,0 +1,60 @@
+package adminController
+
+import (
+	"time"
+
+	"cube-go/internal/apiException"
+	"cube-go/internal/midwares"
+	"cube-go/internal/services/auditService"
+	"cube-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type getAuditLogData struct {
+	Start  time.Time `form:"start" time_format:"2006-01-02T15:04:05Z07:00"`
+	End    time.Time `form:"end" time_format:"2006-01-02T15:04:05Z07:00"`
+	Bucket string    `form:"bucket"`
+	Actor  string    `form:"actor"`
+	Limit  int       `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+// GetAuditLog 查询审计日志
+func GetAuditLog(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	var data getAuditLogData
+	if err := c.ShouldBindQuery(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	if data.Limit == 0 {
+		data.Limit = 100
+	}
+
+	entries, err := auditService.Search(auditService.Query{
+		Start:  data.Start,
+		End:    data.End,
+		Bucket: data.Bucket,
+		Actor:  data.Actor,
+		Limit:  data.Limit,
+	})
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+
+	response.JsonSuccessResp(c, gin.H{"entries": entries})
+}
+
+// recordAudit 记录管理操作的审计日志
+func recordAudit(c *gin.Context, entry auditService.Entry, err error) {
+	entry.Actor = c.GetString(midwares.ActorKey)
+	entry.IP = c.ClientIP()
+	entry.Result = auditService.ResultSuccess
+	if err != nil {
+		entry.Result = auditService.ResultFailure
+		entry.Error = err.Error()
+	}
+	auditService.Record(entry)
+}
@@ -0,0 +1,49 @@
+package adminController
+
+import (
+	"cube-go/internal/apiException"
+	"cube-go/pkg/oss"
+	"cube-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type getDedupData struct {
+	Bucket string `form:"bucket"`
+}
+
+// GetDedupReport 获取存储桶去重节省的空间
+func GetDedupReport(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	var data getDedupData
+	if err := c.ShouldBindQuery(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+
+	names := oss.Buckets.GetManagedBucketList()
+	if data.Bucket != "" {
+		names = []string{data.Bucket}
+	}
+	reports := make(map[string]*oss.DedupReport, len(names))
+	for _, name := range names {
+		bucket, err := oss.Buckets.GetManagedBucket(name)
+		if err != nil {
+			apiException.AbortWithException(c, apiException.BucketNotFound, err)
+			return
+		}
+		dedup, ok := oss.As[oss.DedupProvider](bucket)
+		if !ok || !dedup.DedupEnabled() {
+			continue
+		}
+		report, err := dedup.DedupReport(c.Request.Context())
+		if err != nil {
+			apiException.AbortWithException(c, apiException.ServerError, err)
+			return
+		}
+		reports[name] = report
+	}
+
+	response.JsonSuccessResp(c, gin.H{"dedup": reports})
+}
@@ -0,0 +1,96 @@
+package adminController
+
+import (
+	"errors"
+	"fmt"
+
+	"cube-go/internal/apiException"
+	"cube-go/internal/midwares"
+	"cube-go/internal/services/auditService"
+	"cube-go/pkg/oss"
+	"cube-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type rotateKeyData struct {
+	Bucket string `form:"bucket" binding:"required"`
+}
+
+// encryptedBucket 获取启用加密的存储桶，出错时已写入响应
+func encryptedBucket(c *gin.Context, name string) (*oss.EncryptedProvider, bool) {
+	bucket, err := oss.Buckets.GetManagedBucket(name)
+	if err != nil {
+		apiException.AbortWithException(c, apiException.BucketNotFound, err)
+		return nil, false
+	}
+	encrypted, ok := oss.As[*oss.EncryptedProvider](bucket)
+	if !ok {
+		apiException.AbortWithException(c, apiException.EncryptionDisabled, oss.ErrEncryptionDisabled)
+		return nil, false
+	}
+	return encrypted, true
+}
+
+// RotateEncryptionKey 重新加载密钥文件，并在后台以新的主密钥重新封装存储桶中的对象，完成后记录审计日志
+func RotateEncryptionKey(c *gin.Context) {
+	var data rotateKeyData
+	if err := c.ShouldBind(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	encrypted, ok := encryptedBucket(c, data.Bucket)
+	if !ok {
+		return
+	}
+
+	entry := auditService.Entry{
+		Actor:     c.GetString(midwares.ActorKey),
+		IP:        c.ClientIP(),
+		Operation: auditService.OperationRotateKey,
+		Bucket:    data.Bucket,
+	}
+	err := encrypted.StartRotate(func(report *oss.RotateReport, err error) {
+		entry.Result = auditService.ResultSuccess
+		entry.Detail = fmt.Sprintf("primary %s, rewrapped %d, current %d, failed %d",
+			report.Primary, report.Rewrapped, report.Current, len(report.Failed))
+		if err != nil {
+			entry.Result, entry.Error = auditService.ResultFailure, err.Error()
+		}
+		auditService.Record(entry)
+	})
+	if err != nil {
+		recordAudit(c, entry, err)
+	}
+	if errors.Is(err, oss.ErrRotationRunning) {
+		apiException.AbortWithException(c, apiException.RotationRunning, err)
+		return
+	}
+	if errors.Is(err, oss.ErrInvalidKeyFile) {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+
+	response.JsonSuccessResp(c, encrypted.RotationStatus())
+}
+
+// GetRotationStatus 获取最近一次密钥轮换的进度或结果
+func GetRotationStatus(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	var data rotateKeyData
+	if err := c.ShouldBindQuery(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	encrypted, ok := encryptedBucket(c, data.Bucket)
+	if !ok {
+		return
+	}
+
+	response.JsonSuccessResp(c, gin.H{"rotation": encrypted.RotationStatus()})
+}
@@ -0,0 +1,93 @@
+package adminController
+
+import (
+	"errors"
+
+	"cube-go/internal/apiException"
+	"cube-go/internal/services/auditService"
+	"cube-go/pkg/oss"
+	"cube-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type getIndexData struct {
+	Bucket string `form:"bucket"`
+}
+
+type rebuildIndexData struct {
+	Bucket string `form:"bucket" binding:"required"`
+}
+
+// GetIndexStatus 获取各存储桶的对象索引状态
+func GetIndexStatus(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	var data getIndexData
+	if err := c.ShouldBindQuery(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+
+	names := oss.Buckets.GetManagedBucketList()
+	if data.Bucket != "" {
+		names = []string{data.Bucket}
+	}
+	statuses := make(map[string]oss.IndexStatus, len(names))
+	for _, name := range names {
+		bucket, err := oss.Buckets.GetManagedBucket(name)
+		if err != nil {
+			apiException.AbortWithException(c, apiException.BucketNotFound, err)
+			return
+		}
+		indexed, ok := oss.As[*oss.IndexedProvider](bucket)
+		if !ok {
+			continue
+		}
+		status, err := indexed.Status()
+		if err != nil {
+			apiException.AbortWithException(c, apiException.ServerError, err)
+			return
+		}
+		statuses[name] = status
+	}
+
+	response.JsonSuccessResp(c, gin.H{"index": statuses})
+}
+
+// RebuildIndex 全量遍历存储桶重建对象索引
+func RebuildIndex(c *gin.Context) {
+	var data rebuildIndexData
+	if err := c.ShouldBind(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	bucket, err := oss.Buckets.GetManagedBucket(data.Bucket)
+	if err != nil {
+		apiException.AbortWithException(c, apiException.BucketNotFound, err)
+		return
+	}
+	indexed, ok := oss.As[*oss.IndexedProvider](bucket)
+	if !ok {
+		apiException.AbortWithException(c, apiException.IndexDisabled, oss.ErrIndexDisabled)
+		return
+	}
+
+	err = indexed.Rebuild(c.Request.Context())
+	recordAudit(c, auditService.Entry{Operation: auditService.OperationRebuildIndex, Bucket: data.Bucket}, err)
+	if errors.Is(err, oss.ErrIndexRebuilding) {
+		apiException.AbortWithException(c, apiException.IndexRebuilding, err)
+		return
+	}
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+	status, err := indexed.Status()
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+
+	response.JsonSuccessResp(c, status)
+}
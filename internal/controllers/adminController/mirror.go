@@ -0,0 +1,77 @@
+package adminController
+
+import (
+	"cube-go/internal/apiException"
+	"cube-go/internal/midwares"
+	"cube-go/internal/services/auditService"
+	"cube-go/pkg/oss"
+	"cube-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type getMirrorData struct {
+	Bucket string `form:"bucket"`
+}
+
+type reconcileMirrorData struct {
+	Bucket string `form:"bucket" binding:"required"`
+}
+
+// GetMirrorStatus 获取镜像存储桶的副本与复制队列状态
+func GetMirrorStatus(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	var data getMirrorData
+	if err := c.ShouldBindQuery(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+
+	names := oss.Buckets.GetBucketList()
+	if data.Bucket != "" {
+		names = []string{data.Bucket}
+	}
+	statuses := make(map[string]oss.MirrorStatus, len(names))
+	for _, name := range names {
+		bucket, err := oss.Buckets.GetBucket(name)
+		if err != nil {
+			apiException.AbortWithException(c, apiException.BucketNotFound, err)
+			return
+		}
+		if mirror, ok := oss.As[*oss.MirrorProvider](bucket); ok {
+			statuses[name] = mirror.Status()
+		}
+	}
+
+	response.JsonSuccessResp(c, gin.H{"mirror": statuses})
+}
+
+// ReconcileMirror 以主副本为准校准镜像存储桶的各副本
+func ReconcileMirror(c *gin.Context) {
+	var data reconcileMirrorData
+	if err := c.ShouldBind(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	bucket, err := oss.Buckets.GetBucket(data.Bucket)
+	if err != nil {
+		apiException.AbortWithException(c, apiException.BucketNotFound, err)
+		return
+	}
+	mirror, ok := oss.As[*oss.MirrorProvider](bucket)
+	if !ok {
+		apiException.AbortWithException(c, apiException.NotMirrorBucket, oss.ErrNotMirror)
+		return
+	}
+
+	report, err := mirror.Reconcile(c.Request.Context())
+	recordAudit(c, auditService.Entry{Operation: auditService.OperationReconcile, Bucket: data.Bucket}, err)
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+	auditService.RecordReconcile(c.GetString(midwares.ActorKey), c.ClientIP(), data.Bucket, report)
+
+	response.JsonSuccessResp(c, report)
+}
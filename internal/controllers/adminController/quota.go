@@ -0,0 +1,42 @@
+package adminController
+
+import (
+	"cube-go/internal/apiException"
+	"cube-go/pkg/oss"
+	"cube-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type getQuotaData struct {
+	Bucket string `form:"bucket"`
+}
+
+// GetQuotaUsage 获取存储桶配额用量
+func GetQuotaUsage(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	var data getQuotaData
+	if err := c.ShouldBindQuery(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+
+	names := oss.Buckets.GetManagedBucketList()
+	if data.Bucket != "" {
+		names = []string{data.Bucket}
+	}
+	reports := make(map[string]oss.QuotaReport, len(names))
+	for _, name := range names {
+		bucket, err := oss.Buckets.GetManagedBucket(name)
+		if err != nil {
+			apiException.AbortWithException(c, apiException.BucketNotFound, err)
+			return
+		}
+		if quota, ok := oss.As[*oss.QuotaProvider](bucket); ok {
+			reports[name] = quota.Report()
+		}
+	}
+
+	response.JsonSuccessResp(c, gin.H{"quota": reports})
+}
@@ -0,0 +1,86 @@
+package adminController
+
+import (
+	"errors"
+
+	"cube-go/internal/apiException"
+	"cube-go/internal/midwares"
+	"cube-go/internal/services/auditService"
+	"cube-go/internal/services/scrubService"
+	"cube-go/pkg/oss"
+	"cube-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type startScrubData struct {
+	Bucket     string `form:"bucket"`
+	Fix        bool   `form:"fix"`
+	Thumbnails *bool  `form:"thumbnails"`
+}
+
+// StartScrub 在后台开始一次完整性巡检
+func StartScrub(c *gin.Context) {
+	var data startScrubData
+	if err := c.ShouldBind(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	if data.Bucket != "" {
+		if _, err := oss.Buckets.GetManagedBucket(data.Bucket); err != nil {
+			apiException.AbortWithException(c, apiException.BucketNotFound, err)
+			return
+		}
+	}
+
+	id, err := scrubService.Start(scrubService.Options{
+		Bucket:     data.Bucket,
+		Fix:        data.Fix,
+		Thumbnails: data.Thumbnails == nil || *data.Thumbnails,
+		Actor:      c.GetString(midwares.ActorKey),
+	})
+	if data.Fix {
+		// 只读巡检不改变数据，不记录审计日志
+		recordAudit(c, auditService.Entry{Operation: auditService.OperationScrub, Bucket: data.Bucket}, err)
+	}
+	if errors.Is(err, scrubService.ErrRunning) {
+		apiException.AbortWithException(c, apiException.ScrubRunning, err)
+		return
+	}
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+
+	response.JsonSuccessResp(c, gin.H{"id": id})
+}
+
+// GetScrubReports 获取巡检报告列表
+func GetScrubReports(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	reports, err := scrubService.List()
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+
+	response.JsonSuccessResp(c, gin.H{"running": scrubService.Running(), "reports": reports})
+}
+
+// GetScrubReport 获取巡检报告
+func GetScrubReport(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	report, err := scrubService.Get(c.Param("id"))
+	if errors.Is(err, scrubService.ErrReportNotFound) {
+		apiException.AbortWithException(c, apiException.ResourceNotFound, err)
+		return
+	}
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+
+	response.JsonSuccessResp(c, report)
+}
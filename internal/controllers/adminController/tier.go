@@ -0,0 +1,126 @@
+package adminController
+
+import (
+	"errors"
+
+	"cube-go/internal/apiException"
+	"cube-go/internal/services/auditService"
+	"cube-go/internal/services/objectService"
+	"cube-go/pkg/oss"
+	"cube-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type getTiersData struct {
+	Bucket   string `form:"bucket" binding:"required"`
+	Location string `form:"location"`
+}
+
+type pinObjectData struct {
+	Bucket    string `form:"bucket" binding:"required"`
+	ObjectKey string `form:"object_key" binding:"required"`
+	Pinned    *bool  `form:"pinned"`
+}
+
+type migrateTiersData struct {
+	Bucket string `form:"bucket" binding:"required"`
+}
+
+// GetTiers 列出分层存储桶中对象所在的层
+func GetTiers(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	var data getTiersData
+	if err := c.ShouldBindQuery(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	tiered, ok := tieredBucket(c, data.Bucket)
+	if !ok {
+		return
+	}
+
+	list, err := tiered.List(c.Request.Context(), objectService.CleanLocation(data.Location))
+	if errors.Is(err, oss.ErrInvalidObjectKey) {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+
+	response.JsonSuccessResp(c, gin.H{"objects": list})
+}
+
+// PinObject 将对象固定在热层或取消固定，固定位于冷层的对象会立即迁回热层
+func PinObject(c *gin.Context) {
+	var data pinObjectData
+	if err := c.ShouldBind(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	tiered, ok := tieredBucket(c, data.Bucket)
+	if !ok {
+		return
+	}
+
+	pinned := data.Pinned == nil || *data.Pinned
+	entry, err := tiered.Pin(c.Request.Context(), data.ObjectKey, pinned)
+	operation := auditService.OperationPin
+	if !pinned {
+		operation = auditService.OperationUnpin
+	}
+	recordAudit(c, auditService.Entry{Operation: operation, Bucket: data.Bucket, ObjectKey: data.ObjectKey}, err)
+	if errors.Is(err, oss.ErrInvalidObjectKey) {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	if errors.Is(err, oss.ErrResourceNotExists) {
+		apiException.AbortWithException(c, apiException.ResourceNotFound, err)
+		return
+	}
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+
+	response.JsonSuccessResp(c, entry)
+}
+
+// MigrateTiers 立即执行一次分层迁移
+func MigrateTiers(c *gin.Context) {
+	var data migrateTiersData
+	if err := c.ShouldBind(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	tiered, ok := tieredBucket(c, data.Bucket)
+	if !ok {
+		return
+	}
+
+	report, err := tiered.Migrate(c.Request.Context())
+	recordAudit(c, auditService.Entry{Operation: auditService.OperationMigrateTiers, Bucket: data.Bucket}, err)
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+
+	response.JsonSuccessResp(c, report)
+}
+
+func tieredBucket(c *gin.Context, name string) (*oss.TieredProvider, bool) {
+	bucket, err := oss.Buckets.GetBucket(name)
+	if err != nil {
+		apiException.AbortWithException(c, apiException.BucketNotFound, err)
+		return nil, false
+	}
+	tiered, ok := oss.As[*oss.TieredProvider](bucket)
+	if !ok {
+		apiException.AbortWithException(c, apiException.NotTieredBucket, oss.ErrNotTiered)
+		return nil, false
+	}
+	return tiered, true
+}
@@ -0,0 +1,115 @@
+package objectController
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"cube-go/internal/apiException"
+	"cube-go/internal/services/objectService"
+	"cube-go/pkg/oss"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type downloadArchiveData struct {
+	Bucket   string   `form:"bucket" binding:"required"`
+	Location string   `form:"location"`
+	Format   string   `form:"format" binding:"omitempty,oneof=zip tar.gz"`
+	Include  []string `form:"include"`
+	Exclude  []string `form:"exclude"`
+}
+
+// DownloadArchive 将目录打包为 ZIP 或 tar.gz 边读边写返回
+func DownloadArchive(c *gin.Context) {
+	var data downloadArchiveData
+	if err := c.ShouldBindQuery(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	if data.Format == "" {
+		data.Format = objectService.ArchiveZip
+	}
+
+	bucket, err := oss.Buckets.GetBucket(data.Bucket)
+	if err != nil {
+		apiException.AbortWithException(c, apiException.BucketNotFound, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	entries, err := objectService.CollectArchive(ctx, bucket, data.Bucket, objectService.ArchiveOptions{
+		Prefix:  objectService.CleanLocation(data.Location),
+		Include: data.Include,
+		Exclude: data.Exclude,
+		MaxSize: objectService.ArchiveSizeLimit,
+	})
+	if errors.Is(err, objectService.ErrInvalidPattern) || errors.Is(err, oss.ErrInvalidObjectKey) ||
+		errors.Is(err, oss.ErrPathIsNotDir) {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	if errors.Is(err, objectService.ErrArchiveTooLarge) {
+		apiException.AbortWithException(c, apiException.ArchiveTooLarge, err)
+		return
+	}
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+	if len(entries) == 0 {
+		apiException.AbortWithException(c, apiException.ResourceNotFound, nil)
+		return
+	}
+
+	// 文件名与归档内的顶层目录同名
+	root, _, _ := strings.Cut(entries[0].Name, "/")
+	name := root + "." + data.Format
+	contentType := "application/zip"
+	if data.Format == objectService.ArchiveTarGz {
+		contentType = "application/gzip"
+	}
+	c.Header("Cache-Control", noStoreCacheControl)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	c.Status(http.StatusOK)
+
+	writer := &archiveResponseWriter{Writer: c.Writer}
+	err = objectService.WriteArchive(ctx, writer, bucket, data.Format, entries, objectService.ArchiveSizeLimit)
+	if err == nil {
+		return
+	}
+	fields := []zap.Field{zap.String("bucket", data.Bucket), zap.String("location", data.Location), zap.Error(err)}
+	switch {
+	case writer.err != nil || errors.Is(err, context.Canceled):
+		zap.L().Info("客户端断开，打包下载已中止", fields...)
+	case errors.Is(err, objectService.ErrArchiveTooLarge):
+		// 对象在收集后变大，属于请求超出限制而不是服务端故障
+		zap.L().Warn("打包期间对象总大小超出限制，打包下载已中止", fields...)
+	default:
+		zap.L().Error("打包下载失败", fields...)
+	}
+	// 响应头已发送，直接断开连接，避免客户端把不完整的归档当作完整文件
+	if conn, _, err := http.NewResponseController(c.Writer).Hijack(); err == nil {
+		_ = conn.Close()
+	}
+	c.Abort()
+}
+
+// archiveResponseWriter 记录写入响应失败，用于区分客户端断开与读取对象失败
+type archiveResponseWriter struct {
+	io.Writer
+	err error
+}
+
+func (w *archiveResponseWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
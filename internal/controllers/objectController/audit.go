@@ -0,0 +1,20 @@
+package objectController
+
+import (
+	"cube-go/internal/midwares"
+	"cube-go/internal/services/auditService"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordAudit 记录变更操作的审计日志
+func recordAudit(c *gin.Context, entry auditService.Entry, err error) {
+	entry.Actor = c.GetString(midwares.ActorKey)
+	entry.IP = c.ClientIP()
+	entry.Result = auditService.ResultSuccess
+	if err != nil {
+		entry.Result = auditService.ResultFailure
+		entry.Error = err.Error()
+	}
+	auditService.Record(entry)
+}
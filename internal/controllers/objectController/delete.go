@@ -2,6 +2,7 @@ package objectController
 
 import (
 	"cube-go/internal/apiException"
+	"cube-go/internal/services/auditService"
 	"cube-go/pkg/oss"
 	"cube-go/pkg/response"
 
@@ -37,7 +38,19 @@ func DeleteFile(c *gin.Context) {
 		target += "/"
 	}
 
+	var size int64
+	if !isDir {
+		if info, err := bucket.StatObject(c.Request.Context(), target, oss.GetObjectOptions{}); err == nil {
+			size = info.ContentLength
+		}
+	}
 	err = bucket.DeleteObject(c.Request.Context(), target)
+	recordAudit(c, auditService.Entry{
+		Operation: auditService.OperationDelete,
+		Bucket:    data.Bucket,
+		ObjectKey: target,
+		Size:      size,
+	}, err)
 	if err == oss.ErrInvalidObjectKey {
 		apiException.AbortWithException(c, apiException.ParamError, err)
 		return
@@ -0,0 +1,113 @@
+package objectController
+
+import (
+	"errors"
+	"mime/multipart"
+
+	"cube-go/internal/apiException"
+	"cube-go/internal/midwares"
+	"cube-go/internal/services/auditService"
+	"cube-go/internal/services/objectService"
+	"cube-go/pkg/oss"
+	"cube-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// extractEntryResult 单个条目的解压结果，code 与 msg 与普通上传失败时相同
+type extractEntryResult struct {
+	Name      string `json:"name"`
+	ObjectKey string `json:"object_key,omitempty"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256,omitempty"`
+	Converted bool   `json:"converted"`
+	Code      int    `json:"code"`
+	Msg       string `json:"msg"`
+}
+
+// extractArchive 将上传的压缩包解压到目标目录，返回每个条目的结果
+func extractArchive(c *gin.Context, data *uploadFileData, bucket oss.StorageProvider, file multipart.File, userMeta oss.UserMetadata) {
+	results, err := objectService.ExtractArchive(c.Request.Context(), bucket, file, data.File.Size, objectService.ExtractOptions{
+		Location:    data.Location,
+		ConvertWebP: data.ConvertWebP,
+		Overwrite:   data.Overwrite,
+		Uploader:    c.GetString(midwares.ActorKey),
+		Metadata:    userMeta.Metadata,
+		Tags:        userMeta.Tags,
+		MaxEntries:  objectService.ExtractMaxEntries,
+		MaxSize:     objectService.ExtractMaxSize,
+		EntryLimit:  objectService.SizeLimit,
+	})
+	if errors.Is(err, objectService.ErrInvalidArchive) || errors.Is(err, oss.ErrInvalidObjectKey) {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	if errors.Is(err, objectService.ErrTooManyEntries) || errors.Is(err, objectService.ErrExtractTooLarge) {
+		apiException.AbortWithException(c, apiException.ExtractLimitExceeded, err)
+		return
+	}
+
+	list := make([]extractEntryResult, 0, len(results))
+	var saved int
+	for _, result := range results {
+		apiErr := extractEntryError(result.Err)
+		list = append(list, extractEntryResult{
+			Name:      result.Name,
+			ObjectKey: result.ObjectKey,
+			Size:      result.Size,
+			SHA256:    result.SHA256,
+			Converted: result.Converted,
+			Code:      apiErr.Code,
+			Msg:       apiErr.Msg,
+		})
+		if result.Err == nil {
+			saved++
+		}
+		// 在保存前失败的条目（如路径非法、超出大小）同样记录，对象键可能为空，由 Detail 标明条目
+		recordAudit(c, auditService.Entry{
+			Operation: auditService.OperationUpload,
+			Bucket:    data.Bucket,
+			ObjectKey: result.ObjectKey,
+			Size:      result.Size,
+			SHA256:    result.SHA256,
+			Detail:    "extracted from " + data.File.Filename + ": " + result.Name,
+		}, result.Err)
+	}
+	if err != nil {
+		// 已保存的条目保留，结果不完整
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+
+	zap.L().Info("解压上传完成", zap.String("bucket", data.Bucket), zap.String("archive", data.File.Filename),
+		zap.Int("saved", saved), zap.Int("failed", len(results)-saved), zap.String("ip", c.ClientIP()))
+	response.JsonSuccessResp(c, gin.H{
+		"results": list,
+		"saved":   saved,
+		"failed":  len(results) - saved,
+	})
+}
+
+// extractEntryError 将条目的错误映射为与普通上传一致的错误码
+func extractEntryError(err error) *apiException.Error {
+	switch {
+	case err == nil:
+		return &apiException.Error{Code: 200, Msg: "OK"}
+	case errors.Is(err, oss.ErrInvalidObjectKey), errors.Is(err, objectService.ErrUnsupportedEntry),
+		errors.Is(err, objectService.ErrInvalidArchive):
+		return apiException.ParamError
+	case errors.Is(err, objectService.ErrEntryTooLarge):
+		return apiException.FileSizeExceedError
+	case errors.Is(err, oss.ErrFileAlreadyExists):
+		return apiException.FileAlreadyExists
+	case errors.Is(err, oss.ErrChecksumMismatch):
+		return apiException.ChecksumMismatch
+	case errors.Is(err, oss.ErrQuotaExceeded):
+		return apiException.QuotaExceeded
+	case errors.Is(err, oss.ErrMetadataNotSupported):
+		return apiException.MetadataNotSupported
+	}
+	zap.L().Error("解压条目保存失败", zap.Error(err))
+	return apiException.UploadFileError
+}
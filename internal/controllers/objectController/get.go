@@ -4,6 +4,7 @@ import (
 	"errors"
 	"image"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"path"
@@ -28,6 +29,7 @@ type getFileData struct {
 	Bucket    string `form:"bucket" binding:"required"`
 	ObjectKey string `form:"object_key" binding:"required"`
 	Thumbnail bool   `form:"thumbnail"`
+	Version   string `form:"version"`
 }
 
 const (
@@ -92,8 +94,11 @@ func GetFile(c *gin.Context) {
 	if data.Thumbnail {
 		prefix = "/thumbnails/"
 	}
-	target := (&url.URL{Path: prefix + data.Bucket + "/" + objectKey}).String()
-	c.Redirect(http.StatusPermanentRedirect, target)
+	target := &url.URL{Path: prefix + data.Bucket + "/" + objectKey}
+	if data.Version != "" && !data.Thumbnail {
+		target.RawQuery = url.Values{"version": {data.Version}}.Encode()
+	}
+	c.Redirect(http.StatusPermanentRedirect, target.String())
 }
 
 func ServeFile(c *gin.Context) {
@@ -126,8 +131,9 @@ func serveObject(c *gin.Context, thumbnail bool) {
 		serveSeekable(c, objectKey+".jpg", reader, info)
 		return
 	}
-	if _, local := bucket.(*oss.LocalStorageProvider); local {
-		reader, info, err := bucket.GetObject(c.Request.Context(), objectKey, oss.GetObjectOptions{})
+	versionID := c.Query("version")
+	if oss.IsSeekable(bucket) {
+		reader, info, err := bucket.GetObject(c.Request.Context(), objectKey, oss.GetObjectOptions{VersionID: versionID})
 		if err != nil {
 			handleObjectError(c, err)
 			return
@@ -135,7 +141,7 @@ func serveObject(c *gin.Context, thumbnail bool) {
 		serveSeekable(c, objectKey, reader, info)
 		return
 	}
-	serveRemoteObject(c, bucket, objectKey)
+	serveRemoteObject(c, bucket, objectKey, versionID)
 }
 
 func serveSeekable(c *gin.Context, name string, reader io.ReadCloser, info *oss.GetObjectInfo) {
@@ -149,17 +155,17 @@ func serveSeekable(c *gin.Context, name string, reader io.ReadCloser, info *oss.
 	http.ServeContent(&cacheResponseWriter{ResponseWriter: c.Writer}, c.Request, path.Base(name), info.LastModified.UTC(), seeker)
 }
 
-func serveRemoteObject(c *gin.Context, bucket oss.StorageProvider, objectKey string) {
-	options := oss.GetObjectOptions{Conditions: requestConditions(c.Request)}
+func serveRemoteObject(c *gin.Context, bucket oss.StorageProvider, objectKey, versionID string) {
+	options := oss.GetObjectOptions{Conditions: requestConditions(c.Request), VersionID: versionID}
 	options.Range = c.GetHeader("Range")
 	if strings.Contains(options.Range, ",") {
-		handleRemoteObjectError(c, bucket, objectKey, oss.ErrInvalidRange)
+		handleRemoteObjectError(c, bucket, objectKey, versionID, oss.ErrInvalidRange)
 		return
 	}
 	if options.Range != "" && c.GetHeader("If-Range") != "" {
-		info, err := bucket.StatObject(c.Request.Context(), objectKey, oss.GetObjectOptions{Conditions: options.Conditions})
+		info, err := bucket.StatObject(c.Request.Context(), objectKey, oss.GetObjectOptions{Conditions: options.Conditions, VersionID: versionID})
 		if err != nil {
-			handleRemoteObjectError(c, bucket, objectKey, err)
+			handleRemoteObjectError(c, bucket, objectKey, versionID, err)
 			return
 		}
 		if !ifRangeMatches(c.GetHeader("If-Range"), info) {
@@ -182,7 +188,7 @@ func serveRemoteObject(c *gin.Context, bucket oss.StorageProvider, objectKey str
 			}
 		}
 		if err != nil {
-			handleRemoteObjectError(c, bucket, objectKey, err)
+			handleRemoteObjectError(c, bucket, objectKey, versionID, err)
 			return
 		}
 		partial := info.ContentRange != ""
@@ -198,7 +204,7 @@ func serveRemoteObject(c *gin.Context, bucket oss.StorageProvider, objectKey str
 
 	reader, info, err := bucket.GetObject(c.Request.Context(), objectKey, options)
 	if err != nil {
-		handleRemoteObjectError(c, bucket, objectKey, err)
+		handleRemoteObjectError(c, bucket, objectKey, versionID, err)
 		return
 	}
 	defer func() { _ = reader.Close() }()
@@ -213,7 +219,7 @@ func serveRemoteObject(c *gin.Context, bucket oss.StorageProvider, objectKey str
 	_, _ = io.Copy(c.Writer, reader)
 }
 
-func handleRemoteObjectError(c *gin.Context, bucket oss.StorageProvider, objectKey string, err error) {
+func handleRemoteObjectError(c *gin.Context, bucket oss.StorageProvider, objectKey, versionID string, err error) {
 	if !errors.Is(err, oss.ErrNotModified) && !errors.Is(err, oss.ErrInvalidRange) {
 		handleObjectError(c, err)
 		return
@@ -229,7 +235,7 @@ func handleRemoteObjectError(c *gin.Context, bucket oss.StorageProvider, objectK
 		needStat = true
 	}
 	if needStat {
-		statInfo, statErr := bucket.StatObject(c.Request.Context(), objectKey, oss.GetObjectOptions{})
+		statInfo, statErr := bucket.StatObject(c.Request.Context(), objectKey, oss.GetObjectOptions{VersionID: versionID})
 		if statErr == nil {
 			if info == nil {
 				info = statInfo
@@ -304,6 +310,19 @@ func setObjectHeaders(c *gin.Context, info *oss.GetObjectInfo, includeLength boo
 	if !info.LastModified.IsZero() {
 		c.Header("Last-Modified", info.LastModified.UTC().Truncate(time.Second).Format(http.TimeFormat))
 	}
+	if info.SHA256 != "" {
+		c.Header("X-Checksum-SHA256", info.SHA256)
+		c.Header("Repr-Digest", objectService.ReprDigest(info.SHA256))
+	}
+	exposed := make([]string, 0, len(info.Metadata))
+	for key, value := range info.Metadata {
+		c.Header("X-Meta-"+key, mime.QEncoding.Encode("utf-8", value))
+		exposed = append(exposed, "X-Meta-"+key)
+	}
+	// 自定义元数据的头部名称随对象变化，无法在 CORS 配置中列出，跨域请求时逐个暴露
+	if len(exposed) > 0 && c.GetHeader("Origin") != "" {
+		c.Writer.Header().Add("Access-Control-Expose-Headers", strings.Join(exposed, ", "))
+	}
 	if includeLength && info.ContentLength >= 0 {
 		c.Header("Content-Length", strconv.FormatInt(info.ContentLength, 10))
 	}
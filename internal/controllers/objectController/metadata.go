@@ -0,0 +1,101 @@
+package objectController
+
+import (
+	"errors"
+
+	"cube-go/internal/apiException"
+	"cube-go/internal/services/auditService"
+	"cube-go/pkg/oss"
+	"cube-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type getMetadataData struct {
+	Bucket    string `form:"bucket" binding:"required"`
+	ObjectKey string `form:"object_key" binding:"required"`
+}
+
+type updateMetadataData struct {
+	Bucket    string            `json:"bucket" binding:"required"`
+	ObjectKey string            `json:"object_key" binding:"required"`
+	Metadata  map[string]string `json:"metadata"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// GetMetadata 获取对象的自定义元数据与标签
+func GetMetadata(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	var data getMetadataData
+	if err := c.ShouldBindQuery(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	provider, ok := getMetadataBucket(c, data.Bucket)
+	if !ok {
+		return
+	}
+
+	meta, err := provider.GetMetadata(c.Request.Context(), data.ObjectKey)
+	if err != nil {
+		handleMetadataError(c, err)
+		return
+	}
+
+	response.JsonSuccessResp(c, meta)
+}
+
+// UpdateMetadata 更新对象的自定义元数据与标签，未提供的字段保持不变
+func UpdateMetadata(c *gin.Context) {
+	var data updateMetadataData
+	if err := c.ShouldBindJSON(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	update, err := oss.NormalizeUserMetadata(oss.UserMetadata{Metadata: data.Metadata, Tags: data.Tags})
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	provider, ok := getMetadataBucket(c, data.Bucket)
+	if !ok {
+		return
+	}
+
+	err = provider.UpdateMetadata(c.Request.Context(), data.ObjectKey, update)
+	recordAudit(c, auditService.Entry{Operation: auditService.OperationUpdateMetadata, Bucket: data.Bucket, ObjectKey: data.ObjectKey}, err)
+	if err != nil {
+		handleMetadataError(c, err)
+		return
+	}
+
+	response.JsonSuccessResp(c, nil)
+}
+
+func getMetadataBucket(c *gin.Context, name string) (oss.MetadataProvider, bool) {
+	bucket, err := oss.Buckets.GetBucket(name)
+	if err != nil {
+		apiException.AbortWithException(c, apiException.BucketNotFound, err)
+		return nil, false
+	}
+	provider, ok := oss.As[oss.MetadataProvider](bucket)
+	if !ok {
+		apiException.AbortWithException(c, apiException.MetadataNotSupported, oss.ErrMetadataNotSupported)
+		return nil, false
+	}
+	return provider, true
+}
+
+func handleMetadataError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, oss.ErrInvalidObjectKey), errors.Is(err, oss.ErrInvalidMetadata):
+		apiException.AbortWithException(c, apiException.ParamError, err)
+	case errors.Is(err, oss.ErrResourceNotExists):
+		apiException.AbortWithException(c, apiException.ResourceNotFound, err)
+	case errors.Is(err, oss.ErrPreconditionFailed):
+		apiException.AbortWithException(c, apiException.PreconditionFailed, err)
+	default:
+		apiException.AbortWithException(c, apiException.ServerError, err)
+	}
+}
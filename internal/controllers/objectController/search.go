@@ -0,0 +1,72 @@
+package objectController
+
+import (
+	"errors"
+	"time"
+
+	"cube-go/internal/apiException"
+	"cube-go/internal/services/objectService"
+	"cube-go/pkg/oss"
+	"cube-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type searchData struct {
+	Bucket         string    `form:"bucket"`
+	Location       string    `form:"location"`
+	Name           string    `form:"name"`
+	Type           string    `form:"type"`
+	MinSize        *int64    `form:"min_size" binding:"omitempty,min=0"`
+	MaxSize        *int64    `form:"max_size" binding:"omitempty,min=0"`
+	ModifiedAfter  time.Time `form:"modified_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	ModifiedBefore time.Time `form:"modified_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cursor         string    `form:"cursor"`
+	Limit          int       `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+// Search 按名称、类型、大小与修改时间搜索对象，结果分页返回
+func Search(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	var data searchData
+	if err := c.ShouldBindQuery(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	if data.Limit == 0 {
+		data.Limit = 100
+	}
+	if data.Bucket != "" {
+		if _, err := oss.Buckets.GetBucket(data.Bucket); err != nil {
+			apiException.AbortWithException(c, apiException.BucketNotFound, err)
+			return
+		}
+	}
+
+	result, err := objectService.Search(c.Request.Context(), objectService.SearchOptions{
+		Bucket: data.Bucket,
+		Query: oss.SearchQuery{
+			Prefix:         objectService.CleanLocation(data.Location),
+			Name:           data.Name,
+			Type:           data.Type,
+			MinSize:        data.MinSize,
+			MaxSize:        data.MaxSize,
+			ModifiedAfter:  data.ModifiedAfter,
+			ModifiedBefore: data.ModifiedBefore,
+		},
+		Cursor: data.Cursor,
+		Limit:  data.Limit,
+	})
+	if errors.Is(err, oss.ErrInvalidSearch) || errors.Is(err, objectService.ErrInvalidCursor) ||
+		errors.Is(err, oss.ErrInvalidObjectKey) || errors.Is(err, oss.ErrPathIsNotDir) {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ServerError, err)
+		return
+	}
+
+	response.JsonSuccessResp(c, result)
+}
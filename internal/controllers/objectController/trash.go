@@ -0,0 +1,134 @@
+package objectController
+
+import (
+	"errors"
+
+	"cube-go/internal/apiException"
+	"cube-go/internal/services/auditService"
+	"cube-go/pkg/oss"
+	"cube-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type getTrashListData struct {
+	Bucket string `form:"bucket" binding:"required"`
+}
+
+type trashItemData struct {
+	Bucket string `form:"bucket" binding:"required"`
+	ID     string `form:"id"`
+}
+
+// GetTrashList 获取回收站列表
+func GetTrashList(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	var data getTrashListData
+	if err := c.ShouldBindQuery(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	trash, ok := getTrashBucket(c, data.Bucket)
+	if !ok {
+		return
+	}
+
+	items, err := trash.ListTrash(c.Request.Context())
+	if err != nil {
+		handleTrashError(c, err)
+		return
+	}
+
+	response.JsonSuccessResp(c, gin.H{"trash_list": items})
+}
+
+// RestoreTrash 还原回收站条目
+func RestoreTrash(c *gin.Context) {
+	var data trashItemData
+	if err := c.ShouldBind(&data); err != nil || data.ID == "" {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	trash, ok := getTrashBucket(c, data.Bucket)
+	if !ok {
+		return
+	}
+
+	item, err := trash.RestoreTrash(c.Request.Context(), data.ID)
+	entry := auditService.Entry{Operation: auditService.OperationRestore, Bucket: data.Bucket, ObjectKey: data.ID}
+	if item != nil {
+		entry.ObjectKey = item.ObjectKey
+		entry.Size = item.Size
+	}
+	recordAudit(c, entry, err)
+	if err != nil {
+		handleTrashError(c, err)
+		return
+	}
+
+	response.JsonSuccessResp(c, gin.H{"object_key": item.ObjectKey})
+}
+
+// PurgeTrash 永久删除回收站条目，未指定 ID 时清空回收站
+func PurgeTrash(c *gin.Context) {
+	var data trashItemData
+	if err := c.ShouldBind(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	trash, ok := getTrashBucket(c, data.Bucket)
+	if !ok {
+		return
+	}
+
+	ids := []string{data.ID}
+	if data.ID == "" {
+		items, err := trash.ListTrash(c.Request.Context())
+		if err != nil {
+			handleTrashError(c, err)
+			return
+		}
+		ids = ids[:0]
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+	}
+	for _, id := range ids {
+		err := trash.PurgeTrash(c.Request.Context(), id)
+		recordAudit(c, auditService.Entry{Operation: auditService.OperationPurge, Bucket: data.Bucket, ObjectKey: id}, err)
+		if err != nil {
+			handleTrashError(c, err)
+			return
+		}
+	}
+
+	response.JsonSuccessResp(c, nil)
+}
+
+func getTrashBucket(c *gin.Context, name string) (oss.TrashProvider, bool) {
+	bucket, err := oss.Buckets.GetBucket(name)
+	if err != nil {
+		apiException.AbortWithException(c, apiException.BucketNotFound, err)
+		return nil, false
+	}
+	trash, ok := oss.As[oss.TrashProvider](bucket)
+	if !ok || !trash.TrashEnabled() {
+		apiException.AbortWithException(c, apiException.TrashDisabled, oss.ErrTrashDisabled)
+		return nil, false
+	}
+	return trash, true
+}
+
+func handleTrashError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, oss.ErrTrashDisabled):
+		apiException.AbortWithException(c, apiException.TrashDisabled, err)
+	case errors.Is(err, oss.ErrTrashItemNotFound):
+		apiException.AbortWithException(c, apiException.ResourceNotFound, err)
+	case errors.Is(err, oss.ErrFileAlreadyExists):
+		apiException.AbortWithException(c, apiException.FileAlreadyExists, err)
+	default:
+		apiException.AbortWithException(c, apiException.ServerError, err)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"path/filepath"
 
 	"cube-go/internal/apiException"
+	"cube-go/internal/midwares"
+	"cube-go/internal/services/auditService"
 	"cube-go/internal/services/objectService"
 	"cube-go/pkg/oss"
 	"cube-go/pkg/response"
@@ -24,11 +26,14 @@ type uploadFileData struct {
 	Location    string                `form:"location"`
 	ConvertWebP bool                  `form:"convert_webp"`
 	UseUUID     bool                  `form:"use_uuid"`
+	Overwrite   bool                  `form:"overwrite"`
+	Extract     bool                  `form:"extract"`
 }
 
 // UploadFile 上传文件
 func UploadFile(c *gin.Context) {
-	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, objectService.SizeLimit)
+	// 解压上传的压缩包可以大于普通文件，表单解析后再按是否解压检查
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max(objectService.SizeLimit, objectService.ExtractArchiveLimit))
 
 	var data uploadFileData
 	if err := c.ShouldBind(&data); err != nil {
@@ -40,6 +45,28 @@ func UploadFile(c *gin.Context) {
 		apiException.AbortWithException(c, apiException.ParamError, err)
 		return
 	}
+	if data.Extract && data.UseUUID {
+		// 解压时对象名取自压缩包内的路径，无法使用 UUID
+		apiException.AbortWithException(c, apiException.ParamError, nil)
+		return
+	}
+	limit := objectService.SizeLimit
+	if data.Extract && objectService.ExtractArchiveLimit > 0 {
+		limit = objectService.ExtractArchiveLimit
+	}
+	if data.File.Size > limit {
+		apiException.AbortWithException(c, apiException.FileSizeExceedError, nil)
+		return
+	}
+
+	userMeta, err := oss.NormalizeUserMetadata(oss.UserMetadata{
+		Metadata: c.PostFormMap("metadata"),
+		Tags:     c.PostFormMap("tags"),
+	})
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
 
 	bucket, err := oss.Buckets.GetBucket(data.Bucket)
 	if err != nil {
@@ -56,6 +83,12 @@ func UploadFile(c *gin.Context) {
 		name = uuid.NewV1().String()
 	}
 
+	expected, err := objectService.RequestChecksum(c.Request.Header)
+	if err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+
 	file, err := data.File.Open()
 	if err != nil {
 		apiException.AbortWithException(c, apiException.UploadFileError, err)
@@ -63,6 +96,24 @@ func UploadFile(c *gin.Context) {
 	}
 	defer func() { _ = file.Close() }()
 
+	// 校验客户端提供的校验和，转换格式前针对原始文件
+	if expected != "" {
+		_, actual, err := objectService.HashReader(file)
+		if err != nil {
+			apiException.AbortWithException(c, apiException.UploadFileError, err)
+			return
+		}
+		if actual != expected {
+			apiException.AbortWithException(c, apiException.ChecksumMismatch, oss.ErrChecksumMismatch)
+			return
+		}
+	}
+
+	if data.Extract {
+		extractArchive(c, &data, bucket, file, userMeta)
+		return
+	}
+
 	// 转换到 WebP
 	var reader io.ReadSeeker = file
 	if data.ConvertWebP {
@@ -78,9 +129,31 @@ func UploadFile(c *gin.Context) {
 		}
 	}
 
+	size, checksum, err := objectService.HashReader(reader)
+	if err != nil {
+		apiException.AbortWithException(c, apiException.UploadFileError, err)
+		return
+	}
+
 	// 上传文件
 	objectKey := objectService.GenerateObjectKey(data.Location, name, ext)
-	err = bucket.SaveObject(c.Request.Context(), reader, objectKey)
+	err = bucket.SaveObject(c.Request.Context(), reader, objectKey, oss.SaveObjectOptions{
+		Overwrite: data.Overwrite,
+		IfMatch:   c.GetHeader("If-Match"),
+
+		OriginalName: data.File.Filename,
+		Uploader:     c.GetString(midwares.ActorKey),
+		Metadata:     userMeta.Metadata,
+		Tags:         userMeta.Tags,
+		SHA256:       checksum,
+	})
+	recordAudit(c, auditService.Entry{
+		Operation: auditService.OperationUpload,
+		Bucket:    data.Bucket,
+		ObjectKey: objectKey,
+		Size:      size,
+		SHA256:    checksum,
+	}, err)
 	if errors.Is(err, oss.ErrInvalidObjectKey) {
 		apiException.AbortWithException(c, apiException.ParamError, err)
 		return
@@ -89,6 +162,22 @@ func UploadFile(c *gin.Context) {
 		apiException.AbortWithException(c, apiException.FileAlreadyExists, err)
 		return
 	}
+	if errors.Is(err, oss.ErrPreconditionFailed) {
+		apiException.AbortWithException(c, apiException.PreconditionFailed, err)
+		return
+	}
+	if errors.Is(err, oss.ErrChecksumMismatch) {
+		apiException.AbortWithException(c, apiException.ChecksumMismatch, err)
+		return
+	}
+	if errors.Is(err, oss.ErrQuotaExceeded) {
+		apiException.AbortWithException(c, apiException.QuotaExceeded, err)
+		return
+	}
+	if errors.Is(err, oss.ErrMetadataNotSupported) {
+		apiException.AbortWithException(c, apiException.MetadataNotSupported, err)
+		return
+	}
 	if err != nil {
 		apiException.AbortWithException(c, apiException.ServerError, err)
 		return
@@ -97,5 +186,6 @@ func UploadFile(c *gin.Context) {
 	zap.L().Info("上传文件成功", zap.String("bucket", data.Bucket), zap.String("objectKey", objectKey), zap.String("ip", c.ClientIP()))
 	response.JsonSuccessResp(c, gin.H{
 		"object_key": objectKey,
+		"sha256":     checksum,
 	})
 }
@@ -0,0 +1,127 @@
+package objectController
+
+import (
+	"errors"
+
+	"cube-go/internal/apiException"
+	"cube-go/internal/services/auditService"
+	"cube-go/pkg/oss"
+	"cube-go/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type getVersionListData struct {
+	Bucket    string `form:"bucket" binding:"required"`
+	ObjectKey string `form:"object_key" binding:"required"`
+}
+
+type versionData struct {
+	Bucket    string `form:"bucket" binding:"required"`
+	ObjectKey string `form:"object_key" binding:"required"`
+	VersionID string `form:"version_id" binding:"required"`
+}
+
+// GetVersionList 获取对象的版本列表
+func GetVersionList(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	var data getVersionListData
+	if err := c.ShouldBindQuery(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	versions, ok := getVersionBucket(c, data.Bucket)
+	if !ok {
+		return
+	}
+
+	list, err := versions.ListVersions(c.Request.Context(), data.ObjectKey)
+	if err != nil {
+		handleVersionError(c, err)
+		return
+	}
+
+	response.JsonSuccessResp(c, gin.H{"version_list": list})
+}
+
+// RestoreVersion 将历史版本恢复为当前版本
+func RestoreVersion(c *gin.Context) {
+	var data versionData
+	if err := c.ShouldBind(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	versions, ok := getVersionBucket(c, data.Bucket)
+	if !ok {
+		return
+	}
+
+	err := versions.RestoreVersion(c.Request.Context(), data.ObjectKey, data.VersionID)
+	recordAudit(c, auditService.Entry{
+		Operation: auditService.OperationRestoreVersion,
+		Bucket:    data.Bucket,
+		ObjectKey: data.ObjectKey + "?version=" + data.VersionID,
+	}, err)
+	if err != nil {
+		handleVersionError(c, err)
+		return
+	}
+
+	response.JsonSuccessResp(c, nil)
+}
+
+// DeleteVersion 删除历史版本
+func DeleteVersion(c *gin.Context) {
+	var data versionData
+	if err := c.ShouldBind(&data); err != nil {
+		apiException.AbortWithException(c, apiException.ParamError, err)
+		return
+	}
+	versions, ok := getVersionBucket(c, data.Bucket)
+	if !ok {
+		return
+	}
+
+	err := versions.DeleteVersion(c.Request.Context(), data.ObjectKey, data.VersionID)
+	recordAudit(c, auditService.Entry{
+		Operation: auditService.OperationDeleteVersion,
+		Bucket:    data.Bucket,
+		ObjectKey: data.ObjectKey + "?version=" + data.VersionID,
+	}, err)
+	if err != nil {
+		handleVersionError(c, err)
+		return
+	}
+
+	response.JsonSuccessResp(c, nil)
+}
+
+func getVersionBucket(c *gin.Context, name string) (oss.VersionProvider, bool) {
+	bucket, err := oss.Buckets.GetBucket(name)
+	if err != nil {
+		apiException.AbortWithException(c, apiException.BucketNotFound, err)
+		return nil, false
+	}
+	versions, ok := oss.As[oss.VersionProvider](bucket)
+	if !ok || !versions.VersioningEnabled() {
+		apiException.AbortWithException(c, apiException.VersioningDisabled, oss.ErrVersioningDisabled)
+		return nil, false
+	}
+	return versions, true
+}
+
+func handleVersionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, oss.ErrVersioningDisabled):
+		apiException.AbortWithException(c, apiException.VersioningDisabled, err)
+	case errors.Is(err, oss.ErrVersionNotFound):
+		apiException.AbortWithException(c, apiException.ResourceNotFound, err)
+	case errors.Is(err, oss.ErrInvalidObjectKey):
+		apiException.AbortWithException(c, apiException.ParamError, err)
+	case errors.Is(err, oss.ErrFileAlreadyExists):
+		apiException.AbortWithException(c, apiException.FileAlreadyExists, err)
+	default:
+		apiException.AbortWithException(c, apiException.ServerError, err)
+	}
+}
@@ -47,6 +47,9 @@ func HandleNotFound(c *gin.Context) {
 	response.JsonResp(c, http.StatusNotFound, err.Code, err.Msg, nil)
 }
 
+// ActorKey 上下文中保存调用者标识的键
+const ActorKey = "actor"
+
 // Auth 验证权限
 func Auth(c *gin.Context) {
 	key := c.GetHeader("Key")
@@ -54,5 +57,6 @@ func Auth(c *gin.Context) {
 		apiException.AbortWithException(c, apiException.NoPermission, nil)
 		return
 	}
+	c.Set(ActorKey, KeyID(key))
 	c.Next()
 }
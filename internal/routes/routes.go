@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"cube-go/internal/controllers/adminController"
 	"cube-go/internal/controllers/objectController"
 	"cube-go/internal/midwares"
 
@@ -12,15 +13,46 @@ func Init(r *gin.Engine) {
 	api := r.Group("/api")
 	{
 		api.GET("/buckets", midwares.Auth, objectController.GetBucketList)
-		api.POST("/upload", midwares.Auth, objectController.UploadFile)
+		api.POST("/upload", midwares.Auth, midwares.RateLimit(midwares.RateLimitUpload), objectController.UploadFile)
 		api.GET("/files", midwares.Auth, objectController.GetFileList)
+		api.GET("/search", midwares.Auth, objectController.Search)
+		api.GET("/archive", midwares.Auth, midwares.RateLimit(midwares.RateLimitArchive), objectController.DownloadArchive)
 		api.DELETE("/delete", midwares.Auth, objectController.DeleteFile)
+		api.GET("/trash", midwares.Auth, objectController.GetTrashList)
+		api.POST("/trash/restore", midwares.Auth, objectController.RestoreTrash)
+		api.DELETE("/trash", midwares.Auth, objectController.PurgeTrash)
+		api.GET("/versions", midwares.Auth, objectController.GetVersionList)
+		api.POST("/versions/restore", midwares.Auth, objectController.RestoreVersion)
+		api.DELETE("/versions", midwares.Auth, objectController.DeleteVersion)
+		api.GET("/metadata", midwares.Auth, objectController.GetMetadata)
+		api.PUT("/metadata", midwares.Auth, objectController.UpdateMetadata)
 
 		api.GET("/file", objectController.GetFile)
 		api.HEAD("/file", objectController.GetFile)
 	}
-	r.GET("/files/:bucket/*object_key", objectController.ServeFile)
-	r.HEAD("/files/:bucket/*object_key", objectController.ServeFile)
-	r.GET("/thumbnails/:bucket/*object_key", objectController.ServeThumbnail)
-	r.HEAD("/thumbnails/:bucket/*object_key", objectController.ServeThumbnail)
+	admin := api.Group("/admin", midwares.Auth)
+	{
+		admin.GET("/quota", adminController.GetQuotaUsage)
+		admin.GET("/audit", adminController.GetAuditLog)
+		admin.GET("/dedup", adminController.GetDedupReport)
+		admin.POST("/scrub", adminController.StartScrub)
+		admin.GET("/scrub", adminController.GetScrubReports)
+		admin.GET("/scrub/:id", adminController.GetScrubReport)
+		admin.POST("/encryption/rotate", adminController.RotateEncryptionKey)
+		admin.GET("/encryption/rotate", adminController.GetRotationStatus)
+		admin.GET("/mirror", adminController.GetMirrorStatus)
+		admin.POST("/mirror/reconcile", adminController.ReconcileMirror)
+		admin.GET("/tier", adminController.GetTiers)
+		admin.PUT("/tier/pin", adminController.PinObject)
+		admin.POST("/tier/migrate", adminController.MigrateTiers)
+		admin.GET("/index", adminController.GetIndexStatus)
+		admin.POST("/index/rebuild", adminController.RebuildIndex)
+	}
+	downloadLimit := midwares.RateLimit(midwares.RateLimitDownload)
+	thumbnailLimit := midwares.RateLimit(midwares.RateLimitThumbnail)
+	hotlink := midwares.Hotlink()
+	r.GET("/files/:bucket/*object_key", hotlink, downloadLimit, objectController.ServeFile)
+	r.HEAD("/files/:bucket/*object_key", hotlink, downloadLimit, objectController.ServeFile)
+	r.GET("/thumbnails/:bucket/*object_key", hotlink, thumbnailLimit, objectController.ServeThumbnail)
+	r.HEAD("/thumbnails/:bucket/*object_key", hotlink, thumbnailLimit, objectController.ServeThumbnail)
 }
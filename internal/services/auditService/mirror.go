@@ -0,0 +1,21 @@
+package auditService
+
+import "cube-go/pkg/oss"
+
+// RecordReconcile 为镜像校准从副本删除的每个多余对象记录一条审计日志
+func RecordReconcile(actor, ip, bucket string, report *oss.MirrorReport) {
+	for _, extra := range report.Extra {
+		if !extra.Deleted {
+			continue
+		}
+		Record(Entry{
+			Actor:     actor,
+			IP:        ip,
+			Operation: OperationReconcile,
+			Bucket:    extra.Replica,
+			ObjectKey: extra.ObjectKey,
+			Result:    ResultSuccess,
+			Detail:    "not in the primary replica of mirror " + bucket,
+		})
+	}
+}
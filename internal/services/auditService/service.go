@@ -0,0 +1,288 @@
+package auditService
+
+import (
+	"bufio"
+	"compress/gzip"
+	"container/heap"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cube-go/pkg/config"
+
+	"go.uber.org/zap"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// 审计操作类型
+const (
+	OperationUpload         = "upload"
+	OperationDelete         = "delete"
+	OperationRestore        = "restore"
+	OperationPurge          = "purge"
+	OperationRestoreVersion = "restore_version"
+	OperationDeleteVersion  = "delete_version"
+	OperationUpdateMetadata = "update_metadata"
+
+	// 管理操作，ObjectKey 为空时作用于整个存储桶
+	OperationScrub        = "scrub"
+	OperationRotateKey    = "rotate_key"
+	OperationReconcile    = "reconcile"
+	OperationPin          = "pin"
+	OperationUnpin        = "unpin"
+	OperationMigrateTiers = "migrate_tiers"
+	OperationRebuildIndex = "rebuild_index"
+)
+
+// 审计结果
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+const logFileName = "audit.log"
+
+// Entry 审计日志条目
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Actor     string    `json:"actor"`
+	IP        string    `json:"ip"`
+	Operation string    `json:"operation"`
+	Bucket    string    `json:"bucket"`
+	ObjectKey string    `json:"object_key"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256,omitempty"`
+	Result    string    `json:"result"`
+	Error     string    `json:"error,omitempty"`
+	Detail    string    `json:"detail,omitempty"` // 后台任务修改对象的原因，或解压上传的来源条目
+}
+
+// Query 审计日志查询条件
+type Query struct {
+	Start  time.Time
+	End    time.Time
+	Bucket string
+	Actor  string
+	Limit  int
+}
+
+var (
+	mu     sync.Mutex
+	writer *lumberjack.Logger
+)
+
+// Init 初始化审计日志
+func Init() error {
+	dir := logDir()
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	writer = &lumberjack.Logger{
+		Filename:  filepath.Join(dir, logFileName),
+		MaxSize:   config.Config.GetInt("audit.maxSize"),
+		MaxAge:    config.Config.GetInt("audit.maxAge"),
+		LocalTime: true,
+		Compress:  config.Config.GetBool("audit.compress"),
+	}
+	return nil
+}
+
+// Close 关闭审计日志
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if writer == nil {
+		return nil
+	}
+	err := writer.Close()
+	writer = nil
+	return err
+}
+
+// Record 追加一条审计日志
+func Record(entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		zap.L().Error("序列化审计日志失败", zap.Error(err))
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if writer == nil {
+		zap.L().Warn("审计日志未初始化", zap.ByteString("entry", data))
+		return
+	}
+	if _, err := writer.Write(append(data, '\n')); err != nil {
+		zap.L().Error("写入审计日志失败", zap.Error(err), zap.ByteString("entry", data))
+	}
+}
+
+// Search 按条件查询审计日志，结果按时间倒序排列。
+// 跳过时间范围之外的日志文件，设置 Limit 时扫描过程中只保留最新的 Limit 条
+func Search(query Query) ([]Entry, error) {
+	files, err := logFiles()
+	if err != nil {
+		return nil, err
+	}
+	matched := &newestEntries{limit: query.Limit}
+	// 从最新的文件开始扫描，已保留足够条目后跳过更早的文件
+	for i := len(files) - 1; i >= 0; i-- {
+		file := files[i]
+		if !query.Start.IsZero() && file.end.Add(rotationSlack).Before(query.Start) || matched.newerThan(file.end.Add(rotationSlack)) {
+			continue
+		}
+		if !query.End.IsZero() && i > 0 && !files[i-1].end.Add(-rotationSlack).Before(query.End) {
+			continue
+		}
+		err := searchFile(file.name, func(entry Entry) {
+			if query.matches(entry) {
+				matched.add(entry)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	entries := matched.entries
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Time.After(entries[j].Time)
+	})
+	return entries, nil
+}
+
+// rotationSlack 条目时间在写入前确定，可能略早于上一个文件的轮转时间
+const rotationSlack = time.Minute
+
+// backupTimeFormat lumberjack 备份文件名中的轮转时间格式
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// logFile 日志文件及其中条目时间的上界
+type logFile struct {
+	name string
+	end  time.Time
+}
+
+// logFiles 返回按时间先后排列的日志文件。备份文件以文件名中的轮转时间为上界，
+// 无法解析时与当前文件一样使用修改时间；每个文件的条目晚于前一个文件的上界
+func logFiles() ([]logFile, error) {
+	names, err := filepath.Glob(filepath.Join(logDir(), "audit*.log*"))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]logFile, 0, len(names))
+	for _, name := range names {
+		base := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(name), ".gz"), ".log")
+		end, err := time.ParseInLocation(backupTimeFormat, strings.TrimPrefix(base, "audit-"), time.Local)
+		if err != nil {
+			info, err := os.Stat(name)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			end = info.ModTime()
+		}
+		files = append(files, logFile{name: name, end: end})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].end.Before(files[j].end)
+	})
+	return files, nil
+}
+
+// newestEntries 保留时间最新的 limit 条日志，limit 为 0 时不限制
+type newestEntries struct {
+	limit   int
+	entries []Entry
+}
+
+func (h *newestEntries) Len() int           { return len(h.entries) }
+func (h *newestEntries) Less(i, j int) bool { return h.entries[i].Time.Before(h.entries[j].Time) }
+func (h *newestEntries) Swap(i, j int)      { h.entries[i], h.entries[j] = h.entries[j], h.entries[i] }
+func (h *newestEntries) Push(x any)         { h.entries = append(h.entries, x.(Entry)) }
+func (h *newestEntries) Pop() any {
+	last := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	return last
+}
+
+// newerThan 判断已保留满 limit 条，且都晚于 t
+func (h *newestEntries) newerThan(t time.Time) bool {
+	return h.limit > 0 && len(h.entries) == h.limit && h.entries[0].Time.After(t)
+}
+
+func (h *newestEntries) add(entry Entry) {
+	switch {
+	case h.limit <= 0:
+		h.entries = append(h.entries, entry)
+	case len(h.entries) < h.limit:
+		heap.Push(h, entry)
+	case entry.Time.After(h.entries[0].Time):
+		h.entries[0] = entry
+		heap.Fix(h, 0)
+	}
+}
+
+func searchFile(name string, fn func(Entry)) error {
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = gz.Close() }()
+		reader = gz
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		fn(entry)
+	}
+	return scanner.Err()
+}
+
+func (q Query) matches(entry Entry) bool {
+	if !q.Start.IsZero() && entry.Time.Before(q.Start) {
+		return false
+	}
+	if !q.End.IsZero() && !entry.Time.Before(q.End) {
+		return false
+	}
+	if q.Bucket != "" && entry.Bucket != q.Bucket {
+		return false
+	}
+	return q.Actor == "" || entry.Actor == q.Actor
+}
+
+func logDir() string {
+	dir := config.Config.GetString("audit.dir")
+	if dir == "" {
+		dir = "./audit_logs"
+	}
+	return dir
+}
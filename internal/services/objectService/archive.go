@@ -0,0 +1,238 @@
+package objectService
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"cube-go/pkg/config"
+	"cube-go/pkg/oss"
+
+	"github.com/dustin/go-humanize"
+	"go.uber.org/zap"
+)
+
+// ArchiveSizeLimit 打包下载的对象总大小限制，0 表示不限制
+var ArchiveSizeLimit = humanize.MiByte * config.Config.GetInt64("oss.archiveLimit")
+
+// 打包格式
+const (
+	ArchiveZip   = "zip"
+	ArchiveTarGz = "tar.gz"
+)
+
+var (
+	// ErrInvalidPattern 包含或排除模式不合法
+	ErrInvalidPattern = errors.New("invalid pattern")
+	// ErrArchiveTooLarge 打包的对象总大小超出限制
+	ErrArchiveTooLarge = errors.New("archive too large")
+
+	// errUnknownSize 后端未返回对象大小（如 WebDAV），tar 头部需要预先写入大小
+	errUnknownSize = errors.New("object size unknown")
+)
+
+// ArchiveOptions 打包选项
+type ArchiveOptions struct {
+	Prefix  string   // 打包的目录，为空时打包整个存储桶
+	Include []string // 只打包匹配任一模式的对象，为空时包含全部
+	Exclude []string // 排除匹配任一模式的对象
+	MaxSize int64    // 对象总大小上限，0 表示不限制
+}
+
+// ArchiveEntry 归档中的一个对象
+type ArchiveEntry struct {
+	ObjectKey string
+	Name      string // 归档内的路径
+	Size      int64
+}
+
+// CollectArchive 遍历目录收集需要打包的对象，按归档内路径排序。
+// 模式不含 / 时匹配文件名，否则匹配相对于目录的路径，均不区分大小写。
+func CollectArchive(ctx context.Context, provider oss.StorageProvider, root string, options ArchiveOptions) ([]ArchiveEntry, error) {
+	for _, pattern := range slices.Concat(options.Include, options.Exclude) {
+		if _, err := path.Match(strings.ToLower(pattern), ""); err != nil || pattern == "" {
+			return nil, ErrInvalidPattern
+		}
+	}
+	prefix, _, err := oss.NormalizeObjectKey(options.Prefix, true)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" {
+		root = path.Base(prefix)
+	}
+
+	var entries []ArchiveEntry
+	var total int64
+	err = oss.WalkObjects(ctx, provider, prefix, func(entry oss.ObjectEntry) error {
+		rel := entry.Key
+		if prefix != "" {
+			var found bool
+			if rel, found = strings.CutPrefix(entry.Key, prefix+"/"); !found {
+				// 前缀本身是对象而不是目录
+				return oss.ErrPathIsNotDir
+			}
+		}
+		if len(options.Include) > 0 && !matchAny(options.Include, rel) || matchAny(options.Exclude, rel) {
+			return nil
+		}
+		total += entry.Size
+		if options.MaxSize > 0 && total > options.MaxSize {
+			return ErrArchiveTooLarge
+		}
+		entries = append(entries, ArchiveEntry{ObjectKey: entry.Key, Name: root + "/" + rel, Size: entry.Size})
+		return nil
+	})
+	if err != nil && !errors.Is(err, oss.ErrResourceNotExists) {
+		return nil, err
+	}
+	slices.SortFunc(entries, func(a, b ArchiveEntry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return entries, nil
+}
+
+func matchAny(patterns []string, rel string) bool {
+	rel = strings.ToLower(rel)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteArchive 依次读取对象并写入归档，不在本地缓存归档内容。
+// 收集后被删除的对象，以及 tar 格式下大小未知的对象会被跳过；上下文取消（如客户端断开）或读取失败时立即返回错误，此时归档不完整。
+// 对象在收集后变大导致总大小超出 maxSize 时返回 ErrArchiveTooLarge。
+func WriteArchive(ctx context.Context, w io.Writer, provider oss.StorageProvider, format string, entries []ArchiveEntry, maxSize int64) error {
+	var archive archiveWriter
+	switch format {
+	case ArchiveZip:
+		archive = &zipArchive{writer: zip.NewWriter(w)}
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		archive = &tarArchive{gzip: gz, writer: tar.NewWriter(gz)}
+	default:
+		return fmt.Errorf("unknown archive format %q", format)
+	}
+
+	var written int64
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		reader, info, err := provider.GetObject(ctx, entry.ObjectKey, oss.GetObjectOptions{})
+		if errors.Is(err, oss.ErrResourceNotExists) {
+			zap.L().Info("打包时对象已不存在，已跳过", zap.String("objectKey", entry.ObjectKey))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		size := info.ContentLength
+		if size < 0 {
+			size = entry.Size
+		}
+		if maxSize > 0 && written+size > maxSize {
+			_ = reader.Close()
+			return ErrArchiveTooLarge
+		}
+		dst, err := archive.create(entry.Name, info)
+		if errors.Is(err, errUnknownSize) {
+			_ = reader.Close()
+			zap.L().Warn("对象大小未知，无法写入 tar 归档，已跳过", zap.String("objectKey", entry.ObjectKey))
+			continue
+		}
+		if err == nil {
+			var source io.Reader = reader
+			if maxSize > 0 {
+				// 大小未知的对象按实际读取的字节数计入限制
+				source = io.LimitReader(reader, maxSize-written+1)
+			}
+			var n int64
+			n, err = io.Copy(dst, source)
+			written += n
+			if err == nil && maxSize > 0 && written > maxSize {
+				err = ErrArchiveTooLarge
+			}
+		}
+		_ = reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+type archiveWriter interface {
+	create(name string, info *oss.GetObjectInfo) (io.Writer, error)
+	Close() error
+}
+
+type zipArchive struct {
+	writer *zip.Writer
+}
+
+// create 文本类内容压缩存储，图片等已压缩的内容直接存储以节省 CPU
+func (a *zipArchive) create(name string, info *oss.GetObjectInfo) (io.Writer, error) {
+	method := zip.Store
+	if strings.HasPrefix(info.ContentType, "text/") || strings.Contains(info.ContentType, "json") || strings.Contains(info.ContentType, "xml") {
+		method = zip.Deflate
+	}
+	return a.writer.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   method,
+		Modified: archiveModTime(info),
+	})
+}
+
+func (a *zipArchive) Close() error {
+	return a.writer.Close()
+}
+
+type tarArchive struct {
+	gzip   *gzip.Writer
+	writer *tar.Writer
+}
+
+func (a *tarArchive) create(name string, info *oss.GetObjectInfo) (io.Writer, error) {
+	if info.ContentLength < 0 {
+		return nil, errUnknownSize
+	}
+	err := a.writer.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     info.ContentLength,
+		ModTime:  archiveModTime(info),
+	})
+	return a.writer, err
+}
+
+func (a *tarArchive) Close() error {
+	if err := a.writer.Close(); err != nil {
+		return err
+	}
+	return a.gzip.Close()
+}
+
+func archiveModTime(info *oss.GetObjectInfo) time.Time {
+	if info.LastModified.IsZero() {
+		return time.Now()
+	}
+	return info.LastModified
+}
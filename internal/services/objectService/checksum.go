@@ -0,0 +1,42 @@
+package objectService
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalidChecksum 客户端提供的校验和格式不合法
+var ErrInvalidChecksum = errors.New("invalid checksum")
+
+// RequestChecksum 读取客户端在 X-Checksum-SHA256 中提供的 SHA-256 校验和（十六进制或 Base64），返回十六进制摘要，未提供时返回空字符串
+func RequestChecksum(header http.Header) (string, error) {
+	value := strings.TrimSpace(header.Get("X-Checksum-SHA256"))
+	if value == "" {
+		return "", nil
+	}
+	if digest, err := hex.DecodeString(value); err == nil && len(digest) == sha256.Size {
+		return hex.EncodeToString(digest), nil
+	}
+	return decodeBase64Digest(value)
+}
+
+func decodeBase64Digest(value string) (string, error) {
+	digest, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(digest) != sha256.Size {
+		return "", ErrInvalidChecksum
+	}
+	return hex.EncodeToString(digest), nil
+}
+
+// ReprDigest 生成 RFC 9530 Repr-Digest 头的值
+func ReprDigest(checksum string) string {
+	digest, err := hex.DecodeString(checksum)
+	if err != nil {
+		return ""
+	}
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(digest) + ":"
+}
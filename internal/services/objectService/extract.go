@@ -0,0 +1,281 @@
+package objectService
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"image"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"cube-go/pkg/config"
+	"cube-go/pkg/oss"
+
+	"github.com/dustin/go-humanize"
+)
+
+// 解压上传限制，0 表示不限制；压缩包本身的大小限制为 0 时与普通上传相同
+var (
+	ExtractArchiveLimit = humanize.MiByte * config.Config.GetInt64("oss.extractLimit")
+	ExtractMaxEntries   = config.Config.GetInt("oss.extractMaxEntries")
+	ExtractMaxSize      = humanize.MiByte * config.Config.GetInt64("oss.extractMaxSize")
+)
+
+var (
+	// ErrInvalidArchive 不是受支持的 ZIP 或 tar 压缩包，或压缩包已损坏
+	ErrInvalidArchive = errors.New("invalid or unsupported archive")
+	// ErrTooManyEntries 压缩包条目数超出限制
+	ErrTooManyEntries = errors.New("too many archive entries")
+	// ErrExtractTooLarge 解压后总大小超出限制
+	ErrExtractTooLarge = errors.New("extracted size exceeds limit")
+	// ErrUnsupportedEntry 链接、设备等非普通文件条目
+	ErrUnsupportedEntry = errors.New("unsupported archive entry")
+	// ErrEntryTooLarge 单个条目超出上传大小限制
+	ErrEntryTooLarge = errors.New("archive entry exceeds size limit")
+)
+
+// ExtractOptions 解压选项
+type ExtractOptions struct {
+	Location    string // 解压到的目录
+	ConvertWebP bool   // 将图片条目转换为 WebP，非图片条目原样保存
+	Overwrite   bool
+
+	Uploader string
+	Metadata map[string]string // 应用到每个条目
+	Tags     map[string]string
+
+	MaxEntries int   // 条目数上限，不含目录
+	MaxSize    int64 // 解压后总大小上限
+	EntryLimit int64 // 单个条目大小上限
+}
+
+// ExtractResult 压缩包中一个条目的解压结果
+type ExtractResult struct {
+	Name      string // 条目在压缩包内的路径
+	ObjectKey string
+	Size      int64
+	SHA256    string // 非空时已尝试保存
+	Converted bool   // 已转换为 WebP
+	Err       error  // 为 nil 时已保存
+}
+
+// archiveEntry 压缩包条目，open 只能在遍历到该条目时调用
+type archiveEntry struct {
+	name    string
+	size    int64
+	regular bool
+	open    func() (io.ReadCloser, error)
+}
+
+// ExtractArchive 将 ZIP、tar 或 tar.gz 压缩包中的文件逐个保存到存储桶，目录条目不单独保存。
+// 先完整检查一遍条目数与解压后大小，超出限制时不保存任何条目；
+// 条目路径必须是压缩包内的相对路径，否则只记录失败，防止写到目标目录之外；路径中的非法字符与普通上传一样被去除。
+func ExtractArchive(ctx context.Context, provider oss.StorageProvider, file io.ReadSeeker, size int64, options ExtractOptions) ([]ExtractResult, error) {
+	walk, err := archiveWalker(file, size)
+	if err != nil {
+		return nil, err
+	}
+	location, _, err := oss.NormalizeObjectKey(CleanLocation(options.Location), true)
+	if err != nil {
+		return nil, err
+	}
+
+	// 第一遍只检查限制
+	var entries int
+	var total int64
+	err = walk(func(entry archiveEntry) error {
+		if !entry.regular {
+			return nil
+		}
+		entries++
+		total += entry.size
+		if options.MaxEntries > 0 && entries > options.MaxEntries {
+			return ErrTooManyEntries
+		}
+		if options.MaxSize > 0 && total > options.MaxSize {
+			return ErrExtractTooLarge
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	results := make([]ExtractResult, 0, entries)
+	err = walk(func(entry archiveEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		result := ExtractResult{Name: entry.name, Size: entry.size}
+		result.Err = extractEntry(ctx, provider, location, entry, options, &result)
+		results = append(results, result)
+		return nil
+	})
+	return results, err
+}
+
+func extractEntry(ctx context.Context, provider oss.StorageProvider, location string, entry archiveEntry, options ExtractOptions, result *ExtractResult) error {
+	// tar 常以 ./ 开头，仍位于目标目录内；先检查是否越出目标目录，再与普通上传一样去除非法字符
+	name, _, err := oss.NormalizeObjectKey(strings.TrimPrefix(entry.name, "./"), false)
+	if err != nil {
+		return err
+	}
+	if name, _, err = oss.NormalizeObjectKey(CleanLocation(name), false); err != nil {
+		return err
+	}
+	objectKey := path.Join(location, name)
+	result.ObjectKey = objectKey
+	if !entry.regular {
+		return ErrUnsupportedEntry
+	}
+	if options.EntryLimit > 0 && entry.size > options.EntryLimit {
+		return ErrEntryTooLarge
+	}
+
+	// 条目写入临时文件，不在内存中缓存整个条目
+	temp, err := os.CreateTemp("", "cube-extract-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = temp.Close()
+		_ = os.Remove(temp.Name())
+	}()
+	rc, err := entry.open()
+	if err != nil {
+		return errors.Join(ErrInvalidArchive, err)
+	}
+	// 声明的大小可能与实际不符，多读一个字节用于判断
+	limit := entry.size
+	if options.EntryLimit > 0 && options.EntryLimit < limit {
+		limit = options.EntryLimit
+	}
+	written, err := io.Copy(temp, io.LimitReader(rc, limit+1))
+	_ = rc.Close()
+	if err != nil {
+		return errors.Join(ErrInvalidArchive, err)
+	}
+	if written > limit {
+		return ErrEntryTooLarge
+	}
+	if _, err := temp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	var reader io.ReadSeeker = temp
+	if options.ConvertWebP {
+		converted, err := ConvertToWebP(temp)
+		if err != nil && !errors.Is(err, image.ErrFormat) {
+			return err
+		}
+		if err == nil {
+			reader = converted
+			objectKey = strings.TrimSuffix(objectKey, path.Ext(objectKey)) + ".webp"
+			result.ObjectKey, result.Converted = objectKey, true
+		} else if _, err := temp.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	size, checksum, err := HashReader(reader)
+	if err != nil {
+		return err
+	}
+	result.Size, result.SHA256 = size, checksum
+	return provider.SaveObject(ctx, reader, objectKey, oss.SaveObjectOptions{
+		Overwrite:    options.Overwrite,
+		OriginalName: path.Base(entry.name),
+		Uploader:     options.Uploader,
+		Metadata:     options.Metadata,
+		Tags:         options.Tags,
+		SHA256:       checksum,
+	})
+}
+
+// archiveWalker 根据文件头识别压缩包格式，返回按顺序遍历条目的函数
+func archiveWalker(file io.ReadSeeker, size int64) (func(func(archiveEntry) error) error, error) {
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, ErrInvalidArchive
+	}
+	header = header[:n]
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")) || bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		readerAt, ok := file.(io.ReaderAt)
+		if !ok {
+			return nil, ErrInvalidArchive
+		}
+		archive, err := zip.NewReader(readerAt, size)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidArchive, err)
+		}
+		return func(fn func(archiveEntry) error) error {
+			for _, f := range archive.File {
+				if f.FileInfo().IsDir() {
+					continue
+				}
+				err := fn(archiveEntry{
+					name:    f.Name,
+					size:    int64(f.UncompressedSize64),
+					regular: f.Mode().IsRegular(),
+					open:    f.Open,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return func(fn func(archiveEntry) error) error {
+			gz, err := gzip.NewReader(file)
+			if err != nil {
+				return errors.Join(ErrInvalidArchive, err)
+			}
+			defer func() { _ = gz.Close() }()
+			return walkTar(tar.NewReader(gz), fn)
+		}, nil
+	case len(header) > 262 && string(header[257:262]) == "ustar":
+		return func(fn func(archiveEntry) error) error {
+			return walkTar(tar.NewReader(file), fn)
+		}, nil
+	}
+	return nil, ErrInvalidArchive
+}
+
+func walkTar(reader *tar.Reader, fn func(archiveEntry) error) error {
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return errors.Join(ErrInvalidArchive, err)
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		err = fn(archiveEntry{
+			name:    header.Name,
+			size:    header.Size,
+			regular: header.Typeflag == tar.TypeReg,
+			open:    func() (io.ReadCloser, error) { return io.NopCloser(reader), nil },
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
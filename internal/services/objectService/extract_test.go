@@ -0,0 +1,154 @@
+package objectService_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"cube-go/internal/services/objectService"
+	"cube-go/pkg/oss"
+)
+
+type archiveFile struct {
+	name    string
+	content string
+}
+
+func zipArchive(t *testing.T, files []archiveFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range files {
+		entry, err := w.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarArchive(t *testing.T, files []archiveFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, f := range files {
+		header := &tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.content)), Typeflag: tar.TypeReg, Format: tar.FormatUSTAR}
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractArchive(t *testing.T) {
+	cases := []struct {
+		name       string
+		files      []archiveFile
+		maxEntries int
+		wantErr    error             // ExtractArchive 整体失败
+		wantFailed map[string]error  // 按条目名记录的失败
+		wantSaved  map[string]string // 保存的对象与内容
+	}{
+		{
+			name:      "Relative",
+			files:     []archiveFile{{"a.txt", "a"}, {"./sub/b.txt", "b"}},
+			wantSaved: map[string]string{"dest/a.txt": "a", "dest/sub/b.txt": "b"},
+		},
+		{
+			name:       "ParentTraversal",
+			files:      []archiveFile{{"../evil.txt", "evil"}, {"sub/../../evil.txt", "evil"}, {"ok.txt", "ok"}},
+			wantFailed: map[string]error{"../evil.txt": oss.ErrInvalidObjectKey, "sub/../../evil.txt": oss.ErrInvalidObjectKey},
+			wantSaved:  map[string]string{"dest/ok.txt": "ok"},
+		},
+		{
+			name:       "Absolute",
+			files:      []archiveFile{{"/etc/passwd", "root"}, {"ok.txt", "ok"}},
+			wantFailed: map[string]error{"/etc/passwd": oss.ErrInvalidObjectKey},
+			wantSaved:  map[string]string{"dest/ok.txt": "ok"},
+		},
+		{
+			name:       "Backslash",
+			files:      []archiveFile{{`..\evil.txt`, "evil"}, {`sub\c.txt`, "c"}, {"ok.txt", "ok"}},
+			wantFailed: map[string]error{`..\evil.txt`: oss.ErrInvalidObjectKey, `sub\c.txt`: oss.ErrInvalidObjectKey},
+			wantSaved:  map[string]string{"dest/ok.txt": "ok"},
+		},
+		{
+			name:       "TooManyEntries",
+			files:      []archiveFile{{"a.txt", "a"}, {"b.txt", "b"}, {"c.txt", "c"}},
+			maxEntries: 2,
+			wantErr:    objectService.ErrTooManyEntries,
+		},
+	}
+	formats := []struct {
+		name  string
+		build func(*testing.T, []archiveFile) []byte
+	}{
+		{"zip", zipArchive},
+		{"tar", tarArchive},
+	}
+	for _, format := range formats {
+		for _, c := range cases {
+			t.Run(format.name+"/"+c.name, func(t *testing.T) {
+				ctx := context.Background()
+				provider := oss.NewMemoryStorageProvider(oss.MemoryStorageOptions{})
+				data := format.build(t, c.files)
+				results, err := objectService.ExtractArchive(ctx, provider, bytes.NewReader(data), int64(len(data)), objectService.ExtractOptions{
+					Location:   "dest/",
+					MaxEntries: c.maxEntries,
+				})
+				if !errors.Is(err, c.wantErr) {
+					t.Fatalf("ExtractArchive error = %v, want %v", err, c.wantErr)
+				}
+				if err == nil && len(results) != len(c.files) {
+					t.Fatalf("results = %+v, want %d entries", results, len(c.files))
+				}
+				for _, result := range results {
+					if want := c.wantFailed[result.Name]; !errors.Is(result.Err, want) || (want == nil) != (result.Err == nil) {
+						t.Errorf("entry %q error = %v, want %v", result.Name, result.Err, want)
+					}
+				}
+
+				saved := make(map[string]string)
+				err = oss.WalkObjects(ctx, provider, "", func(entry oss.ObjectEntry) error {
+					reader, _, err := provider.GetObject(ctx, entry.Key, oss.GetObjectOptions{})
+					if err != nil {
+						return err
+					}
+					defer func() { _ = reader.Close() }()
+					var content bytes.Buffer
+					if _, err := content.ReadFrom(reader); err != nil {
+						return err
+					}
+					saved[entry.Key] = content.String()
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(saved) != len(c.wantSaved) {
+					t.Errorf("saved objects = %v, want %v", saved, c.wantSaved)
+				}
+				for key, content := range c.wantSaved {
+					if saved[key] != content {
+						t.Errorf("object %q = %q, want %q", key, saved[key], content)
+					}
+				}
+			})
+		}
+	}
+}
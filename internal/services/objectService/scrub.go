@@ -0,0 +1,84 @@
+package objectService
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cube-go/pkg/config"
+	"cube-go/pkg/oss"
+)
+
+// staleThumbnailTempAge 超过该时长的缩略图临时文件视为崩溃残留
+const staleThumbnailTempAge = 10 * time.Minute
+
+// ScrubThumbnails 检查缓存目录中已没有对应源对象的缩略图。
+// 通过为所有存储桶中的对象重新计算缓存键得到有效缩略图集合，任一存储桶遍历失败时放弃本次检查，避免误删。
+func ScrubThumbnails(ctx context.Context, fix bool) (*oss.ScrubResult, error) {
+	startedAt := time.Now()
+	valid := make(map[string]struct{})
+	for _, name := range oss.Buckets.GetBucketList() {
+		provider, err := oss.Buckets.GetBucket(name)
+		if err != nil {
+			return nil, err
+		}
+		err = oss.WalkObjects(ctx, provider, "", func(entry oss.ObjectEntry) error {
+			info, err := provider.StatObject(ctx, entry.Key, oss.GetObjectOptions{})
+			if errors.Is(err, oss.ErrResourceNotExists) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			valid[thumbnailCacheKey(name, entry.Key, info)+".jpg"] = struct{}{}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &oss.ScrubResult{Issues: []oss.ScrubIssue{}}
+	dir := config.Config.GetString("oss.thumbnailDir")
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		name := entry.Name()
+		kind := ""
+		switch {
+		case strings.HasPrefix(name, ".thumbnail-") && strings.HasSuffix(name, ".tmp"):
+			if time.Since(info.ModTime()) >= staleThumbnailTempAge {
+				kind = oss.ScrubStaleTemp
+			}
+		case strings.HasSuffix(name, ".jpg"):
+			result.Objects++
+			result.Bytes += info.Size()
+			// 巡检开始后生成的缩略图可能对应遍历之后上传的对象
+			if _, ok := valid[name]; !ok && info.ModTime().Before(startedAt) {
+				kind = oss.ScrubOrphanThumbnail
+			}
+		}
+		if kind == "" {
+			continue
+		}
+		fixed := fix && os.Remove(filepath.Join(dir, name)) == nil
+		result.Issues = append(result.Issues, oss.ScrubIssue{Kind: kind, ObjectKey: name, Fixed: fixed})
+	}
+	return result, nil
+}
@@ -0,0 +1,107 @@
+package objectService
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"cube-go/pkg/oss"
+
+	"go.uber.org/zap"
+)
+
+// ErrInvalidCursor 分页游标不合法
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SearchOptions 跨存储桶搜索选项
+type SearchOptions struct {
+	Bucket string // 为空时搜索所有存储桶
+	Query  oss.SearchQuery
+	Cursor string // 上一页返回的游标，为空时从头开始
+	Limit  int
+}
+
+// SearchResult 一页搜索结果，按存储桶名与对象键排序
+type SearchResult struct {
+	Results []oss.IndexEntry  `json:"results"`
+	Next    string            `json:"next,omitempty"`   // 下一页游标，为空表示没有更多结果；未建立索引的存储桶候选过多时本页结果可能不足 limit 个
+	Failed  map[string]string `json:"failed,omitempty"` // 搜索所有存储桶时无法访问的存储桶及原因
+}
+
+// Search 在存储桶中搜索对象。指定存储桶时其错误直接返回；
+// 搜索所有存储桶时跳过无法访问的存储桶，并在结果中列出。
+func Search(ctx context.Context, options SearchOptions) (*SearchResult, error) {
+	if err := options.Query.Validate(); err != nil {
+		return nil, err
+	}
+	afterBucket, afterKey, err := decodeCursor(options.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	names := oss.Buckets.GetBucketList()
+	if options.Bucket != "" {
+		names = []string{options.Bucket}
+	}
+
+	result := &SearchResult{Results: make([]oss.IndexEntry, 0, options.Limit)}
+	for _, name := range names {
+		if name < afterBucket {
+			continue
+		}
+		after := ""
+		if name == afterBucket {
+			after = afterKey
+		}
+		provider, err := oss.Buckets.GetBucket(name)
+		if err != nil {
+			return nil, err
+		}
+		// 多取一个结果，用于判断是否还有下一页
+		entries, resume, err := oss.SearchObjects(ctx, provider, name, options.Query, after, options.Limit+1-len(result.Results))
+		if err != nil {
+			if options.Bucket != "" || ctx.Err() != nil || errors.Is(err, oss.ErrInvalidObjectKey) {
+				return nil, err
+			}
+			zap.L().Warn("搜索存储桶失败", zap.String("bucket", name), zap.Error(err))
+			if result.Failed == nil {
+				result.Failed = make(map[string]string)
+			}
+			result.Failed[name] = err.Error()
+			continue
+		}
+		result.Results = append(result.Results, entries...)
+		if len(result.Results) > options.Limit {
+			result.Results = result.Results[:options.Limit]
+			last := result.Results[len(result.Results)-1]
+			result.Next = encodeCursor(last.Bucket, last.Key)
+			break
+		}
+		if resume != "" {
+			// 该存储桶只检查了一部分候选，下一页从检查过的最后一个对象键之后继续
+			result.Next = encodeCursor(name, resume)
+			break
+		}
+	}
+	return result, nil
+}
+
+// 游标由存储桶名与对象键组成，存储桶名不含 /
+func encodeCursor(bucket, key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(bucket + "/" + key))
+}
+
+func decodeCursor(cursor string) (string, string, error) {
+	if cursor == "" {
+		return "", "", nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", "", ErrInvalidCursor
+	}
+	bucket, key, found := strings.Cut(string(data), "/")
+	if !found || bucket == "" || key == "" {
+		return "", "", ErrInvalidCursor
+	}
+	return bucket, key, nil
+}
@@ -51,6 +51,19 @@ func CleanLocation(location string) string {
 	return result
 }
 
+// HashReader 计算内容的大小与 SHA-256，完成后将读取位置重置到开头
+func HashReader(reader io.ReadSeeker) (int64, string, error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, reader)
+	if err != nil {
+		return 0, "", err
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 // ConvertToWebP 将图片转换为 WebP 格式
 func ConvertToWebP(reader io.Reader) (*bytes.Reader, error) {
 	img, err := imaging.Decode(reader, imaging.AutoOrientation(true))
@@ -0,0 +1,334 @@
+package scrubService
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cube-go/internal/services/auditService"
+	"cube-go/internal/services/objectService"
+	"cube-go/pkg/config"
+	"cube-go/pkg/oss"
+
+	"go.uber.org/zap"
+)
+
+// Options 巡检选项
+type Options struct {
+	Bucket     string // 为空时巡检所有存储桶
+	Fix        bool   // 修复可修复的问题
+	Thumbnails bool   // 检查缩略图缓存
+	Actor      string // 发起巡检的管理员，记录到修复的审计日志
+}
+
+// Report 巡检报告
+type Report struct {
+	ID         string                      `json:"id"`
+	StartedAt  time.Time                   `json:"started_at"`
+	FinishedAt time.Time                   `json:"finished_at"`
+	Fix        bool                        `json:"fix"`
+	Issues     int                         `json:"issues"`
+	Buckets    map[string]*oss.ScrubResult `json:"buckets"`
+	Thumbnails *oss.ScrubResult            `json:"thumbnails,omitempty"`
+	Skipped    []string                    `json:"skipped"` // 不支持巡检的存储桶
+	Errors     map[string]string           `json:"errors,omitempty"`
+}
+
+// Summary 巡检报告摘要
+type Summary struct {
+	ID         string    `json:"id"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Fix        bool      `json:"fix"`
+	Issues     int       `json:"issues"`
+}
+
+var (
+	// ErrRunning 已有巡检正在进行
+	ErrRunning = errors.New("scrub is already running")
+	// ErrReportNotFound 巡检报告不存在
+	ErrReportNotFound = errors.New("scrub report not found")
+)
+
+const thumbnailsName = "thumbnails"
+
+var reportIDPattern = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}\.[0-9]{9}Z$`)
+
+var (
+	running atomic.Bool
+	wg      sync.WaitGroup
+	// baseCtx 在 Close 时取消，后台巡检与定期巡检均基于它
+	baseCtx, stop = context.WithCancel(context.Background())
+)
+
+// Init 初始化报告目录，并按配置启动定期巡检
+func Init() error {
+	if err := os.MkdirAll(reportDir(), 0750); err != nil {
+		return err
+	}
+	interval := config.Config.GetInt("scrub.interval")
+	if interval <= 0 {
+		return nil
+	}
+	options := Options{Fix: config.Config.GetBool("scrub.fix"), Thumbnails: true, Actor: "scrub"}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(time.Duration(interval) * time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-baseCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			if _, err := Run(baseCtx, options); err != nil && !errors.Is(err, ErrRunning) {
+				zap.L().Error("定期巡检失败", zap.Error(err))
+			}
+		}
+	}()
+	return nil
+}
+
+// Close 取消正在进行的巡检并停止定期巡检
+func Close() {
+	stop()
+	wg.Wait()
+}
+
+// Start 在后台开始一次巡检，返回报告 ID
+func Start(options Options) (string, error) {
+	if !running.CompareAndSwap(false, true) {
+		return "", ErrRunning
+	}
+	report := newReport(options)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer running.Store(false)
+		if err := execute(baseCtx, report, options); err != nil {
+			zap.L().Error("巡检失败", zap.String("id", report.ID), zap.Error(err))
+		}
+	}()
+	return report.ID, nil
+}
+
+// Run 执行一次巡检并保存报告
+func Run(ctx context.Context, options Options) (*Report, error) {
+	if !running.CompareAndSwap(false, true) {
+		return nil, ErrRunning
+	}
+	defer running.Store(false)
+	report := newReport(options)
+	if err := execute(ctx, report, options); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func newReport(options Options) *Report {
+	now := time.Now().UTC()
+	return &Report{
+		ID:        now.Format("20060102T150405.000000000Z"),
+		StartedAt: now,
+		Fix:       options.Fix,
+		Buckets:   make(map[string]*oss.ScrubResult),
+		Skipped:   []string{},
+		Errors:    make(map[string]string),
+	}
+}
+
+func execute(ctx context.Context, report *Report, options Options) error {
+	names := oss.Buckets.GetManagedBucketList()
+	if options.Bucket != "" {
+		names = []string{options.Bucket}
+	}
+	// 镜像存储桶巡检时会逐个巡检副本并用健康的副本修复，副本不再单独巡检
+	members := make(map[string]bool)
+	for _, name := range names {
+		bucket, err := oss.Buckets.GetManagedBucket(name)
+		if err != nil {
+			return err
+		}
+		if mirror, ok := oss.As[*oss.MirrorProvider](bucket); ok {
+			for _, replica := range mirror.Status().Replicas {
+				members[replica] = true
+			}
+		}
+	}
+	for _, name := range names {
+		if members[name] {
+			continue
+		}
+		bucket, err := oss.Buckets.GetManagedBucket(name)
+		if err != nil {
+			return err
+		}
+		scrubber, ok := oss.As[oss.ScrubProvider](bucket)
+		if !ok {
+			report.Skipped = append(report.Skipped, name)
+			continue
+		}
+		result, err := scrubber.Scrub(ctx, options.Fix)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			report.Errors[name] = err.Error()
+			continue
+		}
+		report.Buckets[name] = result
+		report.Issues += len(result.Issues)
+		recordFixes(options.Actor, name, result)
+	}
+	if options.Thumbnails {
+		result, err := objectService.ScrubThumbnails(ctx, options.Fix)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			report.Errors[thumbnailsName] = err.Error()
+		} else {
+			report.Thumbnails = result
+			report.Issues += len(result.Issues)
+		}
+	}
+	report.FinishedAt = time.Now().UTC()
+	zap.L().Info("巡检完成", zap.String("id", report.ID), zap.Int("issues", report.Issues))
+	return save(report)
+}
+
+// recordFixes 为巡检修改过的每个对象记录审计日志
+func recordFixes(actor, bucket string, result *oss.ScrubResult) {
+	for _, issue := range result.Issues {
+		if !issue.Fixed {
+			continue
+		}
+		detail := issue.Kind
+		if issue.Detail != "" {
+			detail += ": " + issue.Detail
+		}
+		entry := auditService.Entry{
+			Actor:     actor,
+			Operation: auditService.OperationScrub,
+			Bucket:    bucket,
+			ObjectKey: issue.ObjectKey,
+			SHA256:    issue.Expected,
+			Result:    auditService.ResultSuccess,
+			Detail:    detail,
+		}
+		if issue.Replica != "" {
+			entry.Bucket = issue.Replica
+		}
+		auditService.Record(entry)
+	}
+}
+
+// save 保存报告并清理超出保留数量的旧报告
+func save(report *Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := reportDir()
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, report.ID+".json"), data, 0640); err != nil {
+		return err
+	}
+	ids, err := reportIDs()
+	if err != nil {
+		return err
+	}
+	keep := config.Config.GetInt("scrub.keepReports")
+	if keep <= 0 {
+		keep = 30
+	}
+	for _, id := range ids[min(keep, len(ids)):] {
+		_ = os.Remove(filepath.Join(dir, id+".json"))
+	}
+	return nil
+}
+
+// List 列出巡检报告摘要，按时间倒序
+func List() ([]Summary, error) {
+	ids, err := reportIDs()
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]Summary, 0, len(ids))
+	for _, id := range ids {
+		report, err := Get(id)
+		if err != nil {
+			continue
+		}
+		summaries = append(summaries, Summary{
+			ID:         report.ID,
+			StartedAt:  report.StartedAt,
+			FinishedAt: report.FinishedAt,
+			Fix:        report.Fix,
+			Issues:     report.Issues,
+		})
+	}
+	return summaries, nil
+}
+
+// Get 读取巡检报告
+func Get(id string) (*Report, error) {
+	if !reportIDPattern.MatchString(id) {
+		return nil, ErrReportNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(reportDir(), id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrReportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Running 是否有巡检正在进行
+func Running() bool {
+	return running.Load()
+}
+
+func reportIDs() ([]string, error) {
+	entries, err := os.ReadDir(reportDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if ok && reportIDPattern.MatchString(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	return ids, nil
+}
+
+func reportDir() string {
+	dir := config.Config.GetString("scrub.reportDir")
+	if dir == "" {
+		dir = "./scrub_reports"
+	}
+	return dir
+}
@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"strings"
 
 	"cube-go/internal/midwares"
 	"cube-go/internal/routes"
+	"cube-go/internal/services/auditService"
+	"cube-go/internal/services/scrubService"
 	"cube-go/pkg/config"
 	"cube-go/pkg/log"
 	"cube-go/pkg/oss"
@@ -16,6 +20,17 @@ import (
 )
 
 func main() {
+	if err := config.Err(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "Config not found", err)
+		os.Exit(1)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "fsck" {
+		os.Exit(fsck(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "reindex" {
+		os.Exit(reindex(os.Args[2:]))
+	}
+	log.Init()
 	if !config.Config.GetBool("server.debug") {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -24,11 +39,29 @@ func main() {
 	r.Use(midwares.ErrHandler())
 	r.NoMethod(midwares.HandleNotFound)
 	r.NoRoute(midwares.HandleNotFound)
-	log.Init()
+	// 未配置可信代理时不信任任何转发头，避免伪造 X-Forwarded-For 绕过按 IP 限流
+	proxies := config.Config.GetStringSlice("server.trustedProxies")
+	if len(proxies) == 0 {
+		proxies = nil
+	}
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		zap.L().Fatal("Invalid server.trustedProxies", zap.Error(err))
+	}
 	if strings.TrimSpace(config.Config.GetString("oss.adminKey")) == "" {
 		zap.L().Fatal("oss.adminKey must not be empty")
 	}
-	if err := oss.Init(context.Background()); err != nil {
+	if err := auditService.Init(); err != nil {
+		zap.L().Fatal("Init audit log failed", zap.Error(err))
+	}
+	defer func() {
+		if err := auditService.Close(); err != nil {
+			zap.L().Error("Close audit log failed", zap.Error(err))
+		}
+	}()
+	oss.ReconcileHook = func(bucket string, report *oss.MirrorReport) {
+		auditService.RecordReconcile("reconcile", "", bucket, report)
+	}
+	if err := oss.Init(context.Background(), oss.InitOptions{Background: true}); err != nil {
 		zap.L().Fatal("Init OSS failed", zap.Error(err))
 	}
 	defer func() {
@@ -36,6 +69,10 @@ func main() {
 			zap.L().Error("Close OSS failed", zap.Error(err))
 		}
 	}()
+	if err := scrubService.Init(); err != nil {
+		zap.L().Fatal("Init scrub failed", zap.Error(err))
+	}
+	defer scrubService.Close()
 	routes.Init(r)
 	server.Run(r, ":"+config.Config.GetString("server.port"))
 }
@@ -1,21 +1,25 @@
 package config
 
 import (
-	"log"
-
 	"github.com/spf13/viper"
 )
 
 // Config 全局 Viper 实例
 var Config = viper.New()
 
-// init 加载配置文件
+// loadErr 读取配置文件的错误
+var loadErr error
+
+// init 加载配置文件。各包的配置项在包初始化时读取，因此配置文件需在此时加载；
+// 找不到配置文件时不在这里退出，由程序入口通过 Err 检查，测试等不需要配置文件的场景使用默认值
 func init() {
 	Config.SetConfigName("config")
 	Config.SetConfigType("yaml")
 	Config.AddConfigPath(".")
-	err := Config.ReadInConfig()
-	if err != nil {
-		log.Fatal("Config not found", err)
-	}
+	loadErr = Config.ReadInConfig()
+}
+
+// Err 返回加载配置文件时的错误
+func Err() error {
+	return loadErr
 }
@@ -9,24 +9,49 @@ import (
 // BucketManager 存储桶管理器
 type BucketManager struct {
 	buckets map[string]StorageProvider
+	members map[string]string // 镜像、分层存储桶的成员所属的存储桶，成员不直接对外提供
+	jobs    []io.Closer       // 后台任务，先于存储桶关闭
+	pools   []io.Closer       // 存储桶共用的连接池，在存储桶之后关闭
+	index   *ObjectIndex      // 对象索引，未启用时为 nil，最后关闭
 }
 
 // 定义存储桶相关错误
 var (
 	ErrBucketAlreadyExists = errors.New("bucket already exists")
 	ErrBucketNotFound      = errors.New("bucket not found")
+	ErrBucketInUse         = errors.New("bucket is already a member of another mirror or tiered bucket")
 )
 
-// GetBucket 获取存储桶
+// GetBucket 获取存储桶，镜像、分层存储桶的成员视为不存在，只能经由所属的存储桶访问
 func (m *BucketManager) GetBucket(name string) (StorageProvider, error) {
+	if _, ok := m.members[name]; ok {
+		return nil, ErrBucketNotFound
+	}
+	return m.GetManagedBucket(name)
+}
+
+// GetBucketList 获取存储桶列表，不含镜像、分层存储桶的成员
+func (m *BucketManager) GetBucketList() []string {
+	list := make([]string, 0, len(m.buckets))
+	for k := range m.buckets {
+		if _, ok := m.members[k]; !ok {
+			list = append(list, k)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// GetManagedBucket 获取存储桶，包括镜像、分层存储桶的成员，供巡检、索引等管理操作使用
+func (m *BucketManager) GetManagedBucket(name string) (StorageProvider, error) {
 	if c, ok := m.buckets[name]; ok {
 		return c, nil
 	}
 	return nil, ErrBucketNotFound
 }
 
-// GetBucketList 获取存储桶列表
-func (m *BucketManager) GetBucketList() []string {
+// GetManagedBucketList 获取包括镜像、分层存储桶成员在内的全部存储桶列表
+func (m *BucketManager) GetManagedBucketList() []string {
 	list := make([]string, 0, len(m.buckets))
 	for k := range m.buckets {
 		list = append(list, k)
@@ -35,12 +60,26 @@ func (m *BucketManager) GetBucketList() []string {
 	return list
 }
 
+// Index 返回对象索引，未启用时返回 nil
+func (m *BucketManager) Index() *ObjectIndex {
+	return m.index
+}
+
 func (m *BucketManager) Close() error {
 	var errs []error
+	for _, job := range m.jobs {
+		errs = append(errs, job.Close())
+	}
 	for _, bucket := range m.buckets {
 		if closer, ok := bucket.(io.Closer); ok {
 			errs = append(errs, closer.Close())
 		}
 	}
+	for _, pool := range m.pools {
+		errs = append(errs, pool.Close())
+	}
+	if m.index != nil {
+		errs = append(errs, m.index.Close())
+	}
 	return errors.Join(errs...)
 }
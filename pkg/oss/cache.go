@@ -0,0 +1,453 @@
+package oss
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type cacheConfig struct {
+	Dir     string `mapstructure:"dir"`     // 缓存目录，默认为 cache/<桶名>
+	MaxSize int64  `mapstructure:"maxSize"` // 缓存容量上限，单位 MB
+	MaxAge  int    `mapstructure:"maxAge"`  // 命中后免于向上游校验的时长，单位秒，0 表示每次命中都校验
+}
+
+func (c cacheConfig) enabled() bool {
+	return c.MaxSize > 0
+}
+
+// cacheEntry 缓存条目，与数据文件一同保存为 JSON，重启后恢复
+type cacheEntry struct {
+	Key          string            `json:"key"`
+	ETag         string            `json:"etag"`
+	ContentType  string            `json:"content_type"`
+	Size         int64             `json:"size"`
+	LastModified time.Time         `json:"last_modified"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	SHA256       string            `json:"sha256,omitempty"`
+
+	checked time.Time // 最近一次与上游确认一致的时间
+}
+
+func (e *cacheEntry) objectInfo() *GetObjectInfo {
+	return &GetObjectInfo{
+		ContentType:   e.ContentType,
+		ContentLength: e.Size,
+		AcceptRanges:  "bytes",
+		ETag:          e.ETag,
+		LastModified:  e.LastModified,
+		Metadata:      e.Metadata,
+		SHA256:        e.SHA256,
+	}
+}
+
+// CachedProvider 将远端存储中读取过的对象缓存到本地磁盘，超出容量时淘汰最久未读取的对象。
+// 命中时以 ETag 向上游校验，缓存的对象可随机读取，范围与条件请求直接由缓存文件响应。
+// 经本提供者写入、删除的对象会立即失效；其他途径的修改在下次校验时发现。
+type CachedProvider struct {
+	StorageProvider
+	dir        string
+	maxSize    int64
+	maxAge     time.Duration
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List // 队首为最近读取的条目
+	size       int64
+	generation uint64 // 每次失效递增，回源期间发生失效时不写入缓存
+	fills      sync.Map
+}
+
+// NewCachedProvider 创建读取缓存存储提供者，并恢复缓存目录中已有的条目
+func NewCachedProvider(provider StorageProvider, options cacheConfig) (*CachedProvider, error) {
+	if err := os.MkdirAll(options.Dir, 0750); err != nil {
+		return nil, err
+	}
+	p := &CachedProvider{
+		StorageProvider: provider,
+		dir:             options.Dir,
+		maxSize:         options.MaxSize * 1024 * 1024,
+		maxAge:          time.Duration(options.MaxAge) * time.Second,
+		entries:         make(map[string]*list.Element),
+		lru:             list.New(),
+	}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *CachedProvider) Unwrap() StorageProvider {
+	return p.StorageProvider
+}
+
+func (p *CachedProvider) Close() error {
+	if closer, ok := p.StorageProvider.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Seekable 对象总是从本地文件读取
+func (p *CachedProvider) Seekable() bool {
+	return true
+}
+
+func (p *CachedProvider) SaveObject(ctx context.Context, reader io.ReadSeeker, objectKey string, options SaveObjectOptions) error {
+	err := p.StorageProvider.SaveObject(ctx, reader, objectKey, options)
+	if key, _, keyErr := NormalizeObjectKey(objectKey, false); keyErr == nil {
+		p.invalidate(key, false)
+	}
+	return err
+}
+
+func (p *CachedProvider) rewriteContent(ctx context.Context, objectKey string, reader io.ReadSeeker, ifMatch string) error {
+	rewriter, ok := As[contentRewriter](p.StorageProvider)
+	if !ok {
+		return ErrRewriteNotSupported
+	}
+	err := rewriter.rewriteContent(ctx, objectKey, reader, ifMatch)
+	if key, _, keyErr := NormalizeObjectKey(objectKey, false); keyErr == nil {
+		p.invalidate(key, false)
+	}
+	return err
+}
+
+func (p *CachedProvider) DeleteObject(ctx context.Context, objectKey string) error {
+	err := p.StorageProvider.DeleteObject(ctx, objectKey)
+	if key, _, keyErr := NormalizeObjectKey(objectKey, true); keyErr == nil {
+		p.invalidate(key, true)
+	}
+	return err
+}
+
+// GetObject 优先从缓存读取，未命中或校验发现对象已变化时回源并写入缓存
+func (p *CachedProvider) GetObject(ctx context.Context, objectKey string, options GetObjectOptions) (io.ReadCloser, *GetObjectInfo, error) {
+	if options.VersionID != "" {
+		reader, info, err := p.StorageProvider.GetObject(ctx, objectKey, GetObjectOptions{VersionID: options.VersionID})
+		if err != nil {
+			return nil, nil, err
+		}
+		return spoolObject(reader, info)
+	}
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return nil, nil, ErrInvalidObjectKey
+	}
+	start := time.Now()
+	if reader, info, ok := p.hit(key, start.Add(-p.maxAge)); ok {
+		return reader, info, nil
+	}
+
+	// 并发未命中时只由第一个请求回源，其余请求等待其完成后读取缓存
+	first, done, err := p.waitForFill(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer done()
+	if !first {
+		if reader, info, ok := p.hit(key, start); ok {
+			return reader, info, nil
+		}
+	}
+	return p.fill(ctx, key)
+}
+
+func (p *CachedProvider) GetMetadata(ctx context.Context, objectKey string) (*UserMetadata, error) {
+	metadata, ok := As[MetadataProvider](p.StorageProvider)
+	if !ok {
+		return nil, ErrMetadataNotSupported
+	}
+	return metadata.GetMetadata(ctx, objectKey)
+}
+
+// UpdateMetadata 更新元数据后使缓存失效，缓存的对象信息中包含元数据
+func (p *CachedProvider) UpdateMetadata(ctx context.Context, objectKey string, update UserMetadata) error {
+	metadata, ok := As[MetadataProvider](p.StorageProvider)
+	if !ok {
+		return ErrMetadataNotSupported
+	}
+	err := metadata.UpdateMetadata(ctx, objectKey, update)
+	if key, _, keyErr := NormalizeObjectKey(objectKey, false); keyErr == nil {
+		p.invalidate(key, false)
+	}
+	return err
+}
+
+func (p *CachedProvider) walkObjects(ctx context.Context, prefix string, fn func(ObjectEntry) error) error {
+	return WalkObjects(ctx, p.StorageProvider, prefix, fn)
+}
+
+// waitForFill 对象回源并发锁，等待期间请求取消时返回上下文的错误
+func (p *CachedProvider) waitForFill(ctx context.Context, key string) (first bool, done func(), err error) {
+	ch := make(chan struct{})
+	actual, loaded := p.fills.LoadOrStore(key, ch)
+	if !loaded {
+		return true, func() {
+			close(ch)
+			p.fills.Delete(key)
+		}, nil
+	}
+	if newCh, ok := actual.(chan struct{}); ok {
+		select {
+		case <-newCh:
+		case <-ctx.Done():
+			return false, nil, ctx.Err()
+		}
+	}
+	return false, func() {}, nil
+}
+
+// hit 读取在 since 之后校验过的缓存条目
+func (p *CachedProvider) hit(key string, since time.Time) (io.ReadCloser, *GetObjectInfo, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	element, ok := p.entries[key]
+	if !ok || element.Value.(*cacheEntry).checked.Before(since) {
+		return nil, nil, false
+	}
+	return p.open(element)
+}
+
+// revalidated 上游确认对象未变化，刷新校验时间后读取缓存
+func (p *CachedProvider) revalidated(key, etag string) (io.ReadCloser, *GetObjectInfo, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	element, ok := p.entries[key]
+	if !ok || element.Value.(*cacheEntry).ETag != etag {
+		return nil, nil, false
+	}
+	element.Value.(*cacheEntry).checked = time.Now()
+	return p.open(element)
+}
+
+// open 打开条目的数据文件并将其移到队首，需持有锁
+func (p *CachedProvider) open(element *list.Element) (io.ReadCloser, *GetObjectInfo, bool) {
+	entry := element.Value.(*cacheEntry)
+	dataPath := p.dataPath(entry.Key)
+	file, err := os.Open(dataPath)
+	if err != nil {
+		p.remove(element)
+		return nil, nil, false
+	}
+	p.lru.MoveToFront(element)
+	// 以修改时间记录读取顺序，重启后据此恢复
+	now := time.Now()
+	_ = os.Chtimes(dataPath, now, now)
+	return file, entry.objectInfo(), true
+}
+
+// fill 回源读取对象，已有条目时携带 ETag 条件，上游未变化则直接使用缓存
+func (p *CachedProvider) fill(ctx context.Context, key string) (io.ReadCloser, *GetObjectInfo, error) {
+	p.mu.Lock()
+	generation := p.generation
+	var etag string
+	if element, ok := p.entries[key]; ok {
+		etag = element.Value.(*cacheEntry).ETag
+	}
+	p.mu.Unlock()
+
+	reader, info, err := p.StorageProvider.GetObject(ctx, key, GetObjectOptions{
+		Conditions: ObjectConditions{IfNoneMatch: etag},
+	})
+	if etag != "" && errors.Is(err, ErrNotModified) {
+		if reader, info, ok := p.revalidated(key, etag); ok {
+			return reader, info, nil
+		}
+		// 条目在校验期间被淘汰，重新完整读取
+		reader, info, err = p.StorageProvider.GetObject(ctx, key, GetObjectOptions{})
+	}
+	if err != nil {
+		if errors.Is(err, ErrResourceNotExists) {
+			p.invalidate(key, false)
+		}
+		return nil, nil, err
+	}
+	if info.ETag == "" || info.ContentLength > p.maxSize {
+		return spoolObject(reader, info)
+	}
+	return p.store(key, generation, reader, info)
+}
+
+// store 将回源得到的对象写入缓存，返回指向缓存文件的读取器
+func (p *CachedProvider) store(key string, generation uint64, reader io.ReadCloser, info *GetObjectInfo) (io.ReadCloser, *GetObjectInfo, error) {
+	defer func() { _ = reader.Close() }()
+	dataPath := p.dataPath(key)
+	if err := os.MkdirAll(filepath.Dir(dataPath), 0750); err != nil {
+		return nil, nil, err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(dataPath), ".fill-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	size, err := io.Copy(temp, reader)
+	if err == nil {
+		_, err = temp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = spooledObject{temp}.Close()
+		return nil, nil, err
+	}
+	entry := &cacheEntry{
+		Key:          key,
+		ETag:         info.ETag,
+		ContentType:  info.ContentType,
+		Size:         size,
+		LastModified: info.LastModified,
+		Metadata:     info.Metadata,
+		SHA256:       info.SHA256,
+		checked:      time.Now(),
+	}
+	info = entry.objectInfo()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.generation != generation || size > p.maxSize {
+		// 回源期间对象被修改，读取结果只用于本次请求
+		return spooledObject{temp}, info, nil
+	}
+	if err := p.commit(entry, temp.Name()); err != nil {
+		_ = spooledObject{temp}.Close()
+		return nil, nil, err
+	}
+	return temp, info, nil
+}
+
+// commit 以新数据文件替换条目，需持有锁
+func (p *CachedProvider) commit(entry *cacheEntry, tempPath string) error {
+	dataPath := p.dataPath(entry.Key)
+	sidecar, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// 先移除旧的条目信息，避免中途退出后新旧文件错配
+	if err := os.Remove(dataPath + ".json"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if element, ok := p.entries[entry.Key]; ok {
+		p.size -= element.Value.(*cacheEntry).Size
+		p.lru.Remove(element)
+		delete(p.entries, entry.Key)
+	}
+	if err := os.Rename(tempPath, dataPath); err != nil {
+		return err
+	}
+	if err := os.WriteFile(dataPath+".json", sidecar, 0600); err != nil {
+		_ = os.Remove(dataPath)
+		return err
+	}
+	p.entries[entry.Key] = p.lru.PushFront(entry)
+	p.size += entry.Size
+	p.evict()
+	return nil
+}
+
+// invalidate 移除对象的缓存条目，prefix 为 true 时一并移除目录下的条目
+func (p *CachedProvider) invalidate(key string, prefix bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.generation++
+	if element, ok := p.entries[key]; ok {
+		p.remove(element)
+	}
+	if !prefix {
+		return
+	}
+	for k, element := range p.entries {
+		if key == "" || strings.HasPrefix(k, key+"/") {
+			p.remove(element)
+		}
+	}
+}
+
+// evict 从队尾淘汰条目直到总大小不超过上限，需持有锁
+func (p *CachedProvider) evict() {
+	for p.size > p.maxSize {
+		element := p.lru.Back()
+		if element == nil {
+			return
+		}
+		p.remove(element)
+	}
+}
+
+// remove 删除条目及其文件，需持有锁。已打开的读取器不受影响
+func (p *CachedProvider) remove(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	p.lru.Remove(element)
+	delete(p.entries, entry.Key)
+	p.size -= entry.Size
+	dataPath := p.dataPath(entry.Key)
+	_ = os.Remove(dataPath + ".json")
+	_ = os.Remove(dataPath)
+}
+
+// dataPath 以对象键的哈希作为文件名，避免对象键中的特殊字符与目录层级
+func (p *CachedProvider) dataPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(p.dir, name[:2], name)
+}
+
+// load 恢复缓存目录中的条目，按数据文件的修改时间确定淘汰顺序。
+// 恢复的条目在首次命中时都会向上游校验。
+func (p *CachedProvider) load() error {
+	type loaded struct {
+		entry    *cacheEntry
+		modified time.Time
+	}
+	var found []loaded
+	err := filepath.WalkDir(p.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".fill-") {
+			_ = os.Remove(path)
+			return nil
+		}
+		if !strings.HasSuffix(path, ".json") {
+			if _, err := os.Stat(path + ".json"); errors.Is(err, fs.ErrNotExist) {
+				_ = os.Remove(path)
+			}
+			return nil
+		}
+		dataPath := strings.TrimSuffix(path, ".json")
+		content, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var entry cacheEntry
+		stat, statErr := os.Stat(dataPath)
+		if json.Unmarshal(content, &entry) != nil || statErr != nil || stat.Size() != entry.Size || p.dataPath(entry.Key) != dataPath {
+			_ = os.Remove(dataPath)
+			_ = os.Remove(path)
+			return nil
+		}
+		found = append(found, loaded{entry: &entry, modified: stat.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(found, func(a, b loaded) int {
+		return a.modified.Compare(b.modified)
+	})
+	for _, item := range found {
+		p.entries[item.entry.Key] = p.lru.PushFront(item.entry)
+		p.size += item.entry.Size
+	}
+	p.evict()
+	return nil
+}
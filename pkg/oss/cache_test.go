@@ -0,0 +1,63 @@
+package oss_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"cube-go/pkg/oss"
+)
+
+// blockingProvider 读取对象时阻塞到 release 关闭
+type blockingProvider struct {
+	oss.StorageProvider
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingProvider) GetObject(ctx context.Context, objectKey string, options oss.GetObjectOptions) (io.ReadCloser, *oss.GetObjectInfo, error) {
+	select {
+	case p.started <- struct{}{}:
+	default:
+	}
+	<-p.release
+	return p.StorageProvider.GetObject(ctx, objectKey, options)
+}
+
+func TestCacheFillWaiterCanceled(t *testing.T) {
+	ctx := context.Background()
+	inner := oss.NewMemoryStorageProvider(oss.MemoryStorageOptions{})
+	if err := inner.SaveObject(ctx, strings.NewReader("hello"), "a.txt", oss.SaveObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	upstream := &blockingProvider{StorageProvider: inner, started: make(chan struct{}, 1), release: make(chan struct{})}
+	p, err := oss.NewCachedProviderDir(upstream, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+
+	first := make(chan error, 1)
+	go func() {
+		reader, _, err := p.GetObject(ctx, "a.txt", oss.GetObjectOptions{})
+		if err == nil {
+			_ = reader.Close()
+		}
+		first <- err
+	}()
+	<-upstream.started
+
+	// 等待回源的请求取消后立即返回，不再等待第一个请求
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := p.GetObject(waitCtx, "a.txt", oss.GetObjectOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetObject while another request fills = %v, want context.DeadlineExceeded", err)
+	}
+	close(upstream.release)
+	if err := <-first; err != nil {
+		t.Errorf("first GetObject = %v", err)
+	}
+}
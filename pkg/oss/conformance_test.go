@@ -0,0 +1,61 @@
+package oss_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cube-go/pkg/oss"
+	"cube-go/pkg/oss/osstest"
+)
+
+func TestLocalConformance(t *testing.T) {
+	osstest.Run(t, func(t *testing.T) oss.StorageProvider {
+		// NewLocalStorageProvider 将路径视为相对于工作目录
+		wd, err := os.Getwd()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir, err := filepath.Rel(wd, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := oss.NewLocalStorageProvider(dir, oss.LocalStorageOptions{Metadata: "bolt"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = p.Close() })
+		return p
+	})
+}
+
+func TestMemoryConformance(t *testing.T) {
+	osstest.Run(t, func(t *testing.T) oss.StorageProvider {
+		return oss.NewMemoryStorageProvider(oss.MemoryStorageOptions{})
+	})
+}
+
+func TestS3Conformance(t *testing.T) {
+	osstest.Run(t, func(t *testing.T) oss.StorageProvider {
+		return oss.NewS3StorageProvider(osstest.NewFakeS3(t), "conformance", oss.S3StorageOptions{ListMetadata: true})
+	})
+}
+
+func TestSFTPConformance(t *testing.T) {
+	server := osstest.NewSFTPServer(t)
+	osstest.Run(t, func(t *testing.T) oss.StorageProvider {
+		pool := oss.NewSFTPPool(server.Addr, server.Config, 2)
+		t.Cleanup(func() { _ = pool.Close() })
+		return oss.NewSFTPStorageProvider(pool, t.TempDir())
+	})
+}
+
+func TestWebDAVConformance(t *testing.T) {
+	osstest.Run(t, func(t *testing.T) oss.StorageProvider {
+		p, err := oss.NewWebDAVStorageProvider(osstest.NewWebDAVServer(t), oss.WebDAVStorageOptions{Username: "cube", Password: "cube"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	})
+}
@@ -0,0 +1,91 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+// storedMetadataProvider 由记录上传信息的存储提供者实现，复制对象时用于保留原始文件名与上传者
+type storedMetadataProvider interface {
+	// storedMetadata 读取对象的完整元数据，没有记录时返回 nil
+	storedMetadata(ctx context.Context, objectKey string) (*ObjectMetadata, error)
+}
+
+// copyObject 将对象连同原始文件名、上传者、修改时间、自定义元数据与标签从 src 复制到 dst，覆盖 dst 中的同名对象
+func copyObject(ctx context.Context, src, dst StorageProvider, key string) error {
+	return copyObjectExpecting(ctx, src, dst, key, "")
+}
+
+// copyObjectExpecting 复制对象并要求内容的 SHA-256 为 sum，不一致时 dst 返回 ErrChecksumMismatch；
+// sum 为空时使用源对象记录的校验和
+func copyObjectExpecting(ctx context.Context, src, dst StorageProvider, key, sum string) error {
+	reader, info, err := src.GetObject(ctx, key, GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	if _, ok := reader.(io.ReadSeeker); !ok {
+		if reader, _, err = spoolObject(reader, info); err != nil {
+			return err
+		}
+	}
+	defer func() { _ = reader.Close() }()
+
+	if sum == "" {
+		sum = info.SHA256
+	}
+	options := SaveObjectOptions{Overwrite: true, SHA256: sum, Metadata: info.Metadata, UploadedAt: info.LastModified}
+	if stored, ok := As[storedMetadataProvider](src); ok {
+		meta, err := stored.storedMetadata(ctx, key)
+		if err != nil {
+			return err
+		}
+		if meta != nil {
+			options.OriginalName, options.Uploader = meta.OriginalName, meta.Uploader
+			options.Metadata, options.Tags = meta.Custom, meta.Tags
+		}
+	} else if metadata, ok := As[MetadataProvider](src); ok {
+		if current, err := metadata.GetMetadata(ctx, key); err == nil {
+			options.Metadata, options.Tags = current.Metadata, current.Tags
+		}
+	}
+	return saveCopy(ctx, dst, reader.(io.ReadSeeker), key, options)
+}
+
+// saveCopy 保存对象的副本，目标不支持自定义元数据（如 WebDAV）时不保留元数据与标签
+func saveCopy(ctx context.Context, dst StorageProvider, reader io.ReadSeeker, key string, options SaveObjectOptions) error {
+	err := dst.SaveObject(ctx, reader, key, options)
+	if errors.Is(err, ErrMetadataNotSupported) {
+		options.Metadata, options.Tags = nil, nil
+		err = dst.SaveObject(ctx, reader, key, options)
+	}
+	return err
+}
+
+// spooledObject 缓存到临时文件的对象，关闭时删除临时文件
+type spooledObject struct {
+	*os.File
+}
+
+func (o spooledObject) Close() error {
+	return errors.Join(o.File.Close(), os.Remove(o.Name()))
+}
+
+// spoolObject 将不可随机读取的对象缓存到临时文件，以便调用方随机读取
+func spoolObject(reader io.ReadCloser, info *GetObjectInfo) (io.ReadCloser, *GetObjectInfo, error) {
+	defer func() { _ = reader.Close() }()
+	temp, err := os.CreateTemp("", "cube-spool-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	spooled := spooledObject{temp}
+	if _, err = io.Copy(temp, reader); err == nil {
+		_, err = temp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = spooled.Close()
+		return nil, nil, err
+	}
+	return spooled, info, nil
+}
@@ -0,0 +1,90 @@
+package oss_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cube-go/pkg/oss"
+)
+
+func dedupReport(t *testing.T, p *oss.LocalStorageProvider) *oss.DedupReport {
+	t.Helper()
+	report, err := p.DedupReport(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestDedupBlobRefcount(t *testing.T) {
+	ctx := context.Background()
+	p := newLocalProvider(t, oss.LocalStorageOptions{Dedup: true})
+	save := func(key, content string, overwrite bool) {
+		t.Helper()
+		if err := p.SaveObject(ctx, strings.NewReader(content), key, oss.SaveObjectOptions{Overwrite: overwrite}); err != nil {
+			t.Fatalf("SaveObject(%q): %v", key, err)
+		}
+	}
+
+	save("a.txt", "shared", false)
+	save("dir/b.txt", "shared", false)
+	save("c.txt", "unique", false)
+	if report := dedupReport(t, p); report.Blobs != 2 || report.References != 3 || report.SavedSize != int64(len("shared")) {
+		t.Fatalf("after saves = %+v, want 2 blobs, 3 references", report)
+	}
+
+	// 仍有引用时保留 blob
+	if err := p.DeleteObject(ctx, "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if report := dedupReport(t, p); report.Blobs != 2 || report.References != 2 {
+		t.Fatalf("after deleting one reference = %+v, want 2 blobs, 2 references", report)
+	}
+	if content, err := readObject(t, p, "dir/b.txt"); err != nil || content != "shared" {
+		t.Fatalf("dir/b.txt = %q, %v", content, err)
+	}
+
+	// 覆盖写入释放旧内容的 blob
+	save("c.txt", "shared", true)
+	if report := dedupReport(t, p); report.Blobs != 1 || report.References != 2 {
+		t.Fatalf("after overwrite = %+v, want 1 blob, 2 references", report)
+	}
+
+	// 删除目录释放其中对象引用的 blob
+	if err := p.DeleteObject(ctx, "dir/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.DeleteObject(ctx, "c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if report := dedupReport(t, p); report.Blobs != 0 || report.References != 0 {
+		t.Fatalf("after deleting all = %+v, want no blobs", report)
+	}
+	if result, err := p.Scrub(ctx, false); err != nil || len(result.Issues) != 0 {
+		t.Fatalf("Scrub = %+v, %v, want no orphan blobs", result, err)
+	}
+}
+
+func TestDedupBlobKeptByVersions(t *testing.T) {
+	ctx := context.Background()
+	p := newLocalProvider(t, oss.LocalStorageOptions{Dedup: true, Versioning: true})
+	for _, content := range []string{"v1", "v2"} {
+		if err := p.SaveObject(ctx, strings.NewReader(content), "a.txt", oss.SaveObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if report := dedupReport(t, p); report.Blobs != 2 {
+		t.Fatalf("report = %+v, want the archived version to keep its blob", report)
+	}
+	versions, err := p.ListVersions(ctx, "a.txt")
+	if err != nil || len(versions) != 2 || versions[1].IsLatest {
+		t.Fatalf("ListVersions = %v, %v", versions, err)
+	}
+	if err := p.DeleteVersion(ctx, "a.txt", versions[1].VersionID); err != nil {
+		t.Fatal(err)
+	}
+	if report := dedupReport(t, p); report.Blobs != 1 || report.References != 1 {
+		t.Fatalf("after DeleteVersion = %+v, want 1 blob, 1 reference", report)
+	}
+}
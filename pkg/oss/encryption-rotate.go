@@ -0,0 +1,159 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// RotateReport 密钥轮换结果
+type RotateReport struct {
+	Primary    string            `json:"primary"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at,omitzero"` // 为空时仍在进行
+	Rewrapped  int               `json:"rewrapped"`            // 重新封装数据密钥的对象数
+	Current    int               `json:"current"`              // 已使用主密钥的对象数
+	Failed     map[string]string `json:"failed"`
+	Error      string            `json:"error,omitempty"` // 轮换中止的原因
+}
+
+var (
+	// ErrRotationRunning 已有密钥轮换正在进行
+	ErrRotationRunning = errors.New("key rotation is already running")
+	// ErrRewriteNotSupported 底层存储不支持在保留元数据的同时替换对象内容
+	ErrRewriteNotSupported = errors.New("content rewrite not supported")
+)
+
+// contentRewriter 由能替换对象内容并保留全部元数据的存储提供者实现，用于不改变对象逻辑内容的维护操作。
+// 替换不产生历史版本，上传者、原始文件名、自定义元数据与标签保持不变；ifMatch 不匹配时返回 ErrPreconditionFailed
+type contentRewriter interface {
+	rewriteContent(ctx context.Context, objectKey string, reader io.ReadSeeker, ifMatch string) error
+}
+
+// StartRotate 重新加载密钥文件，并在后台以新的主密钥重新封装仍使用旧密钥的对象，完成后调用 done。
+// 密钥文件无效时直接返回错误，不改变当前密钥。
+// 分段密文保持不变，只替换对象头部；回收站与历史版本中的对象不做处理，对应的旧密钥需继续保留。
+func (p *EncryptedProvider) StartRotate(done func(*RotateReport, error)) error {
+	if _, ok := As[contentRewriter](p.StorageProvider); !ok {
+		return ErrRewriteNotSupported
+	}
+	if !p.rotating.CompareAndSwap(false, true) {
+		return ErrRotationRunning
+	}
+	keys, err := loadKeyring(p.keyFile)
+	if err != nil {
+		p.rotating.Store(false)
+		return err
+	}
+	p.mu.Lock()
+	p.keys = keys
+	report := &RotateReport{Primary: keys.primary, StartedAt: time.Now(), Failed: make(map[string]string)}
+	p.rotation = report
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer p.rotating.Store(false)
+		err := p.rotate(p.ctx, report)
+		p.mu.Lock()
+		report.FinishedAt = time.Now()
+		if err != nil {
+			report.Error = err.Error()
+		}
+		p.mu.Unlock()
+		if done != nil {
+			done(p.RotationStatus(), err)
+		}
+	}()
+	return nil
+}
+
+// RotationStatus 返回最近一次密钥轮换的进度或结果，从未轮换时返回 nil
+func (p *EncryptedProvider) RotationStatus() *RotateReport {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.rotation == nil {
+		return nil
+	}
+	report := *p.rotation
+	report.Failed = make(map[string]string, len(p.rotation.Failed))
+	for key, value := range p.rotation.Failed {
+		report.Failed[key] = value
+	}
+	return &report
+}
+
+func (p *EncryptedProvider) rotate(ctx context.Context, report *RotateReport) error {
+	return WalkObjects(ctx, p.StorageProvider, "", func(entry ObjectEntry) error {
+		rewrapped, err := p.rewrap(ctx, entry.Key, report.Primary)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if rewrapped {
+			p.notifyChange(entry.Key)
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		switch {
+		case err != nil:
+			report.Failed[entry.Key] = err.Error()
+		case rewrapped:
+			report.Rewrapped++
+		default:
+			report.Current++
+		}
+		return nil
+	})
+}
+
+// rewrap 以主密钥重新封装对象的数据密钥，对象在此期间被修改时放弃
+func (p *EncryptedProvider) rewrap(ctx context.Context, key, primary string) (bool, error) {
+	rewriter, ok := As[contentRewriter](p.StorageProvider)
+	if !ok {
+		return false, ErrRewriteNotSupported
+	}
+	reader, info, err := p.StorageProvider.GetObject(ctx, key, GetObjectOptions{})
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = reader.Close() }()
+	header, err := readEncryptionHeader(reader)
+	if err != nil {
+		return false, err
+	}
+	if header.keyID == primary {
+		return false, nil
+	}
+	dataKey, err := p.unwrapKey(header)
+	if err != nil {
+		return false, err
+	}
+	if err := p.wrapKey(header, dataKey); err != nil {
+		return false, err
+	}
+
+	temp, err := os.CreateTemp("", "cube-rewrap-*")
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = temp.Close()
+		_ = os.Remove(temp.Name())
+	}()
+	if _, err := temp.Write(header.marshal()); err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(temp, reader); err != nil {
+		return false, err
+	}
+	if _, err := temp.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	if err := rewriter.rewriteContent(ctx, key, temp, info.ETag); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,345 @@
+package oss
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+// 加密对象格式：头部之后依次存放各分段的密文，每段末尾附带 GCM 认证标签。
+//
+//	magic(8) | 头部长度(2) | 分段大小(4) | 明文长度(8) | SHA-256(32) | 类型长度(1) | 类型
+//	| 密钥 ID 长度(1) | 密钥 ID | 封装随机数(12) | 封装后的数据密钥(48)
+//
+// 分段以头部长度与密钥 ID 之间的字段作为附加认证数据，分段序号作为随机数，
+// 因此分段无法被截断、重排或挪用，而轮换密钥时只需替换头部。
+// 封装数据密钥时以分段大小、明文长度、摘要、类型与密钥 ID 作为附加认证数据，
+// 只读取头部（如获取文件列表）时篡改这些字段也会解封失败。
+const (
+	encryptionMagic         = "CUBEENC1"
+	encryptionTagSize       = 16
+	maxEncryptionHeaderSize = 512
+)
+
+type encryptionHeader struct {
+	chunkSize   int64
+	size        int64
+	sha256      string
+	contentType string
+	keyID       string
+	nonce       []byte
+	wrappedKey  []byte
+
+	raw []byte // 序列化后的头部
+	aad []byte // 分段的附加认证数据
+}
+
+// marshal 序列化头部并计算附加认证数据
+func (h *encryptionHeader) marshal() []byte {
+	buf := make([]byte, 0, maxEncryptionHeaderSize)
+	buf = append(buf, encryptionMagic...)
+	buf = append(buf, 0, 0)
+	buf = h.appendFields(buf)
+	aadLength := len(buf)
+	buf = append(buf, byte(len(h.keyID)))
+	buf = append(buf, h.keyID...)
+	buf = append(buf, h.nonce...)
+	buf = append(buf, h.wrappedKey...)
+	binary.BigEndian.PutUint16(buf[len(encryptionMagic):], uint16(len(buf)))
+	h.raw = buf
+	h.aad = buf[len(encryptionMagic)+2 : aadLength]
+	return buf
+}
+
+// readEncryptionHeader 从对象开头读取并解析头部
+func readEncryptionHeader(reader io.Reader) (*encryptionHeader, error) {
+	prefix := make([]byte, len(encryptionMagic)+2)
+	if _, err := io.ReadFull(reader, prefix); err != nil {
+		return nil, headerReadError(err)
+	}
+	if string(prefix[:len(encryptionMagic)]) != encryptionMagic {
+		return nil, ErrDecryptionFailed
+	}
+	length := int(binary.BigEndian.Uint16(prefix[len(encryptionMagic):]))
+	if length <= len(prefix) || length > maxEncryptionHeaderSize {
+		return nil, ErrDecryptionFailed
+	}
+	raw := make([]byte, length)
+	copy(raw, prefix)
+	if _, err := io.ReadFull(reader, raw[len(prefix):]); err != nil {
+		return nil, headerReadError(err)
+	}
+
+	h := &encryptionHeader{raw: raw}
+	rest := raw[len(prefix):]
+	next := func(n int) []byte {
+		if n > len(rest) {
+			rest = nil
+			return nil
+		}
+		field := rest[:n]
+		rest = rest[n:]
+		return field
+	}
+	field := func() []byte {
+		size := next(1)
+		if size == nil {
+			return nil
+		}
+		return next(int(size[0]))
+	}
+	chunkSize, size, sum := next(4), next(8), next(32)
+	contentType := field()
+	aadLength := length - len(rest)
+	keyID := field()
+	h.nonce, h.wrappedKey = next(12), next(48)
+	if h.wrappedKey == nil || len(rest) != 0 {
+		return nil, ErrDecryptionFailed
+	}
+	h.chunkSize = int64(binary.BigEndian.Uint32(chunkSize))
+	h.size = int64(binary.BigEndian.Uint64(size))
+	h.sha256 = hex.EncodeToString(sum)
+	h.contentType = string(contentType)
+	h.keyID = string(keyID)
+	h.aad = raw[len(prefix):aadLength]
+	if h.chunkSize <= 0 || h.size < 0 {
+		return nil, ErrDecryptionFailed
+	}
+	return h, nil
+}
+
+// keyAAD 返回封装数据密钥时的附加认证数据
+func (h *encryptionHeader) keyAAD() []byte {
+	return append(h.appendFields(nil), h.keyID...)
+}
+
+// appendFields 追加分段大小、明文长度、摘要与类型字段
+func (h *encryptionHeader) appendFields(buf []byte) []byte {
+	sum, _ := hex.DecodeString(h.sha256)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(h.chunkSize))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(h.size))
+	buf = append(buf, sum...)
+	buf = append(buf, byte(len(h.contentType)))
+	return append(buf, h.contentType...)
+}
+
+func headerReadError(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrDecryptionFailed
+	}
+	return err
+}
+
+// chunkOffset 返回第 index 个分段在密文中的起始位置
+func (h *encryptionHeader) chunkOffset(index int64) int64 {
+	return int64(len(h.raw)) + index*(h.chunkSize+encryptionTagSize)
+}
+
+// chunkLength 返回第 index 个分段的明文长度
+func (h *encryptionHeader) chunkLength(index int64) int64 {
+	return min(h.chunkSize, h.size-index*h.chunkSize)
+}
+
+func (h *encryptionHeader) chunks() int64 {
+	return (h.size + h.chunkSize - 1) / h.chunkSize
+}
+
+func (h *encryptionHeader) cipherSize() int64 {
+	return h.chunkOffset(h.chunks()) - (h.chunks()*h.chunkSize - h.size)
+}
+
+// objectInfo 以明文属性替换底层返回的对象信息
+func (h *encryptionHeader) objectInfo(info *GetObjectInfo) *GetObjectInfo {
+	return &GetObjectInfo{
+		ContentType:   h.contentType,
+		ContentLength: h.size,
+		AcceptRanges:  "bytes",
+		ETag:          info.ETag,
+		LastModified:  info.LastModified,
+		Metadata:      info.Metadata,
+		SHA256:        h.sha256,
+	}
+}
+
+func chunkNonce(aead cipher.AEAD, index int64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], uint64(index))
+	return nonce
+}
+
+// encryptReader 按需加密明文分段，可随机读取以便底层提供者计算大小、重试上传
+type encryptReader struct {
+	src    io.ReadSeeker
+	header *encryptionHeader
+	aead   cipher.AEAD
+	offset int64
+	cached int64
+	chunk  []byte
+	plain  []byte
+}
+
+func newEncryptReader(src io.ReadSeeker, header *encryptionHeader, aead cipher.AEAD) *encryptReader {
+	header.marshal()
+	return &encryptReader{src: src, header: header, aead: aead, cached: -1}
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	headerSize := int64(len(r.header.raw))
+	if r.offset < headerSize {
+		n := copy(p, r.header.raw[r.offset:])
+		r.offset += int64(n)
+		return n, nil
+	}
+	if r.offset >= r.header.cipherSize() {
+		return 0, io.EOF
+	}
+	stride := r.header.chunkSize + encryptionTagSize
+	index := (r.offset - headerSize) / stride
+	if index != r.cached {
+		if err := r.seal(index); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.chunk[r.offset-headerSize-index*stride:])
+	r.offset += int64(n)
+	return n, nil
+}
+
+func (r *encryptReader) seal(index int64) error {
+	length := r.header.chunkLength(index)
+	if int64(cap(r.plain)) < length {
+		r.plain = make([]byte, r.header.chunkSize)
+	}
+	plain := r.plain[:length]
+	if _, err := r.src.Seek(index*r.header.chunkSize, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r.src, plain); err != nil {
+		return err
+	}
+	r.chunk = r.aead.Seal(r.chunk[:0], chunkNonce(r.aead, index), plain, r.header.aad)
+	r.cached = index
+	return nil
+}
+
+func (r *encryptReader) Seek(offset int64, whence int) (int64, error) {
+	return seekOffset(&r.offset, r.header.cipherSize(), offset, whence)
+}
+
+// decryptReadSeeker 在可随机读取的密文上提供可随机读取的明文视图
+type decryptReadSeeker struct {
+	src    io.ReadSeeker
+	closer io.Closer
+	header *encryptionHeader
+	aead   cipher.AEAD
+	offset int64
+	cached int64
+	chunk  []byte
+	buf    []byte
+}
+
+func (r *decryptReadSeeker) Read(p []byte) (int, error) {
+	if r.offset >= r.header.size {
+		return 0, io.EOF
+	}
+	index := r.offset / r.header.chunkSize
+	if index != r.cached {
+		if _, err := r.src.Seek(r.header.chunkOffset(index), io.SeekStart); err != nil {
+			return 0, err
+		}
+		chunk, err := openChunk(r.src, r.header, r.aead, index, &r.buf)
+		if err != nil {
+			return 0, err
+		}
+		r.chunk, r.cached = chunk, index
+	}
+	n := copy(p, r.chunk[r.offset-index*r.header.chunkSize:])
+	r.offset += int64(n)
+	return n, nil
+}
+
+func (r *decryptReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return seekOffset(&r.offset, r.header.size, offset, whence)
+}
+
+func (r *decryptReadSeeker) Close() error {
+	return r.closer.Close()
+}
+
+// decryptStream 顺序解密从第 first 个分段开始的密文，跳过 skip 字节后输出 remaining 字节
+type decryptStream struct {
+	src       io.ReadCloser
+	header    *encryptionHeader
+	aead      cipher.AEAD
+	next      int64
+	chunk     []byte
+	buf       []byte
+	skip      int64
+	remaining int64
+}
+
+func newDecryptStream(src io.ReadCloser, header *encryptionHeader, aead cipher.AEAD, first, skip, length int64) *decryptStream {
+	return &decryptStream{src: src, header: header, aead: aead, next: first, skip: skip, remaining: length}
+}
+
+func (r *decryptStream) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if len(r.chunk) == 0 {
+		chunk, err := openChunk(r.src, r.header, r.aead, r.next, &r.buf)
+		if err != nil {
+			return 0, err
+		}
+		r.next++
+		r.chunk = chunk[min(r.skip, int64(len(chunk))):]
+		r.skip = 0
+	}
+	n := copy(p[:min(int64(len(p)), r.remaining)], r.chunk)
+	r.chunk = r.chunk[n:]
+	r.remaining -= int64(n)
+	return n, nil
+}
+
+func (r *decryptStream) Close() error {
+	return r.src.Close()
+}
+
+// openChunk 读取并解密第 index 个分段，失败说明密文被截断或篡改
+func openChunk(src io.Reader, header *encryptionHeader, aead cipher.AEAD, index int64, buf *[]byte) ([]byte, error) {
+	if index >= header.chunks() {
+		return nil, io.ErrUnexpectedEOF
+	}
+	length := header.chunkLength(index) + encryptionTagSize
+	if int64(cap(*buf)) < length {
+		*buf = make([]byte, header.chunkSize+encryptionTagSize)
+	}
+	sealed := (*buf)[:length]
+	if _, err := io.ReadFull(src, sealed); err != nil {
+		return nil, headerReadError(err)
+	}
+	plain, err := aead.Open(sealed[:0], chunkNonce(aead, index), sealed, header.aad)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plain, nil
+}
+
+func seekOffset(current *int64, size, offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += *current
+	case io.SeekEnd:
+		offset += size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	*current = offset
+	return offset, nil
+}
@@ -0,0 +1,409 @@
+package oss
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dustin/go-humanize"
+)
+
+type encryptionConfig struct {
+	KeyFile   string `mapstructure:"keyFile"`   // 密钥文件路径，为空时不启用加密
+	ChunkSize int64  `mapstructure:"chunkSize"` // 分段大小，单位 KB
+}
+
+func (c encryptionConfig) enabled() bool {
+	return c.KeyFile != ""
+}
+
+var (
+	// ErrDecryptionFailed 对象无法解密，可能未加密、已损坏或被篡改
+	ErrDecryptionFailed = errors.New("decryption failed")
+	// ErrEncryptionKeyNotFound 密钥文件中缺少对象使用的密钥
+	ErrEncryptionKeyNotFound = errors.New("encryption key not found")
+	// ErrEncryptionDisabled 存储桶未启用加密
+	ErrEncryptionDisabled = errors.New("encryption disabled")
+	// ErrInvalidKeyFile 密钥文件格式不正确
+	ErrInvalidKeyFile = errors.New("invalid encryption key file")
+)
+
+const (
+	defaultEncryptionChunkSize = 64 // KB
+	maxEncryptionChunkSize     = 16 * 1024
+	encryptionProbeConcurrency = 8 // 列出文件时同时读取头部的对象数
+)
+
+var encryptionKeyIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// keyFile 密钥文件格式，keys 中的密钥为 base64 编码的 32 字节 AES-256 密钥。
+// 轮换时加入新密钥并修改 primary，旧密钥需保留到所有对象重新封装之后。
+type keyFile struct {
+	Primary string            `json:"primary"`
+	Keys    map[string]string `json:"keys"`
+}
+
+type keyring struct {
+	primary string
+	keys    map[string][]byte
+}
+
+func loadKeyring(name string) (*keyring, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKeyFile, err)
+	}
+	ring := &keyring{primary: file.Primary, keys: make(map[string][]byte, len(file.Keys))}
+	for id, encoded := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 || !encryptionKeyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("%w: key %q", ErrInvalidKeyFile, id)
+		}
+		ring.keys[id] = key
+	}
+	if _, ok := ring.keys[ring.primary]; !ok {
+		return nil, fmt.Errorf("%w: primary key %q not found", ErrInvalidKeyFile, ring.primary)
+	}
+	return ring, nil
+}
+
+// EncryptedProvider 在存储提供者外层进行静态加密。
+// 每个对象使用随机数据密钥按固定大小分段进行 AES-GCM 加密，数据密钥由密钥文件中的主密钥封装后
+// 与明文长度、摘要、类型一同写入对象头部，范围请求只需解密覆盖到的分段。
+type EncryptedProvider struct {
+	StorageProvider
+	keyFile   string
+	chunkSize int64
+	mu        sync.RWMutex
+	keys      *keyring
+	changeNotifier
+
+	// 后台密钥轮换，关闭时取消
+	rotating atomic.Bool
+	rotation *RotateReport
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewEncryptedProvider 创建加密存储提供者
+func NewEncryptedProvider(provider StorageProvider, options encryptionConfig) (*EncryptedProvider, error) {
+	chunkSize := options.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = defaultEncryptionChunkSize
+	}
+	if chunkSize < 0 || chunkSize > maxEncryptionChunkSize {
+		return nil, fmt.Errorf("invalid encryption chunk size: %d KB", options.ChunkSize)
+	}
+	keys, err := loadKeyring(options.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &EncryptedProvider{
+		StorageProvider: provider,
+		keyFile:         options.KeyFile,
+		chunkSize:       chunkSize * humanize.KiByte,
+		keys:            keys,
+		ctx:             ctx,
+		cancel:          cancel,
+	}, nil
+}
+
+// Unwrap 返回被装饰的存储提供者
+func (p *EncryptedProvider) Unwrap() StorageProvider {
+	return p.StorageProvider
+}
+
+func (p *EncryptedProvider) Close() error {
+	p.cancel()
+	p.wg.Wait()
+	if closer, ok := p.StorageProvider.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Seekable 底层对象可随机读取时，解密后的对象同样可随机读取
+func (p *EncryptedProvider) Seekable() bool {
+	return IsSeekable(p.StorageProvider)
+}
+
+// SaveObject 加密后保存对象，校验和针对明文计算
+func (p *EncryptedProvider) SaveObject(ctx context.Context, reader io.ReadSeeker, objectKey string, options SaveObjectOptions) error {
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, reader)
+	if err != nil {
+		return err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if options.SHA256 != "" && !strings.EqualFold(options.SHA256, sum) {
+		return ErrChecksumMismatch
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return err
+	}
+	header := &encryptionHeader{
+		chunkSize:   p.chunkSize,
+		size:        size,
+		sha256:      sum,
+		contentType: detectMimeType(reader),
+	}
+	if err := p.wrapKey(header, dataKey); err != nil {
+		return err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return err
+	}
+	options.SHA256 = ""
+	return p.StorageProvider.SaveObject(ctx, newEncryptReader(reader, header, aead), objectKey, options)
+}
+
+// GetObject 获取并解密对象。底层不可随机读取时，范围请求被换算为覆盖所需分段的密文范围。
+func (p *EncryptedProvider) GetObject(ctx context.Context, objectKey string, options GetObjectOptions) (io.ReadCloser, *GetObjectInfo, error) {
+	if p.Seekable() {
+		reader, info, err := p.StorageProvider.GetObject(ctx, objectKey, options)
+		if err != nil {
+			return nil, nil, err
+		}
+		seeker, ok := reader.(io.ReadSeeker)
+		if !ok {
+			_ = reader.Close()
+			return nil, nil, ErrDecryptionFailed
+		}
+		header, aead, err := p.openHeader(seeker)
+		if err != nil {
+			_ = reader.Close()
+			return nil, nil, err
+		}
+		return &decryptReadSeeker{src: seeker, closer: reader, header: header, aead: aead, cached: -1}, header.objectInfo(info), nil
+	}
+
+	if options.Range == "" {
+		reader, info, err := p.StorageProvider.GetObject(ctx, objectKey, options)
+		if err != nil {
+			return nil, nil, err
+		}
+		header, aead, err := p.openHeader(reader)
+		if err != nil {
+			_ = reader.Close()
+			return nil, nil, err
+		}
+		return newDecryptStream(reader, header, aead, 0, 0, header.size), header.objectInfo(info), nil
+	}
+
+	header, aead, info, err := p.probe(ctx, objectKey, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	objectInfo := header.objectInfo(info)
+	start, length, ok := parseByteRange(options.Range, header.size)
+	if !ok {
+		objectInfo.ContentRange = "bytes */" + strconv.FormatInt(header.size, 10)
+		return nil, nil, &ObjectResponseError{Err: ErrInvalidRange, Info: objectInfo}
+	}
+	first, last := start/header.chunkSize, (start+length-1)/header.chunkSize
+	cipherEnd := min(header.chunkOffset(last+1), header.cipherSize()) - 1
+	reader, _, err := p.StorageProvider.GetObject(ctx, objectKey, GetObjectOptions{
+		Conditions: ObjectConditions{IfMatch: info.ETag},
+		Range:      fmt.Sprintf("bytes=%d-%d", header.chunkOffset(first), cipherEnd),
+		VersionID:  options.VersionID,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	objectInfo.ContentLength = length
+	objectInfo.ContentRange = fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, header.size)
+	return newDecryptStream(reader, header, aead, first, start-first*header.chunkSize, length), objectInfo, nil
+}
+
+// StatObject 读取对象头部，返回明文的长度与类型
+func (p *EncryptedProvider) StatObject(ctx context.Context, objectKey string, options GetObjectOptions) (*GetObjectInfo, error) {
+	header, _, info, err := p.probe(ctx, objectKey, GetObjectOptions{Conditions: options.Conditions, VersionID: options.VersionID})
+	if err != nil {
+		return nil, err
+	}
+	return header.objectInfo(info), nil
+}
+
+// GetFileList 获取文件列表，对象大小与类型取自加密头部。
+// 每个对象都需读取一次头部（S3 等为一次范围请求），以有限的并发进行；列出后被删除的对象不返回，
+// 其他读取失败时返回错误而不是密文大小。启用对象索引后列表由索引提供，不再经过这里。
+func (p *EncryptedProvider) GetFileList(ctx context.Context, prefix string) ([]FileListElement, error) {
+	list, err := p.StorageProvider.GetFileList(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	errs := make([]error, len(list))
+	slots := make(chan struct{}, encryptionProbeConcurrency)
+	var wg sync.WaitGroup
+	for i := range list {
+		if list[i].Type == "dir" {
+			continue
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			header, _, _, err := p.probe(ctx, list[i].ObjectKey, GetObjectOptions{})
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			list[i].Size = header.size
+			list[i].Type = classifyMIME(header.contentType)
+		}()
+	}
+	wg.Wait()
+
+	result := list[:0]
+	for i, element := range list {
+		switch {
+		case errs[i] == nil:
+			result = append(result, element)
+		case errors.Is(errs[i], ErrResourceNotExists):
+		default:
+			return nil, fmt.Errorf("read encryption header of %s: %w", element.ObjectKey, errs[i])
+		}
+	}
+	return result, nil
+}
+
+// probe 读取对象开头的加密头部，不可随机读取的底层只请求头部所在范围
+func (p *EncryptedProvider) probe(ctx context.Context, objectKey string, options GetObjectOptions) (*encryptionHeader, cipher.AEAD, *GetObjectInfo, error) {
+	options.Range = fmt.Sprintf("bytes=0-%d", maxEncryptionHeaderSize-1)
+	reader, info, err := p.StorageProvider.GetObject(ctx, objectKey, options)
+	if errors.Is(err, ErrInvalidRange) {
+		return nil, nil, nil, ErrDecryptionFailed
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer func() { _ = reader.Close() }()
+	header, aead, err := p.openHeader(reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return header, aead, info, nil
+}
+
+// openHeader 读取头部并解封数据密钥
+func (p *EncryptedProvider) openHeader(reader io.Reader) (*encryptionHeader, cipher.AEAD, error) {
+	header, err := readEncryptionHeader(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	dataKey, err := p.unwrapKey(header)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, aead, nil
+}
+
+// wrapKey 以主密钥封装数据密钥并写入头部
+func (p *EncryptedProvider) wrapKey(header *encryptionHeader, dataKey []byte) error {
+	p.mu.RLock()
+	keyID, key := p.keys.primary, p.keys.keys[p.keys.primary]
+	p.mu.RUnlock()
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	header.keyID = keyID
+	header.nonce = nonce
+	header.wrappedKey = aead.Seal(nil, nonce, dataKey, header.keyAAD())
+	return nil
+}
+
+func (p *EncryptedProvider) unwrapKey(header *encryptionHeader) ([]byte, error) {
+	p.mu.RLock()
+	key, ok := p.keys.keys[header.keyID]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrEncryptionKeyNotFound, header.keyID)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := aead.Open(nil, header.nonce, header.wrappedKey, header.keyAAD())
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return dataKey, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// parseByteRange 解析单段 Range 头，返回起始位置与长度
+func parseByteRange(value string, size int64) (int64, int64, bool) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(value), "bytes=")
+	if !ok {
+		return 0, 0, false
+	}
+	startText, endText, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false
+	}
+	if startText == "" {
+		suffix, err := strconv.ParseInt(endText, 10, 64)
+		if err != nil || suffix <= 0 || size == 0 {
+			return 0, 0, false
+		}
+		suffix = min(suffix, size)
+		return size - suffix, suffix, true
+	}
+	start, err := strconv.ParseInt(startText, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if endText != "" {
+		if end, err = strconv.ParseInt(endText, 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+		end = min(end, size-1)
+	}
+	return start, end - start + 1, true
+}
@@ -0,0 +1,222 @@
+package oss_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cube-go/pkg/oss"
+	"cube-go/pkg/oss/osstest"
+)
+
+// writeKeyFile 写入密钥文件，密钥内容由 ID 派生
+func writeKeyFile(t *testing.T, name, primary string, ids ...string) {
+	t.Helper()
+	keys := make(map[string]string, len(ids))
+	for _, id := range ids {
+		keys[id] = base64.StdEncoding.EncodeToString([]byte(strings.Repeat(id, 32)[:32]))
+	}
+	data, err := json.Marshal(map[string]any{"primary": primary, "keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newEncryptedProvider(t *testing.T, inner oss.StorageProvider) *oss.EncryptedProvider {
+	t.Helper()
+	keyFile := filepath.Join(t.TempDir(), "keys.json")
+	writeKeyFile(t, keyFile, "a", "a")
+	p, err := oss.NewEncryptedProviderWithKeyFile(inner, keyFile, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+	return p
+}
+
+func TestEncryptedMemoryConformance(t *testing.T) {
+	osstest.Run(t, func(t *testing.T) oss.StorageProvider {
+		return newEncryptedProvider(t, oss.NewMemoryStorageProvider(oss.MemoryStorageOptions{}))
+	})
+}
+
+func TestEncryptedLocalConformance(t *testing.T) {
+	osstest.Run(t, func(t *testing.T) oss.StorageProvider {
+		return newEncryptedProvider(t, newLocalProvider(t, oss.LocalStorageOptions{Metadata: "bolt"}))
+	})
+}
+
+func TestEncryptionHeaderTamper(t *testing.T) {
+	ctx := context.Background()
+	local, root := newLocalProviderDir(t, oss.LocalStorageOptions{Metadata: "bolt"})
+	p := newEncryptedProvider(t, local)
+	if err := p.SaveObject(ctx, strings.NewReader("secret"), "a.txt", oss.SaveObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(root, "a.txt")
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 明文长度位于 magic、头部长度与分段大小之后
+	data[8+2+4+7]++
+	if err := os.WriteFile(name, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.StatObject(ctx, "a.txt", oss.GetObjectOptions{}); !errors.Is(err, oss.ErrDecryptionFailed) {
+		t.Errorf("StatObject after tampering with the size = %v, want ErrDecryptionFailed", err)
+	}
+	if _, err := p.GetFileList(ctx, ""); !errors.Is(err, oss.ErrDecryptionFailed) {
+		t.Errorf("GetFileList after tampering with the size = %v, want ErrDecryptionFailed", err)
+	}
+}
+
+func TestEncryptionLayout(t *testing.T) {
+	cases := []struct {
+		size, chunks, cipherSize, lastOffset, lastLength int64
+	}{
+		{size: 0, chunks: 0, cipherSize: 100},
+		{size: 1, chunks: 1, cipherSize: 117, lastOffset: 100, lastLength: 1},
+		{size: 1024, chunks: 1, cipherSize: 1140, lastOffset: 100, lastLength: 1024},
+		{size: 1025, chunks: 2, cipherSize: 1157, lastOffset: 1140, lastLength: 1},
+		{size: 3000, chunks: 3, cipherSize: 3148, lastOffset: 2180, lastLength: 952},
+	}
+	for _, c := range cases {
+		chunks, cipherSize, offset, length := oss.EncryptionLayout(100, 1024, c.size)
+		if chunks != c.chunks || cipherSize != c.cipherSize {
+			t.Errorf("size %d: chunks, cipherSize = %d, %d, want %d, %d", c.size, chunks, cipherSize, c.chunks, c.cipherSize)
+		}
+		if chunks == 0 {
+			continue
+		}
+		if got := offset(chunks - 1); got != c.lastOffset {
+			t.Errorf("size %d: last chunk offset = %d, want %d", c.size, got, c.lastOffset)
+		}
+		if got := length(chunks - 1); got != c.lastLength {
+			t.Errorf("size %d: last chunk length = %d, want %d", c.size, got, c.lastLength)
+		}
+		if end := offset(chunks-1) + length(chunks-1) + 16; end != cipherSize {
+			t.Errorf("size %d: last chunk ends at %d, want %d", c.size, end, cipherSize)
+		}
+	}
+}
+
+func TestParseByteRange(t *testing.T) {
+	cases := []struct {
+		value         string
+		size          int64
+		start, length int64
+		ok            bool
+	}{
+		{"bytes=0-99", 1000, 0, 100, true},
+		{"bytes=900-", 1000, 900, 100, true},
+		{"bytes=900-5000", 1000, 900, 100, true},
+		{"bytes=-100", 1000, 900, 100, true},
+		{"bytes=-5000", 1000, 0, 1000, true},
+		{"bytes=999-999", 1000, 999, 1, true},
+		{"bytes=1000-", 1000, 0, 0, false},
+		{"bytes=5-4", 1000, 0, 0, false},
+		{"bytes=-0", 1000, 0, 0, false},
+		{"bytes=-1", 0, 0, 0, false},
+		{"bytes=0-1,3-4", 1000, 0, 0, false},
+		{"items=0-1", 1000, 0, 0, false},
+	}
+	for _, c := range cases {
+		start, length, ok := oss.ParseByteRange(c.value, c.size)
+		if ok != c.ok || ok && (start != c.start || length != c.length) {
+			t.Errorf("ParseByteRange(%q, %d) = %d, %d, %v, want %d, %d, %v", c.value, c.size, start, length, ok, c.start, c.length, c.ok)
+		}
+	}
+}
+
+func rotate(t *testing.T, p *oss.EncryptedProvider) *oss.RotateReport {
+	t.Helper()
+	done := make(chan *oss.RotateReport, 1)
+	err := p.StartRotate(func(report *oss.RotateReport, err error) {
+		if err != nil {
+			t.Errorf("rotate: %v", err)
+		}
+		done <- report
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return <-done
+}
+
+func TestEncryptionRotatePreservesMetadata(t *testing.T) {
+	ctx := context.Background()
+	keyFile := filepath.Join(t.TempDir(), "keys.json")
+	writeKeyFile(t, keyFile, "a", "a")
+	local := newLocalProvider(t, oss.LocalStorageOptions{Metadata: "bolt", Versioning: true})
+	p, err := oss.NewEncryptedProviderWithKeyFile(local, keyFile, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := strings.Repeat("rotate me ", 300)
+	err = p.SaveObject(ctx, strings.NewReader(content), "doc.txt", oss.SaveObjectOptions{
+		OriginalName: "Doc.txt",
+		Uploader:     "alice",
+		Metadata:     map[string]string{"author": "alice"},
+		Tags:         map[string]string{"env": "test"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := oss.LocalMetadata(local, "doc.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, err := local.StatObject(ctx, "doc.txt", oss.GetObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 新密钥 ID 长度不同，轮换后头部长度随之改变
+	writeKeyFile(t, keyFile, "key-b", "a", "key-b")
+	if report := rotate(t, p); report.Rewrapped != 1 || len(report.Failed) != 0 {
+		t.Fatalf("first rotation = %+v, want one rewrapped object", report)
+	}
+	if report := rotate(t, p); report.Rewrapped != 0 || report.Current != 1 {
+		t.Fatalf("second rotation = %+v, want the object already current", report)
+	}
+
+	after, err := oss.LocalMetadata(local, "doc.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.OriginalName != "Doc.txt" || after.Uploader != "alice" || !after.UploadedAt.Equal(before.UploadedAt) ||
+		after.Custom["author"] != "alice" || after.Tags["env"] != "test" {
+		t.Errorf("metadata after rotation = %+v, want %+v", after, before)
+	}
+	if after.SHA256 == before.SHA256 {
+		t.Error("ciphertext checksum unchanged, header was not rewritten")
+	}
+	rotated, err := local.StatObject(ctx, "doc.txt", oss.GetObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rotated.LastModified.Equal(stat.LastModified) {
+		t.Errorf("LastModified = %v, want %v", rotated.LastModified, stat.LastModified)
+	}
+	if versions, err := local.ListVersions(ctx, "doc.txt"); err != nil || len(versions) != 1 {
+		t.Errorf("ListVersions = %v, %v, want no version created by rotation", versions, err)
+	}
+
+	// 只保留新密钥后仍能解密
+	writeKeyFile(t, keyFile, "key-b", "key-b")
+	if report := rotate(t, p); len(report.Failed) != 0 {
+		t.Fatalf("rotation with only the new key = %+v", report)
+	}
+	if got, err := readObject(t, p, "doc.txt"); err != nil || got != content {
+		t.Errorf("content after rotation = %q, %v", got, err)
+	}
+}
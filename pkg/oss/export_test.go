@@ -0,0 +1,78 @@
+package oss
+
+import "context"
+
+// NewQuotaProviderWithLimits 供测试按对象数与容量（MB）上限创建配额存储提供者
+func NewQuotaProviderWithLimits(ctx context.Context, provider StorageProvider, maxSizeMB, maxObjects int64) (*QuotaProvider, error) {
+	return NewQuotaProvider(ctx, provider, quotaConfig{MaxSize: maxSizeMB, MaxObjects: maxObjects})
+}
+
+// MirrorOptions 供测试按复制方式创建镜像配置
+func MirrorOptions(mode string) mirrorConfig {
+	return mirrorConfig{Mode: mode}
+}
+
+// MirrorOptionsDeleteExtra 供测试创建校准时删除多余对象的镜像配置
+func MirrorOptionsDeleteExtra(mode string) mirrorConfig {
+	return mirrorConfig{Mode: mode, DeleteExtra: true}
+}
+
+// CompositeMembers 供测试校验镜像、分层存储桶的成员配置
+func CompositeMembers(mirrors map[string][]string, tiers map[string][2]string) (map[string]string, error) {
+	return compositeMembers(compositeConfigs(mirrors, tiers))
+}
+
+// CreationOrder 供测试返回存储桶的创建顺序，plain 为普通存储桶
+func CreationOrder(mirrors map[string][]string, tiers map[string][2]string, plain ...string) []string {
+	cfgList := compositeConfigs(mirrors, tiers)
+	for _, name := range plain {
+		cfgList = append(cfgList, bucketConfigElement{Name: name, Type: "memory"})
+	}
+	var names []string
+	for _, c := range sortByDependency(cfgList) {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func compositeConfigs(mirrors map[string][]string, tiers map[string][2]string) []bucketConfigElement {
+	var cfgList []bucketConfigElement
+	for name, replicas := range mirrors {
+		cfgList = append(cfgList, bucketConfigElement{Name: name, Type: "mirror", Mirror: mirrorConfig{Replicas: replicas}})
+	}
+	for name, tier := range tiers {
+		cfgList = append(cfgList, bucketConfigElement{Name: name, Type: "tiered", Tiered: tieredConfig{Hot: tier[0], Cold: tier[1]}})
+	}
+	return cfgList
+}
+
+// NewEncryptedProviderWithKeyFile 供测试按密钥文件与分段大小（KB）创建加密存储提供者
+func NewEncryptedProviderWithKeyFile(provider StorageProvider, keyFile string, chunkSizeKB int64) (*EncryptedProvider, error) {
+	return NewEncryptedProvider(provider, encryptionConfig{KeyFile: keyFile, ChunkSize: chunkSizeKB})
+}
+
+// LocalMetadata 供测试读取本地存储中对象的完整元数据
+func LocalMetadata(p *LocalStorageProvider, key string) (*ObjectMetadata, error) {
+	return p.meta.Get(key)
+}
+
+// ParseByteRange 供测试解析单段 Range 头
+var ParseByteRange = parseByteRange
+
+// EncryptionLayout 供测试计算加密对象的分段布局
+func EncryptionLayout(headerSize, chunkSize, size int64) (chunks, cipherSize int64, chunkOffset, chunkLength func(int64) int64) {
+	h := &encryptionHeader{chunkSize: chunkSize, size: size, raw: make([]byte, headerSize)}
+	return h.chunks(), h.cipherSize(), h.chunkOffset, h.chunkLength
+}
+
+// NewCachedProviderDir 供测试在指定目录创建读取缓存
+func NewCachedProviderDir(provider StorageProvider, dir string) (*CachedProvider, error) {
+	return NewCachedProvider(provider, cacheConfig{Dir: dir, MaxSize: 16})
+}
+
+// SetSearchCandidateLimit 供测试修改未建立索引时每次搜索检查的候选数，返回恢复原值的函数
+func SetSearchCandidateLimit(n int) func() {
+	previous := searchCandidateLimit
+	searchCandidateLimit = n
+	return func() { searchCandidateLimit = previous }
+}
@@ -0,0 +1,574 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrIndexDisabled 存储桶未启用对象索引
+	ErrIndexDisabled = errors.New("object index disabled")
+	// ErrIndexRebuilding 对象索引正在重建
+	ErrIndexRebuilding = errors.New("object index is rebuilding")
+)
+
+const (
+	indexRebuildBatch      = 256 // 全量重建时每个写事务写入的条目数
+	indexLookupConcurrency = 8   // 全量重建时同时读取的对象数
+)
+
+// IndexStatus 存储桶的对象索引状态
+type IndexStatus struct {
+	Ready      bool      `json:"ready"` // 索引已建立，列表与遍历由索引响应
+	Rebuilding bool      `json:"rebuilding"`
+	Objects    int       `json:"objects"`
+	BuiltAt    time.Time `json:"built_at"`
+	LastError  string    `json:"last_error,omitempty"` // 最近一次重建失败的原因
+}
+
+// changeNotifier 嵌入在内部修改对象的存储提供者中（密钥轮换、巡检修复、分层迁移等），
+// 这些修改不经过外层的 IndexedProvider，由此通知其更新索引
+type changeNotifier struct {
+	mu    sync.Mutex
+	hooks []func(key string)
+}
+
+// changeSource 由嵌入 changeNotifier 的存储提供者实现
+type changeSource interface {
+	onChange(fn func(key string))
+}
+
+func (n *changeNotifier) onChange(fn func(key string)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.hooks = append(n.hooks, fn)
+}
+
+// notifyChange 在对象被内部修改后调用
+func (n *changeNotifier) notifyChange(key string) {
+	n.mu.Lock()
+	hooks := n.hooks
+	n.mu.Unlock()
+	for _, fn := range hooks {
+		fn(key)
+	}
+}
+
+// IndexedProvider 在存储提供者外层维护对象索引。
+// 经本提供者的写入、删除、元数据修改与还原在成功后更新索引，内层的内部修改经 changeNotifier 通知；
+// 其他途径（如直接修改后端）的修改由全量重建修正。
+// 索引建立后，列表与递归遍历直接查询索引，不再访问后端。
+type IndexedProvider struct {
+	StorageProvider
+	index  *ObjectIndex
+	bucket string
+	ready  atomic.Bool
+
+	mu      sync.Mutex
+	done    chan struct{}   // 重建期间非空，重建结束时关闭
+	dirty   map[string]bool // 重建期间发生变更的对象键，值表示是否需要连同其下的对象一起刷新
+	lastErr error
+}
+
+// NewIndexedProvider 创建维护对象索引的存储提供者，bucket 为索引中的存储桶名称
+func NewIndexedProvider(provider StorageProvider, index *ObjectIndex, bucket string) (*IndexedProvider, error) {
+	p := &IndexedProvider{StorageProvider: provider, index: index, bucket: bucket}
+	_, builtAt, err := index.stat(bucket)
+	if err != nil {
+		return nil, err
+	}
+	p.ready.Store(!builtAt.IsZero())
+	for inner := provider; inner != nil; {
+		if source, ok := inner.(changeSource); ok {
+			source.onChange(func(key string) {
+				p.refresh(context.Background(), key, false)
+			})
+		}
+		wrapper, ok := inner.(interface{ Unwrap() StorageProvider })
+		if !ok {
+			break
+		}
+		inner = wrapper.Unwrap()
+	}
+	return p, nil
+}
+
+func (p *IndexedProvider) Unwrap() StorageProvider {
+	return p.StorageProvider
+}
+
+func (p *IndexedProvider) Close() error {
+	if closer, ok := p.StorageProvider.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Bucket 返回索引中的存储桶名称
+func (p *IndexedProvider) Bucket() string {
+	return p.bucket
+}
+
+func (p *IndexedProvider) SaveObject(ctx context.Context, reader io.ReadSeeker, objectKey string, options SaveObjectOptions) error {
+	if err := p.StorageProvider.SaveObject(ctx, reader, objectKey, options); err != nil {
+		return err
+	}
+	if key, _, err := NormalizeObjectKey(objectKey, false); err == nil {
+		p.refresh(context.WithoutCancel(ctx), key, false)
+	}
+	return nil
+}
+
+func (p *IndexedProvider) DeleteObject(ctx context.Context, objectKey string) error {
+	if err := p.StorageProvider.DeleteObject(ctx, objectKey); err != nil {
+		return err
+	}
+	if key, _, err := NormalizeObjectKey(objectKey, true); err == nil {
+		p.markDirty(key, true)
+		if err := p.index.remove(indexBucketsBucket, p.bucket, key); err != nil {
+			zap.L().Warn("更新对象索引失败", zap.String("bucket", p.bucket), zap.String("key", key), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// GetFileList 索引建立后由索引列出，否则交由后端
+func (p *IndexedProvider) GetFileList(ctx context.Context, prefix string) ([]FileListElement, error) {
+	if !p.ready.Load() {
+		return p.StorageProvider.GetFileList(ctx, prefix)
+	}
+	key, _, err := NormalizeObjectKey(prefix, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return p.index.list(p.bucket, key)
+}
+
+func (p *IndexedProvider) walkObjects(ctx context.Context, prefix string, fn func(ObjectEntry) error) error {
+	if !p.ready.Load() {
+		return WalkObjects(ctx, p.StorageProvider, prefix, fn)
+	}
+	key, _, err := NormalizeObjectKey(prefix, true)
+	if err != nil {
+		return err
+	}
+	return p.index.Scan(ctx, p.bucket, key, func(entry IndexEntry) error {
+		return fn(ObjectEntry{Key: entry.Key, Size: entry.Size, LastModified: entry.LastModified})
+	})
+}
+
+func (p *IndexedProvider) GetMetadata(ctx context.Context, objectKey string) (*UserMetadata, error) {
+	metadata, ok := As[MetadataProvider](p.StorageProvider)
+	if !ok {
+		return nil, ErrMetadataNotSupported
+	}
+	return metadata.GetMetadata(ctx, objectKey)
+}
+
+func (p *IndexedProvider) UpdateMetadata(ctx context.Context, objectKey string, update UserMetadata) error {
+	metadata, ok := As[MetadataProvider](p.StorageProvider)
+	if !ok {
+		return ErrMetadataNotSupported
+	}
+	if err := metadata.UpdateMetadata(ctx, objectKey, update); err != nil {
+		return err
+	}
+	if key, _, err := NormalizeObjectKey(objectKey, false); err == nil {
+		p.refresh(context.WithoutCancel(ctx), key, false)
+	}
+	return nil
+}
+
+func (p *IndexedProvider) TrashEnabled() bool {
+	trash, ok := As[TrashProvider](p.StorageProvider)
+	return ok && trash.TrashEnabled()
+}
+
+func (p *IndexedProvider) ListTrash(ctx context.Context) ([]TrashItem, error) {
+	trash, ok := As[TrashProvider](p.StorageProvider)
+	if !ok {
+		return nil, ErrTrashDisabled
+	}
+	return trash.ListTrash(ctx)
+}
+
+// RestoreTrash 还原回收站条目后刷新还原出的对象，条目可能是整个目录
+func (p *IndexedProvider) RestoreTrash(ctx context.Context, id string) (*TrashItem, error) {
+	trash, ok := As[TrashProvider](p.StorageProvider)
+	if !ok {
+		return nil, ErrTrashDisabled
+	}
+	item, err := trash.RestoreTrash(ctx, id)
+	if err != nil {
+		return item, err
+	}
+	if key, _, err := NormalizeObjectKey(item.ObjectKey, false); err == nil {
+		p.refresh(context.WithoutCancel(ctx), key, true)
+	}
+	return item, nil
+}
+
+// PurgeTrash 永久删除只影响回收站，不改变现有对象，无需更新索引
+func (p *IndexedProvider) PurgeTrash(ctx context.Context, id string) error {
+	trash, ok := As[TrashProvider](p.StorageProvider)
+	if !ok {
+		return ErrTrashDisabled
+	}
+	return trash.PurgeTrash(ctx, id)
+}
+
+func (p *IndexedProvider) VersioningEnabled() bool {
+	versions, ok := As[VersionProvider](p.StorageProvider)
+	return ok && versions.VersioningEnabled()
+}
+
+func (p *IndexedProvider) ListVersions(ctx context.Context, objectKey string) ([]ObjectVersion, error) {
+	versions, ok := As[VersionProvider](p.StorageProvider)
+	if !ok {
+		return nil, ErrVersioningDisabled
+	}
+	return versions.ListVersions(ctx, objectKey)
+}
+
+func (p *IndexedProvider) RestoreVersion(ctx context.Context, objectKey string, versionID string) error {
+	versions, ok := As[VersionProvider](p.StorageProvider)
+	if !ok {
+		return ErrVersioningDisabled
+	}
+	if err := versions.RestoreVersion(ctx, objectKey, versionID); err != nil {
+		return err
+	}
+	if key, _, err := NormalizeObjectKey(objectKey, false); err == nil {
+		p.refresh(context.WithoutCancel(ctx), key, false)
+	}
+	return nil
+}
+
+// DeleteVersion 删除历史版本，原生多版本下删除最新版本会改变当前对象，因此同样刷新索引
+func (p *IndexedProvider) DeleteVersion(ctx context.Context, objectKey string, versionID string) error {
+	versions, ok := As[VersionProvider](p.StorageProvider)
+	if !ok {
+		return ErrVersioningDisabled
+	}
+	if err := versions.DeleteVersion(ctx, objectKey, versionID); err != nil {
+		return err
+	}
+	if key, _, err := NormalizeObjectKey(objectKey, false); err == nil {
+		p.refresh(context.WithoutCancel(ctx), key, false)
+	}
+	return nil
+}
+
+// Status 返回索引状态
+func (p *IndexedProvider) Status() (IndexStatus, error) {
+	objects, builtAt, err := p.index.stat(p.bucket)
+	if err != nil {
+		return IndexStatus{}, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status := IndexStatus{
+		Ready:      p.ready.Load(),
+		Rebuilding: p.done != nil,
+		Objects:    objects,
+		BuiltAt:    builtAt,
+	}
+	if p.lastErr != nil {
+		status.LastError = p.lastErr.Error()
+	}
+	return status, nil
+}
+
+// Rebuild 全量遍历后端重建索引，已有重建正在进行时返回 ErrIndexRebuilding。
+// 遍历期间经本提供者发生的变更会被记录，并在替换索引前重新读取。
+func (p *IndexedProvider) Rebuild(ctx context.Context) (err error) {
+	p.mu.Lock()
+	if p.done != nil {
+		p.mu.Unlock()
+		return ErrIndexRebuilding
+	}
+	done := make(chan struct{})
+	p.done = done
+	p.dirty = make(map[string]bool)
+	p.mu.Unlock()
+	defer func() {
+		if err != nil {
+			if abortErr := p.index.abortRebuild(p.bucket); abortErr != nil {
+				zap.L().Warn("清理对象索引重建区失败", zap.String("bucket", p.bucket), zap.Error(abortErr))
+			}
+		}
+		p.mu.Lock()
+		p.done = nil
+		p.dirty = nil
+		p.lastErr = err
+		p.mu.Unlock()
+		close(done)
+	}()
+
+	start := time.Now()
+	if err := p.index.beginRebuild(p.bucket); err != nil {
+		return err
+	}
+	keys := make([]string, 0, indexRebuildBatch)
+	flush := func() error {
+		entries, err := p.lookupAll(ctx, keys)
+		if err != nil {
+			return err
+		}
+		keys = keys[:0]
+		return p.index.put(indexRebuildBucket, p.bucket, entries...)
+	}
+	err = WalkObjects(ctx, p.StorageProvider, "", func(object ObjectEntry) error {
+		keys = append(keys, object.Key)
+		if len(keys) < indexRebuildBatch {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	// 补上遍历期间的变更，最后一轮持有锁，使替换索引前不再有遗漏的变更
+	for {
+		p.mu.Lock()
+		dirty := p.dirty
+		if len(dirty) == 0 {
+			break
+		}
+		p.dirty = make(map[string]bool)
+		p.mu.Unlock()
+		for key, tree := range dirty {
+			if err := p.apply(ctx, indexRebuildBucket, key, tree); err != nil {
+				return err
+			}
+		}
+	}
+	err = p.index.commitRebuild(p.bucket, time.Now())
+	if err == nil {
+		p.ready.Store(true)
+	}
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	zap.L().Info("对象索引重建完成", zap.String("bucket", p.bucket), zap.Duration("elapsed", time.Since(start)))
+	return nil
+}
+
+// Wait 等待正在进行的重建结束，返回其结果；没有重建时立即返回最近一次重建的结果
+func (p *IndexedProvider) Wait(ctx context.Context) error {
+	p.mu.Lock()
+	done := p.done
+	p.mu.Unlock()
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastErr
+}
+
+// markDirty 在重建期间记录发生变更的对象键
+func (p *IndexedProvider) markDirty(key string, tree bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.dirty != nil {
+		p.dirty[key] = p.dirty[key] || tree
+	}
+}
+
+// refresh 从后端重新读取对象并更新索引，tree 为真时连同其下的对象一起刷新。
+// 索引更新失败不影响已成功的操作，只记录日志，由下次重建修正。
+func (p *IndexedProvider) refresh(ctx context.Context, key string, tree bool) {
+	p.markDirty(key, tree)
+	if err := p.apply(ctx, indexBucketsBucket, key, tree); err != nil {
+		zap.L().Warn("更新对象索引失败", zap.String("bucket", p.bucket), zap.String("key", key), zap.Error(err))
+	}
+}
+
+// apply 按后端的当前状态更新 root 下的索引条目
+func (p *IndexedProvider) apply(ctx context.Context, root []byte, key string, tree bool) error {
+	entry, err := p.lookup(ctx, key)
+	if err == nil {
+		if tree {
+			// 对象键可能曾是目录
+			if err := p.index.remove(root, p.bucket, key); err != nil {
+				return err
+			}
+		}
+		return p.index.put(root, p.bucket, *entry)
+	}
+	if !errors.Is(err, ErrResourceNotExists) && !errors.Is(err, ErrInvalidObjectKey) {
+		return err
+	}
+	if err := p.index.remove(root, p.bucket, key); err != nil {
+		return err
+	}
+	if !tree {
+		return nil
+	}
+	prefix := key
+	if prefix != "" {
+		prefix += "/"
+	}
+	var keys []string
+	err = WalkObjects(ctx, p.StorageProvider, prefix, func(object ObjectEntry) error {
+		keys = append(keys, object.Key)
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrResourceNotExists) && !errors.Is(err, ErrPathIsNotDir) {
+		return err
+	}
+	entries, err := p.lookupAll(ctx, keys)
+	if err != nil {
+		return err
+	}
+	return p.index.put(root, p.bucket, entries...)
+}
+
+// lookup 从后端读取对象的索引条目
+func (p *IndexedProvider) lookup(ctx context.Context, key string) (*IndexEntry, error) {
+	return statIndexEntry(ctx, p.StorageProvider, p.bucket, key)
+}
+
+// lookupAll 并发读取一批对象的索引条目，跳过期间被删除的对象
+func (p *IndexedProvider) lookupAll(ctx context.Context, keys []string) ([]IndexEntry, error) {
+	entries := make([]*IndexEntry, len(keys))
+	errs := make([]error, len(keys))
+	slots := make(chan struct{}, indexLookupConcurrency)
+	var wg sync.WaitGroup
+	for i := range keys {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			entries[i], errs[i] = p.lookup(ctx, keys[i])
+		}()
+	}
+	wg.Wait()
+
+	result := make([]IndexEntry, 0, len(keys))
+	for i, entry := range entries {
+		switch {
+		case errs[i] == nil:
+			result = append(result, *entry)
+		case errors.Is(errs[i], ErrResourceNotExists):
+		default:
+			return nil, errs[i]
+		}
+	}
+	return result, nil
+}
+
+// statIndexEntry 读取对象信息与标签，组成索引条目
+func statIndexEntry(ctx context.Context, p StorageProvider, bucket string, key string) (*IndexEntry, error) {
+	info, err := p.StatObject(ctx, key, GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	entry := &IndexEntry{
+		Bucket:       bucket,
+		Key:          key,
+		Size:         info.ContentLength,
+		ContentType:  info.ContentType,
+		SHA256:       info.SHA256,
+		LastModified: info.LastModified,
+		Metadata:     info.Metadata,
+	}
+	if metadata, ok := As[MetadataProvider](p); ok {
+		current, err := metadata.GetMetadata(ctx, key)
+		if err != nil && !errors.Is(err, ErrMetadataNotSupported) {
+			return nil, err
+		}
+		if current != nil {
+			if current.Metadata != nil {
+				entry.Metadata = current.Metadata
+			}
+			entry.Tags = current.Tags
+		}
+	}
+	return entry, nil
+}
+
+// indexBuilder 在索引尚未建立时重建，并按间隔定期全量重建
+type indexBuilder struct {
+	providers []*IndexedProvider
+	interval  time.Duration
+	stop      chan struct{}
+	ctx       context.Context // 重建使用，关闭时取消
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+func startIndexBuilder(providers []*IndexedProvider, intervalHours int) *indexBuilder {
+	b := &indexBuilder{
+		providers: providers,
+		interval:  time.Duration(intervalHours) * time.Hour,
+		stop:      make(chan struct{}),
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	b.wg.Add(1)
+	go b.run()
+	return b
+}
+
+func (b *indexBuilder) run() {
+	defer b.wg.Done()
+	b.rebuild(true)
+	if b.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			b.rebuild(false)
+		}
+	}
+}
+
+// rebuild 依次重建各存储桶的索引，missingOnly 为真时跳过已建立的索引
+func (b *indexBuilder) rebuild(missingOnly bool) {
+	for _, provider := range b.providers {
+		if b.ctx.Err() != nil {
+			return
+		}
+		if missingOnly && provider.ready.Load() {
+			continue
+		}
+		err := provider.Rebuild(b.ctx)
+		if err != nil && !errors.Is(err, ErrIndexRebuilding) && !errors.Is(err, context.Canceled) {
+			zap.L().Error("对象索引重建失败", zap.String("bucket", provider.bucket), zap.Error(err))
+		}
+	}
+}
+
+func (b *indexBuilder) Close() error {
+	close(b.stop)
+	b.cancel()
+	b.wg.Wait()
+	return nil
+}
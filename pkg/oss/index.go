@@ -0,0 +1,362 @@
+package oss
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	berrors "go.etcd.io/bbolt/errors"
+)
+
+type indexConfig struct {
+	Enabled         bool   `mapstructure:"enabled"`
+	File            string `mapstructure:"file"`            // 索引数据库文件，默认 ./index/objects.db
+	RebuildInterval int    `mapstructure:"rebuildInterval"` // 定期全量重建间隔，单位小时，0 表示只在索引尚未建立时重建
+}
+
+// IndexEntry 对象索引条目
+type IndexEntry struct {
+	Bucket       string            `json:"bucket"`
+	Key          string            `json:"key"`
+	Size         int64             `json:"size"`
+	ContentType  string            `json:"content_type"`
+	SHA256       string            `json:"sha256,omitempty"`
+	LastModified time.Time         `json:"last_modified"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+}
+
+func (e *IndexEntry) fileListElement() FileListElement {
+	return FileListElement{
+		Name:         path.Base(e.Key),
+		Size:         e.Size,
+		Type:         classifyMIME(e.ContentType),
+		LastModified: e.LastModified.Format(time.RFC3339),
+		ObjectKey:    e.Key,
+		Metadata:     e.Metadata,
+		Tags:         e.Tags,
+	}
+}
+
+var (
+	indexBucketsBucket = []byte("buckets")
+	indexRebuildBucket = []byte("rebuild")
+	indexObjectsBucket = []byte("objects")
+	indexBuiltAtKey    = []byte("built_at")
+)
+
+// ErrDatabaseLocked 嵌入式数据库（对象索引、本地元数据、分层状态）已被其他进程打开，通常是服务正在运行
+var ErrDatabaseLocked = errors.New("database is in use by another process")
+
+// openBolt 打开嵌入式数据库，等待文件锁超时时返回 ErrDatabaseLocked
+func openBolt(file string, mode os.FileMode) (*bolt.DB, error) {
+	db, err := bolt.Open(file, mode, &bolt.Options{Timeout: 5 * time.Second})
+	if errors.Is(err, berrors.ErrTimeout) {
+		return nil, fmt.Errorf("%s: %w", file, ErrDatabaseLocked)
+	}
+	return db, err
+}
+
+// indexScanBatch 遍历时每个只读事务读取的条目数，回调在事务之外执行
+const indexScanBatch = 512
+
+// ObjectIndex 保存在嵌入式数据库中的对象索引，所有存储桶共用一个数据库文件。
+// buckets/<桶名>/objects 保存对象键到条目的映射；全量重建写入 rebuild/<桶名>/objects，
+// 完成后在同一事务内替换，重建期间查询仍使用旧索引。
+type ObjectIndex struct {
+	db *bolt.DB
+}
+
+// OpenObjectIndex 打开对象索引数据库，文件不存在时创建
+func OpenObjectIndex(file string) (*ObjectIndex, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0750); err != nil {
+		return nil, err
+	}
+	db, err := openBolt(file, 0640)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(indexBucketsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(indexRebuildBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &ObjectIndex{db: db}, nil
+}
+
+func (ix *ObjectIndex) Close() error {
+	return ix.db.Close()
+}
+
+// indexObjects 返回存储桶的对象表，root 为 buckets 或 rebuild，不存在时返回 nil
+func indexObjects(tx *bolt.Tx, root []byte, bucket string) *bolt.Bucket {
+	b := tx.Bucket(root).Bucket([]byte(bucket))
+	if b == nil {
+		return nil
+	}
+	return b.Bucket(indexObjectsBucket)
+}
+
+func createIndexObjects(tx *bolt.Tx, root []byte, bucket string) (*bolt.Bucket, error) {
+	b, err := tx.Bucket(root).CreateBucketIfNotExists([]byte(bucket))
+	if err != nil {
+		return nil, err
+	}
+	return b.CreateBucketIfNotExists(indexObjectsBucket)
+}
+
+// put 写入索引条目
+func (ix *ObjectIndex) put(root []byte, bucket string, entries ...IndexEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return ix.db.Update(func(tx *bolt.Tx) error {
+		objects, err := createIndexObjects(tx, root, bucket)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			if err := objects.Put([]byte(entry.Key), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// remove 删除对象键及其下的所有条目，key 为空时清空存储桶的索引
+func (ix *ObjectIndex) remove(root []byte, bucket string, key string) error {
+	return ix.db.Update(func(tx *bolt.Tx) error {
+		objects := indexObjects(tx, root, bucket)
+		if objects == nil {
+			return nil
+		}
+		prefix := []byte(key + "/")
+		if key == "" {
+			prefix = nil
+		} else if err := objects.Delete([]byte(key)); err != nil {
+			return err
+		}
+		// 游标遍历期间删除会跳过条目，先收集再删除
+		var keys [][]byte
+		c := objects.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			keys = append(keys, bytes.Clone(k))
+		}
+		for _, k := range keys {
+			if err := objects.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Scan 按对象键顺序遍历前缀下的索引条目，prefix 为对象键时包含该对象本身
+func (ix *ObjectIndex) Scan(ctx context.Context, bucket string, prefix string, fn func(IndexEntry) error) error {
+	return ix.scan(ctx, bucket, prefix, "", fn)
+}
+
+// scan 与 Scan 相同，但只遍历对象键大于 after 的条目
+func (ix *ObjectIndex) scan(ctx context.Context, bucket string, prefix string, after string, fn func(IndexEntry) error) error {
+	if prefix != "" {
+		if prefix > after {
+			entry, err := ix.get(bucket, prefix)
+			if err != nil && !errors.Is(err, ErrResourceNotExists) {
+				return err
+			}
+			if entry != nil {
+				if err := fn(*entry); err != nil {
+					return err
+				}
+			}
+		}
+		prefix += "/"
+	}
+	seek := []byte(prefix)
+	if after != "" && after >= prefix {
+		seek = append([]byte(after), 0)
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch := make([]IndexEntry, 0, indexScanBatch)
+		err := ix.db.View(func(tx *bolt.Tx) error {
+			objects := indexObjects(tx, indexBucketsBucket, bucket)
+			if objects == nil {
+				return nil
+			}
+			c := objects.Cursor()
+			for k, v := c.Seek(seek); k != nil && bytes.HasPrefix(k, []byte(prefix)) && len(batch) < indexScanBatch; k, v = c.Next() {
+				var entry IndexEntry
+				if err := json.Unmarshal(v, &entry); err != nil {
+					return err
+				}
+				batch = append(batch, entry)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, entry := range batch {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		if len(batch) < indexScanBatch {
+			return nil
+		}
+		// 下一批从大于最后一个键的最小键开始
+		seek = append([]byte(batch[len(batch)-1].Key), 0)
+	}
+}
+
+func (ix *ObjectIndex) get(bucket string, key string) (*IndexEntry, error) {
+	var entry *IndexEntry
+	err := ix.db.View(func(tx *bolt.Tx) error {
+		objects := indexObjects(tx, indexBucketsBucket, bucket)
+		if objects == nil {
+			return nil
+		}
+		value := objects.Get([]byte(key))
+		if value == nil {
+			return nil
+		}
+		entry = &IndexEntry{}
+		return json.Unmarshal(value, entry)
+	})
+	if err == nil && entry == nil {
+		err = ErrResourceNotExists
+	}
+	return entry, err
+}
+
+// list 列出目录下的对象与子目录，子目录与 S3 一样不统计大小
+func (ix *ObjectIndex) list(bucket string, dir string) ([]FileListElement, error) {
+	list := make([]FileListElement, 0)
+	err := ix.db.View(func(tx *bolt.Tx) error {
+		objects := indexObjects(tx, indexBucketsBucket, bucket)
+		if objects == nil {
+			return nil
+		}
+		prefix := ""
+		if dir != "" {
+			if objects.Get([]byte(dir)) != nil {
+				return ErrPathIsNotDir
+			}
+			prefix = dir + "/"
+		}
+		c := objects.Cursor()
+		k, v := c.Seek([]byte(prefix))
+		for k != nil && bytes.HasPrefix(k, []byte(prefix)) {
+			name, _, nested := strings.Cut(string(k[len(prefix):]), "/")
+			if nested {
+				list = append(list, FileListElement{Name: name, Type: "dir", ObjectKey: prefix + name + "/"})
+				// '0' 紧随 '/' 之后，跳过整个子目录
+				k, v = c.Seek([]byte(prefix + name + "0"))
+				continue
+			}
+			var entry IndexEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			list = append(list, entry.fileListElement())
+			k, v = c.Next()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// stat 返回存储桶索引的条目数与最近一次重建完成的时间，未建立时 builtAt 为零值
+func (ix *ObjectIndex) stat(bucket string) (objects int, builtAt time.Time, err error) {
+	err = ix.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(indexBucketsBucket).Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		if value := b.Get(indexBuiltAtKey); value != nil {
+			if err := builtAt.UnmarshalText(value); err != nil {
+				return err
+			}
+		}
+		if table := b.Bucket(indexObjectsBucket); table != nil {
+			objects = table.Stats().KeyN
+		}
+		return nil
+	})
+	return objects, builtAt, err
+}
+
+// beginRebuild 清空存储桶的重建区
+func (ix *ObjectIndex) beginRebuild(bucket string) error {
+	return ix.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(indexRebuildBucket).DeleteBucket([]byte(bucket))
+		if err != nil && !errors.Is(err, berrors.ErrBucketNotFound) {
+			return err
+		}
+		_, err = createIndexObjects(tx, indexRebuildBucket, bucket)
+		return err
+	})
+}
+
+func (ix *ObjectIndex) abortRebuild(bucket string) error {
+	return ix.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(indexRebuildBucket).DeleteBucket([]byte(bucket))
+		if errors.Is(err, berrors.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+// commitRebuild 以重建区替换存储桶的索引并记录完成时间
+func (ix *ObjectIndex) commitRebuild(bucket string, builtAt time.Time) error {
+	return ix.db.Update(func(tx *bolt.Tx) error {
+		staging := tx.Bucket(indexRebuildBucket).Bucket([]byte(bucket))
+		if staging == nil {
+			return berrors.ErrBucketNotFound
+		}
+		current, err := tx.Bucket(indexBucketsBucket).CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		if err := current.DeleteBucket(indexObjectsBucket); err != nil && !errors.Is(err, berrors.ErrBucketNotFound) {
+			return err
+		}
+		if err := tx.MoveBucket(indexObjectsBucket, staging, current); err != nil {
+			return err
+		}
+		if err := tx.Bucket(indexRebuildBucket).DeleteBucket([]byte(bucket)); err != nil {
+			return err
+		}
+		value, err := builtAt.MarshalText()
+		if err != nil {
+			return err
+		}
+		return current.Put(indexBuiltAtKey, value)
+	})
+}
@@ -0,0 +1,207 @@
+package oss_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"cube-go/pkg/oss"
+	"cube-go/pkg/oss/osstest"
+)
+
+func openIndex(t *testing.T, file string) *oss.ObjectIndex {
+	t.Helper()
+	index, err := oss.OpenObjectIndex(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = index.Close() })
+	return index
+}
+
+// newIndexedProvider 在 inner 外层建立索引并完成首次重建，之后的列表与遍历都由索引响应
+func newIndexedProvider(t *testing.T, inner oss.StorageProvider, index *oss.ObjectIndex) *oss.IndexedProvider {
+	t.Helper()
+	p, err := oss.NewIndexedProvider(inner, index, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Rebuild(context.Background()); err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+	return p
+}
+
+func listKeys(t *testing.T, p oss.StorageProvider, prefix string) []string {
+	t.Helper()
+	list, err := p.GetFileList(context.Background(), prefix)
+	if err != nil {
+		t.Fatalf("GetFileList(%q): %v", prefix, err)
+	}
+	keys := make([]string, 0, len(list))
+	for _, element := range list {
+		keys = append(keys, element.ObjectKey)
+	}
+	return keys
+}
+
+func TestIndexConformance(t *testing.T) {
+	osstest.Run(t, func(t *testing.T) oss.StorageProvider {
+		index := openIndex(t, filepath.Join(t.TempDir(), "objects.db"))
+		return newIndexedProvider(t, oss.NewMemoryStorageProvider(oss.MemoryStorageOptions{}), index)
+	})
+}
+
+func TestIndexRebuild(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "objects.db")
+	inner := oss.NewMemoryStorageProvider(oss.MemoryStorageOptions{})
+	if err := inner.SaveObject(ctx, strings.NewReader("before"), "docs/before.txt", oss.SaveObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	index := openIndex(t, file)
+	p := newIndexedProvider(t, inner, index)
+	if err := p.SaveObject(ctx, strings.NewReader("tracked"), "docs/tracked.txt", oss.SaveObjectOptions{
+		Tags: map[string]string{"env": "test"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 绕过索引的写入在重建前不可见
+	if err := inner.SaveObject(ctx, strings.NewReader("bypass"), "docs/bypass.txt", oss.SaveObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := listKeys(t, p, "docs"), []string{"docs/before.txt", "docs/tracked.txt"}; !slices.Equal(got, want) {
+		t.Fatalf("GetFileList before rebuild = %v, want %v", got, want)
+	}
+	var found *oss.IndexEntry
+	err := index.Scan(ctx, "test", "docs/tracked.txt", func(entry oss.IndexEntry) error {
+		found = &entry
+		return nil
+	})
+	if err != nil || found == nil {
+		t.Fatalf("Scan = %v, %v", found, err)
+	}
+	if found.Size != 7 || !strings.HasPrefix(found.ContentType, "text/plain") || found.Tags["env"] != "test" || found.SHA256 == "" {
+		t.Errorf("indexed entry = %+v", found)
+	}
+
+	if err := p.Rebuild(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := listKeys(t, p, "docs"), []string{"docs/before.txt", "docs/bypass.txt", "docs/tracked.txt"}; !slices.Equal(got, want) {
+		t.Fatalf("GetFileList after rebuild = %v, want %v", got, want)
+	}
+	status, err := p.Status()
+	if err != nil || !status.Ready || status.Rebuilding || status.Objects != 3 || status.BuiltAt.IsZero() {
+		t.Errorf("Status = %+v, %v", status, err)
+	}
+
+	// 重新打开后索引仍然有效，无需重建
+	if err := index.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := oss.NewIndexedProvider(inner, openIndex(t, file), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, err := reopened.Status(); err != nil || !status.Ready || status.Objects != 3 {
+		t.Errorf("Status after reopen = %+v, %v", status, err)
+	}
+	if err := inner.DeleteObject(ctx, "docs/before.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if got := listKeys(t, reopened, "docs"); len(got) != 3 {
+		t.Errorf("GetFileList after reopen = %v, want the indexed listing", got)
+	}
+}
+
+func TestIndexTrashRestore(t *testing.T) {
+	ctx := context.Background()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := filepath.Rel(wd, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := oss.NewLocalStorageProvider(dir, oss.LocalStorageOptions{Trash: true, Metadata: "bolt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = local.Close() })
+	p := newIndexedProvider(t, local, openIndex(t, filepath.Join(t.TempDir(), "objects.db")))
+	for _, key := range []string{"album/a.txt", "album/sub/b.txt", "other.txt"} {
+		if err := p.SaveObject(ctx, strings.NewReader(key), key, oss.SaveObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.DeleteObject(ctx, "album/"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := listKeys(t, p, ""), []string{"other.txt"}; !slices.Equal(got, want) {
+		t.Fatalf("GetFileList after delete = %v, want %v", got, want)
+	}
+
+	trash, ok := oss.As[oss.TrashProvider](p)
+	if !ok || !trash.TrashEnabled() {
+		t.Fatal("trash not reachable through the index")
+	}
+	items, err := trash.ListTrash(ctx)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("ListTrash = %v, %v", items, err)
+	}
+	if _, err := trash.RestoreTrash(ctx, items[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	err = oss.WalkObjects(ctx, p, "", func(entry oss.ObjectEntry) error {
+		keys = append(keys, entry.Key)
+		return nil
+	})
+	if want := []string{"album/a.txt", "album/sub/b.txt", "other.txt"}; err != nil || !slices.Equal(keys, want) {
+		t.Errorf("WalkObjects after restore = %v, %v, want %v", keys, err, want)
+	}
+}
+
+// 巡检修复不经过索引层，修复后同样要更新索引
+func TestIndexScrubFix(t *testing.T) {
+	ctx := context.Background()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := filepath.Rel(wd, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := oss.NewLocalStorageProvider(dir, oss.LocalStorageOptions{Metadata: "bolt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = local.Close() })
+	p := newIndexedProvider(t, local, openIndex(t, filepath.Join(t.TempDir(), "objects.db")))
+
+	// 绕过存储提供者直接写入的文件没有校验和，索引中也没有
+	if err := os.WriteFile(filepath.Join(dir, "outside.txt"), []byte("outside"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := listKeys(t, p, ""); len(got) != 0 {
+		t.Fatalf("GetFileList before scrub = %v, want empty", got)
+	}
+	scrubber, ok := oss.As[oss.ScrubProvider](p)
+	if !ok {
+		t.Fatal("scrub not reachable through the index")
+	}
+	if _, err := scrubber.Scrub(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	list, err := p.GetFileList(ctx, "")
+	if err != nil || len(list) != 1 || list[0].ObjectKey != "outside.txt" || list[0].Size != int64(len("outside")) {
+		t.Errorf("GetFileList after scrub = %+v, %v", list, err)
+	}
+}
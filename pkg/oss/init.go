@@ -3,16 +3,35 @@ package oss
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"cube-go/pkg/config"
+
+	"github.com/dustin/go-humanize"
+	"go.uber.org/zap"
 )
 
 type bucketConfigElement struct {
-	Name       string `mapstructure:"name"`
-	Type       string `mapstructure:"type"`
-	Target     string `mapstructure:"target"`
-	BucketName string `mapstructure:"bucketName"`
-	Path       string `mapstructure:"path"`
+	Name       string           `mapstructure:"name"`
+	Type       string           `mapstructure:"type"`
+	Target     string           `mapstructure:"target"`
+	BucketName string           `mapstructure:"bucketName"`
+	Path       string           `mapstructure:"path"`
+	Quota      quotaConfig      `mapstructure:"quota"`
+	Trash      trashConfig      `mapstructure:"trash"`
+	Versioning versioningConfig `mapstructure:"versioning"`
+	Metadata   string           `mapstructure:"metadata"`
+	ListMeta   bool             `mapstructure:"listMetadata"`
+	Dedup      bool             `mapstructure:"dedup"`
+	Encryption encryptionConfig `mapstructure:"encryption"`
+	Mirror     mirrorConfig     `mapstructure:"mirror"`
+	Tiered     tieredConfig     `mapstructure:"tiered"`
+	Cache      cacheConfig      `mapstructure:"cache"`
+	Memory     memoryConfig     `mapstructure:"memory"`
 }
 
 // Buckets 全局桶管理器
@@ -23,14 +42,35 @@ var Buckets = &BucketManager{
 var (
 	// ErrUnknownBucketType 未知桶类型
 	ErrUnknownBucketType = errors.New("unknown bucket type")
+	// ErrUnknownVersioningMode 未知多版本实现方式
+	ErrUnknownVersioningMode = errors.New("unknown versioning mode")
 )
 
+// InitOptions 初始化选项
+type InitOptions struct {
+	// Background 启动回收站清理、临时文件清理、索引建立、分层迁移、镜像复制与校准等后台任务，并启用配额。
+	// fsck、reindex 等命令行工具只执行一次性操作，不启动后台任务；配额用量只保存在服务进程内存中，也不统计
+	Background bool
+}
+
 // Init 初始化OSS
-func Init(ctx context.Context) error {
+func Init(ctx context.Context, options InitOptions) error {
 	connections, err := initS3Connections(ctx)
 	if err != nil {
 		return err
 	}
+	sftpConnections, err := initSFTPConnections()
+	if err != nil {
+		return err
+	}
+	webdavConnections, err := initWebDAVConnections()
+	if err != nil {
+		return err
+	}
+	pools := make([]io.Closer, 0, len(sftpConnections))
+	for _, pool := range sftpConnections {
+		pools = append(pools, pool)
+	}
 
 	var cfgList []bucketConfigElement
 	err = config.Config.UnmarshalKey("bucket", &cfgList)
@@ -38,8 +78,32 @@ func Init(ctx context.Context) error {
 		return err
 	}
 
+	members, err := compositeMembers(cfgList)
+	if err != nil {
+		return err
+	}
+
+	var indexCfg indexConfig
+	err = config.Config.UnmarshalKey("index", &indexCfg)
+	if err != nil {
+		return err
+	}
+	var index *ObjectIndex
+	if indexCfg.Enabled {
+		if indexCfg.File == "" {
+			indexCfg.File = filepath.Join("index", "objects.db")
+		}
+		if index, err = OpenObjectIndex(indexCfg.File); err != nil {
+			return err
+		}
+	}
+
+	// 镜像与分层存储桶引用其他存储桶，需在它们之后创建
+	cfgList = sortByDependency(cfgList)
+
 	buckets := make(map[string]StorageProvider, len(cfgList))
-	manager := &BucketManager{buckets: buckets}
+	manager := &BucketManager{buckets: buckets, members: members, pools: pools, index: index}
+	var indexed []*IndexedProvider
 	for _, c := range cfgList {
 		if _, exists := buckets[c.Name]; exists {
 			_ = manager.Close()
@@ -53,9 +117,81 @@ func Init(ctx context.Context) error {
 				_ = manager.Close()
 				return ErrConnectionNotFound
 			}
-			provider = NewS3StorageProvider(client, c.BucketName)
+			options := S3StorageOptions{Trash: c.Trash.Enabled, ListMetadata: c.ListMeta}
+			if c.Versioning.Enabled {
+				options.Versioning = c.Versioning.Mode
+				if options.Versioning == "" {
+					options.Versioning = S3VersioningSuffix
+				}
+				if options.Versioning != S3VersioningNative && options.Versioning != S3VersioningSuffix {
+					_ = manager.Close()
+					return ErrUnknownVersioningMode
+				}
+				if options.Versioning == S3VersioningNative {
+					if err := enableBucketVersioning(ctx, client, c.BucketName); err != nil {
+						_ = manager.Close()
+						return err
+					}
+				}
+			}
+			provider = NewS3StorageProvider(client, c.BucketName, options)
 		} else if c.Type == "local" {
-			provider, err = NewLocalStorageProvider(c.Path)
+			provider, err = NewLocalStorageProvider(c.Path, LocalStorageOptions{
+				Trash:      c.Trash.Enabled,
+				Versioning: c.Versioning.Enabled,
+				Metadata:   c.Metadata,
+				Dedup:      c.Dedup,
+			})
+			if err != nil {
+				_ = manager.Close()
+				return err
+			}
+		} else if c.Type == "sftp" {
+			pool, exists := sftpConnections[c.Target]
+			if !exists {
+				_ = manager.Close()
+				return ErrConnectionNotFound
+			}
+			provider = NewSFTPStorageProvider(pool, c.Path)
+		} else if c.Type == "webdav" {
+			connection, exists := webdavConnections[c.Target]
+			if !exists {
+				_ = manager.Close()
+				return ErrConnectionNotFound
+			}
+			provider, err = NewWebDAVStorageProvider(strings.TrimSuffix(connection.endpoint, "/")+"/"+strings.Trim(c.Path, "/"), connection.options)
+			if err != nil {
+				_ = manager.Close()
+				return err
+			}
+		} else if c.Type == "memory" {
+			provider = NewMemoryStorageProvider(MemoryStorageOptions{MaxBytes: c.Memory.MaxSize * humanize.MiByte})
+		} else if c.Type == "mirror" {
+			replicas := make([]StorageProvider, 0, len(c.Mirror.Replicas))
+			for _, name := range c.Mirror.Replicas {
+				replica, exists := buckets[name]
+				if !exists {
+					_ = manager.Close()
+					return ErrBucketNotFound
+				}
+				replicas = append(replicas, replica)
+			}
+			provider, err = NewMirrorProvider(c.Mirror.Replicas, replicas, c.Mirror)
+			if err != nil {
+				_ = manager.Close()
+				return err
+			}
+		} else if c.Type == "tiered" {
+			hot, hotExists := buckets[c.Tiered.Hot]
+			cold, coldExists := buckets[c.Tiered.Cold]
+			if !hotExists || !coldExists {
+				_ = manager.Close()
+				return ErrBucketNotFound
+			}
+			if c.Tiered.StateFile == "" {
+				c.Tiered.StateFile = filepath.Join("tiers", c.Name+".db")
+			}
+			provider, err = NewTieredProvider(hot, cold, c.Tiered)
 			if err != nil {
 				_ = manager.Close()
 				return err
@@ -64,7 +200,77 @@ func Init(ctx context.Context) error {
 			_ = manager.Close()
 			return ErrUnknownBucketType
 		}
+		if c.Cache.enabled() && c.Type != "s3" {
+			zap.L().Warn("读取缓存仅用于 S3 存储桶，已忽略", zap.String("bucket", c.Name))
+		} else if c.Cache.enabled() {
+			if c.Cache.Dir == "" {
+				c.Cache.Dir = filepath.Join("cache", c.Name)
+			}
+			cachedProvider, err := NewCachedProvider(provider, c.Cache)
+			if err != nil {
+				_ = manager.Close()
+				return err
+			}
+			provider = cachedProvider
+		}
+		if c.Encryption.enabled() {
+			if c.Dedup {
+				zap.L().Warn("加密对象的密文各不相同，去重不会生效", zap.String("bucket", c.Name))
+			}
+			encryptedProvider, err := NewEncryptedProvider(provider, c.Encryption)
+			if err != nil {
+				if closer, ok := provider.(io.Closer); ok {
+					_ = closer.Close()
+				}
+				_ = manager.Close()
+				return err
+			}
+			provider = encryptedProvider
+		}
+		if index != nil {
+			indexedProvider, err := NewIndexedProvider(provider, index, c.Name)
+			if err != nil {
+				if closer, ok := provider.(io.Closer); ok {
+					_ = closer.Close()
+				}
+				_ = manager.Close()
+				return err
+			}
+			provider = indexedProvider
+			indexed = append(indexed, indexedProvider)
+		}
+		if c.Quota.enabled() && options.Background {
+			quotaProvider, err := NewQuotaProvider(ctx, provider, c.Quota)
+			if err != nil {
+				if closer, ok := provider.(io.Closer); ok {
+					_ = closer.Close()
+				}
+				_ = manager.Close()
+				return err
+			}
+			provider = quotaProvider
+		}
 		buckets[c.Name] = provider
+		if !options.Background {
+			continue
+		}
+		if mirror, ok := As[*MirrorProvider](provider); ok {
+			manager.jobs = append(manager.jobs, startMirrorReplicator(mirror, c.Name, c.Mirror.ReconcileInterval))
+		}
+		if local, ok := As[*LocalStorageProvider](provider); ok {
+			manager.jobs = append(manager.jobs, startTempSweeper(local))
+		}
+		if tiered, ok := As[*TieredProvider](provider); ok {
+			manager.jobs = append(manager.jobs, startTierMigrator(tiered))
+		}
+		if c.Trash.Enabled && c.Trash.Retention > 0 {
+			if trash, ok := As[TrashProvider](provider); ok {
+				manager.jobs = append(manager.jobs, startTrashJanitor(trash, c.Trash.Retention))
+			}
+		}
+	}
+	if len(indexed) > 0 && options.Background {
+		manager.jobs = append(manager.jobs, startIndexBuilder(indexed, indexCfg.RebuildInterval))
 	}
 	Buckets = manager
 	return nil
@@ -73,3 +279,53 @@ func Init(ctx context.Context) error {
 func Close() error {
 	return Buckets.Close()
 }
+
+// compositeMembers 返回镜像、分层存储桶的成员所属的存储桶，一个存储桶只能属于一个镜像或分层存储桶，
+// 否则两者的复制、校准与迁移会互相覆盖或删除对方的对象
+func compositeMembers(cfgList []bucketConfigElement) (map[string]string, error) {
+	members := make(map[string]string)
+	for _, c := range cfgList {
+		for _, ref := range c.members() {
+			if owner, exists := members[ref]; exists {
+				return nil, fmt.Errorf("%w: %q is used by %q and %q", ErrBucketInUse, ref, owner, c.Name)
+			}
+			members[ref] = c.Name
+		}
+	}
+	return members, nil
+}
+
+// sortByDependency 将镜像、分层存储桶排在它们的成员之后，成员本身也可以是镜像或分层存储桶。
+// 成员不存在或存在循环引用时，剩余的配置保持原有顺序排在最后，创建时返回 ErrBucketNotFound
+func sortByDependency(cfgList []bucketConfigElement) []bucketConfigElement {
+	sorted := make([]bucketConfigElement, 0, len(cfgList))
+	created := make(map[string]bool, len(cfgList))
+	pending := cfgList
+	for len(pending) > 0 {
+		var rest []bucketConfigElement
+		for _, c := range pending {
+			if slices.ContainsFunc(c.members(), func(name string) bool { return !created[name] }) {
+				rest = append(rest, c)
+				continue
+			}
+			sorted = append(sorted, c)
+			created[c.Name] = true
+		}
+		if len(rest) == len(pending) {
+			return append(sorted, rest...)
+		}
+		pending = rest
+	}
+	return sorted
+}
+
+// members 返回镜像、分层存储桶引用的存储桶
+func (c bucketConfigElement) members() []string {
+	switch c.Type {
+	case "mirror":
+		return c.Mirror.Replicas
+	case "tiered":
+		return []string{c.Tiered.Hot, c.Tiered.Cold}
+	}
+	return nil
+}
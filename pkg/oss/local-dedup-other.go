@@ -0,0 +1,10 @@
+//go:build !unix
+
+package oss
+
+import "io/fs"
+
+// linkCount 当前平台无法读取硬链接数
+func linkCount(fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,163 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"regexp"
+)
+
+// blobsDir 去重模式下按内容 SHA-256 存放数据的目录，对象通过硬链接引用 blob
+const blobsDir = internalDir + "/blobs"
+
+var (
+	// ErrDedupUnsupported 当前平台无法读取硬链接计数，不能启用去重
+	ErrDedupUnsupported = errors.New("dedup is not supported on this platform")
+	// ErrDedupRequiresBolt 去重模式下多个对象共享同一文件，元数据不能存储在扩展属性中
+	ErrDedupRequiresBolt = errors.New("dedup requires bolt metadata backend")
+)
+
+var blobSumPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// DedupReport 去重空间统计
+type DedupReport struct {
+	Blobs        int64 `json:"blobs"`         // blob 数量
+	References   int64 `json:"references"`    // 引用 blob 的对象数，包括回收站与历史版本
+	LogicalSize  int64 `json:"logical_size"`  // 未去重时占用的空间
+	PhysicalSize int64 `json:"physical_size"` // 实际占用的空间
+	SavedSize    int64 `json:"saved_size"`    // 节省的空间
+}
+
+// DedupProvider 由支持内容去重的存储提供者实现
+type DedupProvider interface {
+	DedupEnabled() bool
+	DedupReport(ctx context.Context) (*DedupReport, error)
+}
+
+func blobPath(sum string) string {
+	return path.Join(blobsDir, sum[:2], sum)
+}
+
+// DedupEnabled 是否启用内容去重
+func (p *LocalStorageProvider) DedupEnabled() bool {
+	return p.options.Dedup
+}
+
+// storeBlob 将临时文件登记到 blob 存储。已有相同内容时，临时文件改为指向已有 blob 的硬链接，
+// 随后的发布流程无需区分是否去重。
+func (p *LocalStorageProvider) storeBlob(temp, sum string) error {
+	if !blobSumPattern.MatchString(sum) {
+		return nil
+	}
+	blob := blobPath(sum)
+	unlock := p.locks.lock(blob)
+	defer unlock()
+
+	if err := p.root.MkdirAll(path.Dir(blob), 0755); err != nil {
+		return err
+	}
+	err := p.root.Link(temp, blob)
+	if err == nil || !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	blobInfo, err := p.root.Lstat(blob)
+	if err != nil {
+		return err
+	}
+	tempInfo, err := p.root.Lstat(temp)
+	if err != nil {
+		return err
+	}
+	if blobInfo.Size() != tempInfo.Size() {
+		// 摘要相同但大小不同说明 blob 已损坏，保留新内容不去重
+		return nil
+	}
+	if err := p.root.Remove(temp); err != nil {
+		return err
+	}
+	return p.root.Link(blob, temp)
+}
+
+// blobRefs 收集路径下所有文件引用的 blob，需在删除文件及其元数据之前调用
+func (p *LocalStorageProvider) blobRefs(name string) []string {
+	if !p.options.Dedup {
+		return nil
+	}
+	var sums []string
+	_ = fs.WalkDir(p.root.FS(), name, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if meta, err := p.meta.Get(name); err == nil && meta != nil && meta.SHA256 != "" {
+			sums = append(sums, meta.SHA256)
+		}
+		return nil
+	})
+	return sums
+}
+
+// releaseBlobs 删除已没有对象引用的 blob
+func (p *LocalStorageProvider) releaseBlobs(sums []string) {
+	for _, sum := range sums {
+		if !blobSumPattern.MatchString(sum) {
+			continue
+		}
+		blob := blobPath(sum)
+		unlock := p.locks.lock(blob)
+		if info, err := p.root.Lstat(blob); err == nil {
+			if links, ok := linkCount(info); ok && links <= 1 {
+				_ = p.root.Remove(blob)
+			}
+		}
+		unlock()
+	}
+}
+
+// sweepBlobs 清理崩溃后遗留的无引用 blob
+func (p *LocalStorageProvider) sweepBlobs() {
+	_ = fs.WalkDir(p.root.FS(), blobsDir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		p.releaseBlobs([]string{path.Base(name)})
+		return nil
+	})
+}
+
+// DedupReport 统计去重节省的空间
+func (p *LocalStorageProvider) DedupReport(ctx context.Context) (*DedupReport, error) {
+	report := &DedupReport{}
+	if !p.options.Dedup {
+		return report, nil
+	}
+	err := fs.WalkDir(p.root.FS(), blobsDir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		links, _ := linkCount(info)
+		if links <= 1 {
+			return nil
+		}
+		report.Blobs++
+		report.References += int64(links - 1)
+		report.PhysicalSize += info.Size()
+		report.LogicalSize += info.Size() * int64(links-1)
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	report.SavedSize = report.LogicalSize - report.PhysicalSize
+	return report, nil
+}
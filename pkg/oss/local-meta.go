@@ -0,0 +1,433 @@
+package oss
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/xattr"
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// 本地存储元数据后端
+const (
+	LocalMetadataAuto  = "auto"  // 文件系统支持扩展属性时使用 xattr，否则使用 bolt
+	LocalMetadataXattr = "xattr" // 存储在文件扩展属性中，随文件移动
+	LocalMetadataBolt  = "bolt"  // 存储在桶内嵌入式数据库 .cube/metadata.db 中
+)
+
+// ErrUnknownMetadataBackend 未知元数据后端
+var ErrUnknownMetadataBackend = errors.New("unknown metadata backend")
+
+// ObjectMetadata 对象元数据
+type ObjectMetadata struct {
+	ContentType  string            `json:"content_type,omitempty"`
+	SHA256       string            `json:"sha256,omitempty"`
+	OriginalName string            `json:"original_name,omitempty"`
+	Uploader     string            `json:"uploader,omitempty"`
+	Custom       map[string]string `json:"custom,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	UploadedAt   time.Time         `json:"uploaded_at,omitzero"`
+	// 写入元数据时对象文件的大小与修改时间，用于发现存储之外的修改；本地存储为 Unix 纳秒，SFTP 为 Unix 秒
+	Size    int64 `json:"size,omitempty"`
+	ModTime int64 `json:"mod_time,omitempty"`
+}
+
+// GetMetadata 获取对象的自定义元数据与标签
+func (p *LocalStorageProvider) GetMetadata(ctx context.Context, objectKey string) (*UserMetadata, error) {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return nil, ErrInvalidObjectKey
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if stat, err := p.root.Stat(key); errors.Is(err, fs.ErrNotExist) || (err == nil && stat.IsDir()) {
+		return nil, ErrResourceNotExists
+	} else if err != nil {
+		return nil, err
+	}
+	meta := p.objectMetadata(key, nil)
+	return &UserMetadata{Metadata: meta.Custom, Tags: meta.Tags}, nil
+}
+
+func (p *LocalStorageProvider) storedMetadata(ctx context.Context, objectKey string) (*ObjectMetadata, error) {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return nil, ErrInvalidObjectKey
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return p.meta.Get(key)
+}
+
+// UpdateMetadata 更新对象的自定义元数据与标签
+func (p *LocalStorageProvider) UpdateMetadata(ctx context.Context, objectKey string, update UserMetadata) error {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return ErrInvalidObjectKey
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock := p.locks.lock(key)
+	defer unlock()
+
+	if stat, err := p.root.Stat(key); errors.Is(err, fs.ErrNotExist) || (err == nil && stat.IsDir()) {
+		return ErrResourceNotExists
+	} else if err != nil {
+		return err
+	}
+	meta := p.objectMetadata(key, nil)
+	if update.Metadata != nil {
+		meta.Custom = update.Metadata
+	}
+	if update.Tags != nil {
+		meta.Tags = update.Tags
+	}
+	return p.meta.Put(key, meta)
+}
+
+// metadataStore 本地对象元数据存储，键为桶内相对路径。
+// Delete 与 Rename 同时作用于以 key/ 为前缀的条目，以便整体移动目录。
+type metadataStore interface {
+	// Get 读取元数据，不存在时返回 nil
+	Get(key string) (*ObjectMetadata, error)
+	Put(key string, meta *ObjectMetadata) error
+	// PutContentType 仅在缺少元数据时写入探测到的内容类型，避免覆盖并发写入的完整元数据
+	PutContentType(key string, contentType string) error
+	Delete(key string) error
+	Rename(oldKey, newKey string) error
+	// Keys 返回存储中的所有键，元数据随文件存放的后端返回空
+	Keys() ([]string, error)
+	Close() error
+}
+
+// checkDedupOptions 检查平台能否读取硬链接计数，并将元数据后端固定为 bolt。
+// 去重模式下多个对象共享同一文件，扩展属性无法区分各对象的元数据，显式配置 xattr 时返回错误
+func checkDedupOptions(root *os.Root, options *LocalStorageOptions) error {
+	info, err := root.Stat(".")
+	if err != nil {
+		return err
+	}
+	if _, ok := linkCount(info); !ok {
+		return ErrDedupUnsupported
+	}
+	switch options.Metadata {
+	case "", LocalMetadataAuto, LocalMetadataBolt:
+		options.Metadata = LocalMetadataBolt
+		return nil
+	default:
+		return ErrDedupRequiresBolt
+	}
+}
+
+// newMetadataStore 按配置创建元数据存储
+func newMetadataStore(root *os.Root, folder string, backend string) (metadataStore, error) {
+	switch backend {
+	case "", LocalMetadataAuto:
+		if xattrUsable(root) {
+			return &xattrMetadataStore{root: root}, nil
+		}
+		return openBoltMetadataStore(folder)
+	case LocalMetadataXattr:
+		return &xattrMetadataStore{root: root}, nil
+	case LocalMetadataBolt:
+		return openBoltMetadataStore(folder)
+	default:
+		return nil, ErrUnknownMetadataBackend
+	}
+}
+
+// xattrUsable 在临时目录中试写扩展属性，判断文件系统是否支持 user.* 属性
+func xattrUsable(root *os.Root) bool {
+	if !xattr.XATTR_SUPPORTED {
+		return false
+	}
+	if err := root.MkdirAll(tempDir, 0755); err != nil {
+		return false
+	}
+	name := path.Join(tempDir, newInternalID(time.Now()))
+	file, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = file.Close()
+		_ = root.Remove(name)
+	}()
+	return xattr.FSet(file, "user.cube.probe", []byte("1")) == nil
+}
+
+const (
+	xattrMimeType = "user.mimetype"
+	xattrMetadata = "user.cube.meta"
+)
+
+// xattrMetadataStore 将元数据保存在文件扩展属性中
+type xattrMetadataStore struct {
+	root *os.Root
+}
+
+func (s *xattrMetadataStore) Get(key string) (*ObjectMetadata, error) {
+	file, err := s.root.Open(key)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	return readXattrMetadata(file)
+}
+
+// readXattrMetadata 读取文件扩展属性中的元数据，早期版本只写入了 user.mimetype
+func readXattrMetadata(file *os.File) (*ObjectMetadata, error) {
+	if !xattr.XATTR_SUPPORTED {
+		return nil, nil
+	}
+	if value, err := xattr.FGet(file, xattrMetadata); err == nil {
+		var meta ObjectMetadata
+		if err := json.Unmarshal(value, &meta); err != nil {
+			return nil, err
+		}
+		return &meta, nil
+	}
+	if value, err := xattr.FGet(file, xattrMimeType); err == nil && len(value) > 0 {
+		return &ObjectMetadata{ContentType: string(value)}, nil
+	}
+	return nil, nil
+}
+
+func (s *xattrMetadataStore) Put(key string, meta *ObjectMetadata) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	file, err := s.root.Open(key)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	if meta.ContentType != "" {
+		if err := xattr.FSet(file, xattrMimeType, []byte(meta.ContentType)); err != nil {
+			return err
+		}
+	}
+	if err := xattr.FSet(file, xattrMetadata, data); err != nil {
+		return err
+	}
+	// 扩展属性保存在 inode 中，落盘后随文件一起发布
+	return file.Sync()
+}
+
+func (s *xattrMetadataStore) PutContentType(key string, contentType string) error {
+	file, err := s.root.Open(key)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	return xattr.FSet(file, xattrMimeType, []byte(contentType))
+}
+
+// Delete 扩展属性随文件删除
+func (s *xattrMetadataStore) Delete(string) error {
+	return nil
+}
+
+// Rename 扩展属性随文件移动
+func (s *xattrMetadataStore) Rename(string, string) error {
+	return nil
+}
+
+func (s *xattrMetadataStore) Keys() ([]string, error) {
+	return nil, nil
+}
+
+func (s *xattrMetadataStore) Close() error {
+	return nil
+}
+
+var (
+	boltObjectsBucket = []byte("objects")
+	boltStateBucket   = []byte("state")
+	boltXattrImported = []byte("xattr_imported")
+)
+
+// boltMetadataStore 将元数据保存在桶内嵌入式数据库中
+type boltMetadataStore struct {
+	db *bolt.DB
+}
+
+func openBoltMetadataStore(folder string) (*boltMetadataStore, error) {
+	dir := filepath.Join(folder, filepath.FromSlash(internalDir))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	db, err := openBolt(filepath.Join(dir, "metadata.db"), 0644)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltObjectsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(boltStateBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &boltMetadataStore{db: db}, nil
+}
+
+func (s *boltMetadataStore) Get(key string) (*ObjectMetadata, error) {
+	var meta *ObjectMetadata
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltObjectsBucket).Get([]byte(key))
+		if value == nil {
+			return nil
+		}
+		meta = &ObjectMetadata{}
+		return json.Unmarshal(value, meta)
+	})
+	return meta, err
+}
+
+func (s *boltMetadataStore) Put(key string, meta *ObjectMetadata) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltObjectsBucket).Put([]byte(key), data)
+	})
+}
+
+func (s *boltMetadataStore) PutContentType(key string, contentType string) error {
+	data, err := json.Marshal(ObjectMetadata{ContentType: contentType})
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltObjectsBucket)
+		if bucket.Get([]byte(key)) != nil {
+			return nil
+		}
+		return bucket.Put([]byte(key), data)
+	})
+}
+
+func (s *boltMetadataStore) Delete(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltObjectsBucket)
+		for _, k := range boltKeysUnder(bucket, key) {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltMetadataStore) Rename(oldKey, newKey string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltObjectsBucket)
+		for _, k := range boltKeysUnder(bucket, oldKey) {
+			target := newKey + string(k[len(oldKey):])
+			if err := bucket.Put([]byte(target), bytes.Clone(bucket.Get(k))); err != nil {
+				return err
+			}
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// boltKeysUnder 返回 key 本身及以 key/ 为前缀的所有键
+func boltKeysUnder(bucket *bolt.Bucket, key string) [][]byte {
+	var keys [][]byte
+	if bucket.Get([]byte(key)) != nil {
+		keys = append(keys, []byte(key))
+	}
+	prefix := []byte(key + "/")
+	cursor := bucket.Cursor()
+	for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+		keys = append(keys, bytes.Clone(k))
+	}
+	return keys
+}
+
+func (s *boltMetadataStore) Keys() ([]string, error) {
+	var keys []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltObjectsBucket).ForEach(func(k, _ []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	return keys, err
+}
+
+func (s *boltMetadataStore) Close() error {
+	return s.db.Close()
+}
+
+// importXattrs 首次启用数据库后端时导入已有文件扩展属性中的元数据，已存在的条目不会被覆盖
+func (s *boltMetadataStore) importXattrs(ctx context.Context, p *LocalStorageProvider) error {
+	var done bool
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		done = tx.Bucket(boltStateBucket).Get(boltXattrImported) != nil
+		return nil
+	})
+	if done {
+		return nil
+	}
+
+	imported := 0
+	if xattr.XATTR_SUPPORTED {
+		err := p.walkObjects(ctx, "", func(entry ObjectEntry) error {
+			file, err := p.root.Open(entry.Key)
+			if err != nil {
+				return nil
+			}
+			meta, err := readXattrMetadata(file)
+			_ = file.Close()
+			if err != nil || meta == nil {
+				return nil
+			}
+			data, err := json.Marshal(meta)
+			if err != nil {
+				return err
+			}
+			return s.db.Update(func(tx *bolt.Tx) error {
+				bucket := tx.Bucket(boltObjectsBucket)
+				if bucket.Get([]byte(entry.Key)) != nil {
+					return nil
+				}
+				imported++
+				return bucket.Put([]byte(entry.Key), data)
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if imported > 0 {
+		zap.L().Info("已导入扩展属性元数据", zap.Int("count", imported))
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltStateBucket).Put(boltXattrImported, []byte(time.Now().Format(time.RFC3339)))
+	})
+}
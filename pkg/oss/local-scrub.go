@@ -0,0 +1,264 @@
+package oss
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/xattr"
+)
+
+// Scrub 巡检本地存储桶
+func (p *LocalStorageProvider) Scrub(ctx context.Context, fix bool) (*ScrubResult, error) {
+	result := &ScrubResult{Issues: []ScrubIssue{}}
+	err := p.walkObjects(ctx, "", func(entry ObjectEntry) error {
+		result.Objects++
+		result.Bytes += entry.Size
+		p.scrubObject(ctx, entry.Key, fix, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := p.scrubOrphanMetadata(ctx, fix, result); err != nil {
+		return nil, err
+	}
+	p.scrubTemp(fix, result)
+	if p.options.Dedup {
+		p.scrubBlobs(fix, result)
+	}
+	for _, issue := range result.Issues {
+		switch issue.Kind {
+		case ScrubChecksumMismatch, ScrubMissingChecksum, ScrubInvalidMetadata, ScrubStaleXattr:
+			if issue.Fixed {
+				p.notifyChange(issue.ObjectKey)
+			}
+		}
+	}
+	return result, nil
+}
+
+// scrubObject 重新计算对象的校验和并与元数据比对
+func (p *LocalStorageProvider) scrubObject(ctx context.Context, key string, fix bool, result *ScrubResult) {
+	file, err := p.root.Open(key)
+	if err != nil {
+		result.add(ScrubUnreadable, key, err.Error(), false)
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		result.add(ScrubUnreadable, key, err.Error(), false)
+		return
+	}
+	actual := hex.EncodeToString(hash.Sum(nil))
+
+	meta, err := p.meta.Get(key)
+	if err != nil {
+		result.add(ScrubInvalidMetadata, key, err.Error(), fix)
+		meta = nil
+	}
+	switch {
+	case meta == nil || meta.SHA256 == "" || meta.ContentType == "":
+		if meta == nil {
+			meta = &ObjectMetadata{}
+		}
+		if meta.SHA256 == "" {
+			meta.SHA256 = actual
+		}
+		if meta.ContentType == "" {
+			meta.ContentType = detectMimeType(file)
+		}
+		result.add(ScrubMissingChecksum, key, "", fix)
+		if fix {
+			p.putMetadata(key, meta)
+		}
+	case meta.SHA256 == actual:
+		// 内容与摘要一致但文件被 touch 等操作改动过，重新记录后 ETag 恢复为强 ETag
+		if stat, err := file.Stat(); fix && err == nil && localChecksum(meta, stat) == "" {
+			meta.Size, meta.ModTime = stat.Size(), stat.ModTime().UnixNano()
+			p.putMetadata(key, meta)
+		}
+	case meta.SHA256 != actual:
+		// 内容已损坏，改写记录的校验和会掩盖损坏，只能用内容一致的历史版本替换
+		issue := ScrubIssue{Kind: ScrubChecksumMismatch, ObjectKey: key, Expected: meta.SHA256, Detail: "got " + actual}
+		if stat, err := file.Stat(); err == nil {
+			if localChecksum(meta, stat) == "" {
+				issue.Detail += ", file modified outside the service"
+			}
+			if fix {
+				if versionID, err := p.repairFromVersion(ctx, key, meta, stat); err != nil {
+					issue.Detail += ", repair failed: " + err.Error()
+				} else if versionID != "" {
+					issue.Detail += ", restored from version " + versionID
+					issue.Fixed = true
+				}
+			}
+		}
+		result.Issues = append(result.Issues, issue)
+	}
+
+	if _, ok := p.meta.(*boltMetadataStore); ok && xattr.XATTR_SUPPORTED {
+		names, err := xattr.FList(file)
+		if err != nil {
+			return
+		}
+		var stale []string
+		for _, name := range names {
+			if name == xattrMimeType || name == xattrMetadata {
+				stale = append(stale, name)
+			}
+		}
+		if len(stale) == 0 {
+			return
+		}
+		result.add(ScrubStaleXattr, key, strings.Join(stale, ","), fix)
+		if fix {
+			for _, name := range stale {
+				_ = xattr.FRemove(file, name)
+			}
+		}
+	}
+}
+
+// repairFromVersion 在历史版本中查找内容与记录的校验和一致的一份，替换损坏的对象，返回所用的版本 ID。
+// 没有可用的历史版本时返回空字符串；巡检之后对象又被改写时放弃修复
+func (p *LocalStorageProvider) repairFromVersion(ctx context.Context, key string, meta *ObjectMetadata, damaged fs.FileInfo) (string, error) {
+	if !p.options.Versioning {
+		return "", nil
+	}
+	versions, err := p.ListVersions(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	for _, version := range versions {
+		if version.IsLatest {
+			continue
+		}
+		source, err := p.root.Open(versionKey(key, version.VersionID))
+		if err != nil {
+			continue
+		}
+		temp, tempMeta, err := p.writeTemp(ctx, source)
+		_ = source.Close()
+		if err != nil {
+			return "", err
+		}
+		if tempMeta.SHA256 != meta.SHA256 {
+			p.removeTemp(temp)
+			continue
+		}
+		// 去重模式下同摘要的 blob 可能正是损坏的那份，修复后的对象单独存放
+		repaired := *meta
+		if err := p.stampMetadata(temp, &repaired); err != nil {
+			p.removeTemp(temp)
+			return "", err
+		}
+		if err := p.meta.Put(temp, &repaired); err != nil {
+			p.removeTemp(temp)
+			return "", err
+		}
+		err = p.replaceDamaged(key, temp, damaged)
+		p.removeTemp(temp)
+		if err != nil {
+			return "", err
+		}
+		return version.VersionID, nil
+	}
+	return "", nil
+}
+
+// replaceDamaged 以修复后的临时文件替换损坏的对象，不保留损坏的内容为历史版本
+func (p *LocalStorageProvider) replaceDamaged(key, temp string, damaged fs.FileInfo) error {
+	unlock := p.locks.lock(key)
+	defer unlock()
+
+	stat, err := p.root.Lstat(key)
+	if err != nil {
+		return err
+	}
+	if stat.Size() != damaged.Size() || !stat.ModTime().Equal(damaged.ModTime()) {
+		return ErrPreconditionFailed
+	}
+	refs := p.blobRefs(key)
+	if err := p.root.Rename(temp, key); err != nil {
+		return err
+	}
+	p.publishMetadata(temp, key)
+	p.releaseBlobs(refs)
+	p.syncDir(path.Dir(key))
+	return nil
+}
+
+// scrubOrphanMetadata 检查元数据存储中文件已不存在的条目
+func (p *LocalStorageProvider) scrubOrphanMetadata(ctx context.Context, fix bool, result *ScrubResult) error {
+	keys, err := p.meta.Keys()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// 写入中的临时文件的元数据会在发布时转移，跳过以免误删
+		if strings.HasPrefix(key, tempDir+"/") {
+			continue
+		}
+		if _, err := p.root.Lstat(key); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		result.add(ScrubOrphanMetadata, key, "", fix)
+		if fix {
+			_ = p.meta.Delete(key)
+		}
+	}
+	return nil
+}
+
+// scrubTemp 检查崩溃遗留的临时文件
+func (p *LocalStorageProvider) scrubTemp(fix bool, result *ScrubResult) {
+	entries, err := fs.ReadDir(p.root.FS(), tempDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < staleTempAge {
+			continue
+		}
+		name := path.Join(tempDir, entry.Name())
+		result.add(ScrubStaleTemp, name, "", fix)
+		if fix {
+			p.removeTemp(name)
+		}
+	}
+}
+
+// scrubBlobs 检查已没有对象引用的 blob
+func (p *LocalStorageProvider) scrubBlobs(fix bool, result *ScrubResult) {
+	var orphans []string
+	_ = fs.WalkDir(p.root.FS(), blobsDir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		if links, ok := linkCount(info); ok && links <= 1 {
+			orphans = append(orphans, path.Base(name))
+			result.add(ScrubOrphanBlob, name, "", fix)
+		}
+		return nil
+	})
+	if fix {
+		p.releaseBlobs(orphans)
+	}
+}
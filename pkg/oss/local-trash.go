@@ -0,0 +1,174 @@
+package oss
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"time"
+)
+
+// TrashEnabled 是否启用回收站
+func (p *LocalStorageProvider) TrashEnabled() bool {
+	return p.options.Trash
+}
+
+// moveToTrash 将对象或目录移入回收站，目标不存在时不做处理
+func (p *LocalStorageProvider) moveToTrash(ctx context.Context, key string) error {
+	stat, err := p.root.Lstat(key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	item := TrashItem{ObjectKey: key, DeletedAt: time.Now()}
+	if stat.IsDir() {
+		item.ObjectKey += "/"
+		err = p.walkObjects(ctx, key+"/", func(entry ObjectEntry) error {
+			item.Size += entry.Size
+			item.Objects++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		item.Size = stat.Size()
+	}
+	item.ID = newInternalID(item.DeletedAt)
+
+	dir := path.Join(trashDir, item.ID)
+	if err := p.root.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	meta, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if err := p.root.WriteFile(path.Join(dir, trashMetaName), meta, 0644); err != nil {
+		_ = p.root.RemoveAll(dir)
+		return err
+	}
+	if err := p.root.Rename(key, path.Join(dir, trashDataName)); err != nil {
+		_ = p.root.RemoveAll(dir)
+		return err
+	}
+	return p.meta.Rename(key, path.Join(dir, trashDataName))
+}
+
+// ListTrash 列出回收站条目，按删除时间倒序
+func (p *LocalStorageProvider) ListTrash(ctx context.Context) ([]TrashItem, error) {
+	if !p.options.Trash {
+		return nil, ErrTrashDisabled
+	}
+	entries, err := fs.ReadDir(p.root.FS(), trashDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []TrashItem{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	items := make([]TrashItem, 0, len(entries))
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !entry.IsDir() || !validInternalID(entry.Name()) {
+			continue
+		}
+		item, err := p.readTrashItem(entry.Name())
+		if err != nil {
+			continue
+		}
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+	return items, nil
+}
+
+// RestoreTrash 将回收站条目还原到原位置，原位置已存在时返回 ErrFileAlreadyExists
+func (p *LocalStorageProvider) RestoreTrash(ctx context.Context, id string) (*TrashItem, error) {
+	if !p.options.Trash {
+		return nil, ErrTrashDisabled
+	}
+	if !validInternalID(id) {
+		return nil, ErrTrashItemNotFound
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	item, err := p.readTrashItem(id)
+	if err != nil {
+		return nil, err
+	}
+	key, _, err := NormalizeObjectKey(item.ObjectKey, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.root.Lstat(key); err == nil {
+		return nil, ErrFileAlreadyExists
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if dir := path.Dir(key); dir != "." {
+		if err := p.root.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.root.Rename(path.Join(trashDir, id, trashDataName), key); err != nil {
+		return nil, err
+	}
+	if err := p.meta.Rename(path.Join(trashDir, id, trashDataName), key); err != nil {
+		return nil, err
+	}
+	return item, p.root.RemoveAll(path.Join(trashDir, id))
+}
+
+// PurgeTrash 永久删除回收站条目
+func (p *LocalStorageProvider) PurgeTrash(ctx context.Context, id string) error {
+	if !p.options.Trash {
+		return ErrTrashDisabled
+	}
+	if !validInternalID(id) {
+		return ErrTrashItemNotFound
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	dir := path.Join(trashDir, id)
+	if _, err := p.root.Lstat(dir); errors.Is(err, fs.ErrNotExist) {
+		return ErrTrashItemNotFound
+	}
+	refs := p.blobRefs(dir)
+	if err := p.root.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := p.meta.Delete(dir); err != nil {
+		return err
+	}
+	p.releaseBlobs(refs)
+	return nil
+}
+
+func (p *LocalStorageProvider) readTrashItem(id string) (*TrashItem, error) {
+	data, err := p.root.ReadFile(path.Join(trashDir, id, trashMetaName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrTrashItemNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var item TrashItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, err
+	}
+	item.ID = id
+	return &item, nil
+}
@@ -0,0 +1,196 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// VersioningEnabled 是否启用多版本
+func (p *LocalStorageProvider) VersioningEnabled() bool {
+	return p.options.Versioning
+}
+
+// archiveCurrent 将当前版本保留到历史版本目录并返回其路径，调用方需持有对象键的锁。
+// 当前版本在此期间始终存在，随后的重命名会原子地替换它
+func (p *LocalStorageProvider) archiveCurrent(key string) (string, error) {
+	archived := versionKey(key, newInternalID(time.Now()))
+	if err := p.root.MkdirAll(path.Dir(archived), 0755); err != nil {
+		return "", err
+	}
+	if err := p.root.Link(key, archived); err != nil {
+		// 文件系统不支持硬链接时复制一份，不能移走当前版本
+		if err := p.copyToArchive(key, archived); err != nil {
+			return "", err
+		}
+	}
+	if meta, err := p.meta.Get(key); err == nil && meta != nil {
+		// 复制得到的历史版本修改时间不同，摘要仍与内容一致时重新记录
+		if stat, err := p.root.Lstat(key); err == nil && localChecksum(meta, stat) != "" {
+			_ = p.stampMetadata(archived, meta)
+		}
+		p.putMetadata(archived, meta)
+	}
+	return archived, nil
+}
+
+// copyToArchive 经临时文件将当前版本完整复制为历史版本
+func (p *LocalStorageProvider) copyToArchive(key, archived string) error {
+	source, err := p.root.Open(key)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = source.Close() }()
+	temp, _, err := p.writeTemp(context.Background(), source)
+	if err != nil {
+		return err
+	}
+	if err := p.root.Rename(temp, archived); err != nil {
+		_ = p.root.Remove(temp)
+		return err
+	}
+	return nil
+}
+
+// discardArchive 替换失败时删除刚保留的历史版本
+func (p *LocalStorageProvider) discardArchive(archived string) {
+	_ = p.root.Remove(archived)
+	_ = p.meta.Delete(archived)
+}
+
+// ListVersions 列出对象的所有版本，按时间倒序
+func (p *LocalStorageProvider) ListVersions(ctx context.Context, objectKey string) ([]ObjectVersion, error) {
+	if !p.options.Versioning {
+		return nil, ErrVersioningDisabled
+	}
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return nil, ErrInvalidObjectKey
+	}
+	versions := make([]ObjectVersion, 0)
+	if info, err := p.StatObject(ctx, key, GetObjectOptions{}); err == nil {
+		versions = append(versions, ObjectVersion{
+			Size:         info.ContentLength,
+			ETag:         info.ETag,
+			LastModified: info.LastModified,
+			IsLatest:     true,
+		})
+	} else if !errors.Is(err, ErrResourceNotExists) {
+		return nil, err
+	}
+
+	dir := path.Dir(versionKey(key, ""))
+	prefix := path.Base(key) + "@"
+	entries, err := fs.ReadDir(p.root.FS(), dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	archived := make([]ObjectVersion, 0, len(entries))
+	for _, entry := range entries {
+		id, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || entry.IsDir() || !validInternalID(id) {
+			continue
+		}
+		info, err := p.StatObject(ctx, key, GetObjectOptions{VersionID: id})
+		if err != nil {
+			continue
+		}
+		archived = append(archived, ObjectVersion{
+			VersionID:    id,
+			Size:         info.ContentLength,
+			ETag:         info.ETag,
+			LastModified: info.LastModified,
+		})
+	}
+	sort.Slice(archived, func(i, j int) bool {
+		return archived[i].VersionID > archived[j].VersionID
+	})
+	return append(versions, archived...), nil
+}
+
+// RestoreVersion 将历史版本恢复为当前版本，当前版本会被保留为新的历史版本
+func (p *LocalStorageProvider) RestoreVersion(ctx context.Context, objectKey string, versionID string) error {
+	if !p.options.Versioning {
+		return ErrVersioningDisabled
+	}
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return ErrInvalidObjectKey
+	}
+	if !validInternalID(versionID) {
+		return ErrVersionNotFound
+	}
+	source, err := p.root.Open(versionKey(key, versionID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrVersionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = source.Close() }()
+	if dir := path.Dir(key); dir != "." {
+		if err := p.root.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	temp, meta, err := p.writeTemp(ctx, source)
+	if err != nil {
+		return err
+	}
+	defer p.removeTemp(temp)
+	if p.options.Dedup {
+		if err := p.storeBlob(temp, meta.SHA256); err != nil {
+			return err
+		}
+	}
+	meta.UploadedAt = time.Now()
+	if archived, err := p.meta.Get(versionKey(key, versionID)); err == nil && archived != nil {
+		meta.OriginalName = archived.OriginalName
+		meta.Uploader = archived.Uploader
+		meta.Custom = archived.Custom
+	}
+	if err := p.stampMetadata(temp, meta); err != nil {
+		return err
+	}
+	// 元数据写入失败时不发布对象，避免对象与元数据不一致
+	if err := p.meta.Put(temp, meta); err != nil {
+		return err
+	}
+	return p.replaceObject(key, temp, SaveObjectOptions{Overwrite: true})
+}
+
+// DeleteVersion 删除历史版本
+func (p *LocalStorageProvider) DeleteVersion(ctx context.Context, objectKey string, versionID string) error {
+	if !p.options.Versioning {
+		return ErrVersioningDisabled
+	}
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return ErrInvalidObjectKey
+	}
+	if !validInternalID(versionID) {
+		return ErrVersionNotFound
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	archived := versionKey(key, versionID)
+	refs := p.blobRefs(archived)
+	if err := p.root.Remove(archived); errors.Is(err, fs.ErrNotExist) {
+		return ErrVersionNotFound
+	} else if err != nil {
+		return err
+	}
+	_ = p.meta.Delete(archived)
+	p.releaseBlobs(refs)
+	for dir := path.Dir(archived); dir != versionsDir; dir = path.Dir(dir) {
+		if err := p.root.Remove(dir); err != nil {
+			break
+		}
+	}
+	return nil
+}
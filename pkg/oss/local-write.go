@@ -0,0 +1,268 @@
+package oss
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// tempDir 本地存储写入中的临时文件目录
+const tempDir = internalDir + "/tmp"
+
+// staleTempAge 超过该时长未修改的临时文件视为崩溃残留
+const staleTempAge = 10 * time.Minute
+
+// writeTemp 将内容写入内部临时文件并落盘，返回临时文件路径及探测到的内容类型与校验和
+func (p *LocalStorageProvider) writeTemp(ctx context.Context, reader io.ReadSeeker) (string, *ObjectMetadata, error) {
+	if err := p.root.MkdirAll(tempDir, 0755); err != nil {
+		return "", nil, err
+	}
+	name := path.Join(tempDir, newInternalID(time.Now()))
+	file, err := p.root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", nil, err
+	}
+	removePartial := func() {
+		_ = file.Close()
+		_ = p.root.Remove(name)
+	}
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(file, hash), reader); err != nil {
+		removePartial()
+		return "", nil, err
+	}
+	if err = ctx.Err(); err != nil {
+		removePartial()
+		return "", nil, err
+	}
+	if err = file.Sync(); err != nil {
+		removePartial()
+		return "", nil, err
+	}
+	if err = file.Close(); err != nil {
+		_ = p.root.Remove(name)
+		return "", nil, err
+	}
+	return name, &ObjectMetadata{
+		ContentType: detectMimeType(reader),
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// removeTemp 删除临时文件及其元数据，已发布的临时文件只剩元数据为空操作
+func (p *LocalStorageProvider) removeTemp(temp string) {
+	_ = p.root.Remove(temp)
+	_ = p.meta.Delete(temp)
+}
+
+// publishMetadata 将临时文件的元数据转移到已发布的对象
+func (p *LocalStorageProvider) publishMetadata(temp, key string) {
+	if err := p.meta.Rename(temp, key); err != nil {
+		zap.L().Warn("写入对象元数据失败", zap.String("name", key), zap.Error(err))
+	}
+}
+
+// linkObject 以硬链接方式将临时文件发布为对象，目标已存在时返回 ErrFileAlreadyExists
+func (p *LocalStorageProvider) linkObject(key, temp string) error {
+	unlock := p.locks.lock(key)
+	defer unlock()
+
+	err := p.root.Link(temp, key)
+	if errors.Is(err, fs.ErrExist) {
+		return ErrFileAlreadyExists
+	}
+	if err != nil {
+		// 文件系统不支持硬链接时，在锁内确认目标不存在后重命名
+		if _, statErr := p.root.Lstat(key); statErr == nil {
+			return ErrFileAlreadyExists
+		} else if !errors.Is(statErr, fs.ErrNotExist) {
+			return statErr
+		}
+		if err := p.root.Rename(temp, key); err != nil {
+			return err
+		}
+	}
+	p.publishMetadata(temp, key)
+	p.syncDir(path.Dir(key))
+	return nil
+}
+
+// replaceObject 将临时文件替换为对象，启用多版本时先保留当前版本
+func (p *LocalStorageProvider) replaceObject(key, temp string, options SaveObjectOptions) error {
+	unlock := p.locks.lock(key)
+	defer unlock()
+
+	stat, err := p.root.Lstat(key)
+	if errors.Is(err, fs.ErrNotExist) {
+		if options.IfMatch != "" {
+			return ErrPreconditionFailed
+		}
+		if err := p.root.Rename(temp, key); err != nil {
+			return err
+		}
+		p.publishMetadata(temp, key)
+		p.syncDir(path.Dir(key))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() || (!options.Overwrite && options.IfMatch == "" && !p.options.Versioning) {
+		return ErrFileAlreadyExists
+	}
+	if options.IfMatch != "" {
+		current, _ := p.meta.Get(key)
+		if !etagMatches(options.IfMatch, localETag(localChecksum(current, stat), stat)) {
+			return ErrPreconditionFailed
+		}
+	}
+	var archived string
+	if p.options.Versioning {
+		if archived, err = p.archiveCurrent(key); err != nil {
+			return err
+		}
+	}
+	refs := p.blobRefs(key)
+	if err := p.root.Rename(temp, key); err != nil {
+		if archived != "" {
+			p.discardArchive(archived)
+		}
+		return err
+	}
+	p.publishMetadata(temp, key)
+	p.releaseBlobs(refs)
+	p.syncDir(path.Dir(key))
+	return nil
+}
+
+// rewriteContent 以新内容替换对象，元数据与修改时间保持不变，不保留历史版本
+func (p *LocalStorageProvider) rewriteContent(ctx context.Context, objectKey string, reader io.ReadSeeker, ifMatch string) error {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return ErrInvalidObjectKey
+	}
+	temp, tempMeta, err := p.writeTemp(ctx, reader)
+	if err != nil {
+		return err
+	}
+	defer p.removeTemp(temp)
+
+	unlock := p.locks.lock(key)
+	defer unlock()
+	stat, err := p.root.Lstat(key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrResourceNotExists
+	}
+	if err != nil {
+		return err
+	}
+	current, err := p.meta.Get(key)
+	if err != nil {
+		return err
+	}
+	if ifMatch != "" && !etagMatches(ifMatch, localETag(localChecksum(current, stat), stat)) {
+		return ErrPreconditionFailed
+	}
+	meta := &ObjectMetadata{ContentType: tempMeta.ContentType}
+	if current != nil {
+		meta = current
+	}
+	meta.SHA256 = tempMeta.SHA256
+	if p.options.Dedup {
+		// 链接到已有 blob 后修改时间由各引用共享，不能改动
+		if err := p.storeBlob(temp, meta.SHA256); err != nil {
+			return err
+		}
+	} else if err := p.root.Chtimes(temp, stat.ModTime(), stat.ModTime()); err != nil {
+		return err
+	}
+	if err := p.stampMetadata(temp, meta); err != nil {
+		return err
+	}
+	if err := p.meta.Put(temp, meta); err != nil {
+		return err
+	}
+	refs := p.blobRefs(key)
+	if err := p.root.Rename(temp, key); err != nil {
+		return err
+	}
+	p.publishMetadata(temp, key)
+	p.releaseBlobs(refs)
+	p.syncDir(path.Dir(key))
+	return nil
+}
+
+// syncDir 将目录项落盘，部分平台不支持时忽略
+func (p *LocalStorageProvider) syncDir(dir string) {
+	file, err := p.root.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = file.Sync()
+	_ = file.Close()
+}
+
+// sweepTemp 清理崩溃后遗留的临时文件。
+// 同一目录可能被正在运行的服务与 fsck 等命令同时打开，只清理超过 staleTempAge 的文件，
+// 因此启动时过新的残留由 tempSweeper 稍后清理
+func (p *LocalStorageProvider) sweepTemp() {
+	entries, err := fs.ReadDir(p.root.FS(), tempDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < staleTempAge {
+			continue
+		}
+		name := path.Join(tempDir, entry.Name())
+		if err := p.root.RemoveAll(name); err != nil {
+			zap.L().Warn("清理临时文件失败", zap.String("name", name), zap.Error(err))
+			continue
+		}
+		_ = p.meta.Delete(name)
+	}
+}
+
+// tempSweeper 定期清理本地存储桶崩溃遗留的临时文件
+type tempSweeper struct {
+	provider *LocalStorageProvider
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func startTempSweeper(provider *LocalStorageProvider) *tempSweeper {
+	s := &tempSweeper{provider: provider, stop: make(chan struct{})}
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+func (s *tempSweeper) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(staleTempAge)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.provider.sweepTemp()
+		}
+	}
+}
+
+func (s *tempSweeper) Close() error {
+	close(s.stop)
+	s.wg.Wait()
+	return nil
+}
@@ -13,17 +13,28 @@ import (
 	"time"
 
 	"github.com/gabriel-vasile/mimetype"
-	"github.com/pkg/xattr"
 	"go.uber.org/zap"
 )
 
 // LocalStorageProvider 本地存储提供者
 type LocalStorageProvider struct {
-	root *os.Root
+	root    *os.Root
+	options LocalStorageOptions
+	locks   keyLocks
+	meta    metadataStore
+	changeNotifier
+}
+
+// LocalStorageOptions 本地存储提供者选项
+type LocalStorageOptions struct {
+	Trash      bool   // 删除时移入回收站
+	Versioning bool   // 覆盖时保留历史版本
+	Metadata   string // 元数据后端：auto、xattr 或 bolt
+	Dedup      bool   // 相同内容只存储一份
 }
 
 // NewLocalStorageProvider 创建一个本地存储提供者
-func NewLocalStorageProvider(p string) (*LocalStorageProvider, error) {
+func NewLocalStorageProvider(p string, options LocalStorageOptions) (*LocalStorageProvider, error) {
 	folder := filepath.Join(".", p)
 	if err := os.MkdirAll(folder, 0755); err != nil {
 		return nil, err
@@ -32,15 +43,43 @@ func NewLocalStorageProvider(p string) (*LocalStorageProvider, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &LocalStorageProvider{root: root}, nil
+	if options.Dedup {
+		if err := checkDedupOptions(root, &options); err != nil {
+			_ = root.Close()
+			return nil, err
+		}
+	}
+	meta, err := newMetadataStore(root, folder, options.Metadata)
+	if err != nil {
+		_ = root.Close()
+		return nil, err
+	}
+	provider := &LocalStorageProvider{root: root, options: options, meta: meta}
+	if store, ok := meta.(*boltMetadataStore); ok {
+		if err := store.importXattrs(context.Background(), provider); err != nil {
+			_ = provider.Close()
+			return nil, err
+		}
+	}
+	provider.sweepTemp()
+	if options.Dedup {
+		provider.sweepBlobs()
+	}
+	return provider, nil
 }
 
 func (p *LocalStorageProvider) Close() error {
-	return p.root.Close()
+	return errors.Join(p.meta.Close(), p.root.Close())
+}
+
+// Seekable 本地对象可随机读取
+func (p *LocalStorageProvider) Seekable() bool {
+	return true
 }
 
 // SaveObject 保存对象到本地存储
-func (p *LocalStorageProvider) SaveObject(ctx context.Context, reader io.ReadSeeker, objectKey string) error {
+// 内容先写入内部临时文件并落盘，再以硬链接或重命名发布，读取方不会看到写了一半的对象
+func (p *LocalStorageProvider) SaveObject(ctx context.Context, reader io.ReadSeeker, objectKey string, options SaveObjectOptions) error {
 	key, isDir, err := NormalizeObjectKey(objectKey, false)
 	if err != nil || isDir {
 		return ErrInvalidObjectKey
@@ -53,39 +92,43 @@ func (p *LocalStorageProvider) SaveObject(ctx context.Context, reader io.ReadSee
 			return err
 		}
 	}
-
-	outFile, err := p.root.OpenFile(key, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
-	if errors.Is(err, fs.ErrExist) {
-		return ErrFileAlreadyExists
-	}
+	temp, meta, err := p.writeTemp(ctx, reader)
 	if err != nil {
 		return err
 	}
-	removePartial := func() {
-		_ = outFile.Close()
-		_ = p.root.Remove(key)
+	defer p.removeTemp(temp)
+	if options.SHA256 != "" && !strings.EqualFold(options.SHA256, meta.SHA256) {
+		return ErrChecksumMismatch
 	}
-	if _, err = io.Copy(outFile, reader); err != nil {
-		removePartial()
-		return err
-	}
-	if err = ctx.Err(); err != nil {
-		removePartial()
-		return err
+	meta.UploadedAt = time.Now()
+	if !options.UploadedAt.IsZero() {
+		meta.UploadedAt = options.UploadedAt
 	}
-
-	if xattr.XATTR_SUPPORTED {
-		if _, err := reader.Seek(0, io.SeekStart); err == nil {
-			if mime, err := mimetype.DetectReader(reader); err == nil {
-				_ = xattr.FSet(outFile, "user.mimetype", []byte(mime.String()))
-			}
+	// 启用去重时修改时间取自上传时间，不修改共享数据的文件时间
+	if p.options.Dedup {
+		if err := p.storeBlob(temp, meta.SHA256); err != nil {
+			return err
+		}
+	} else if !options.UploadedAt.IsZero() {
+		if err := p.root.Chtimes(temp, options.UploadedAt, options.UploadedAt); err != nil {
+			return err
 		}
 	}
-	if err = outFile.Close(); err != nil {
-		_ = p.root.Remove(key)
+	meta.OriginalName = options.OriginalName
+	meta.Uploader = options.Uploader
+	meta.Custom = options.Metadata
+	meta.Tags = options.Tags
+	if err := p.stampMetadata(temp, meta); err != nil {
 		return err
 	}
-	return nil
+	// 元数据写入失败时不发布对象，避免对象与元数据不一致
+	if err := p.meta.Put(temp, meta); err != nil {
+		return err
+	}
+	if options.Overwrite || options.IfMatch != "" || p.options.Versioning {
+		return p.replaceObject(key, temp, options)
+	}
+	return p.linkObject(key, temp)
 }
 
 // DeleteObject 删除对象，目标不存在时仍视为成功。
@@ -97,20 +140,33 @@ func (p *LocalStorageProvider) DeleteObject(ctx context.Context, objectKey strin
 	if err := ctx.Err(); err != nil {
 		return err
 	}
-	if err := p.root.RemoveAll(key); err != nil {
+	if p.options.Trash {
+		err = p.moveToTrash(ctx, key)
+	} else {
+		refs := p.blobRefs(key)
+		if err = p.root.RemoveAll(key); err == nil {
+			err = p.meta.Delete(key)
+			p.releaseBlobs(refs)
+		}
+	}
+	if err != nil {
 		return err
 	}
+	p.pruneEmptyParents(key)
+	return nil
+}
 
+// pruneEmptyParents 自下而上删除对象所在的空目录
+func (p *LocalStorageProvider) pruneEmptyParents(key string) {
 	for dir := path.Dir(key); dir != "."; dir = path.Dir(dir) {
 		if err := p.root.Remove(dir); err != nil {
 			break
 		}
 	}
-	return nil
 }
 
 // GetObject 获取对象
-func (p *LocalStorageProvider) GetObject(ctx context.Context, objectKey string, _ GetObjectOptions) (io.ReadCloser, *GetObjectInfo, error) {
+func (p *LocalStorageProvider) GetObject(ctx context.Context, objectKey string, options GetObjectOptions) (io.ReadCloser, *GetObjectInfo, error) {
 	key, isDir, err := NormalizeObjectKey(objectKey, false)
 	if err != nil || isDir {
 		return nil, nil, ErrInvalidObjectKey
@@ -118,14 +174,21 @@ func (p *LocalStorageProvider) GetObject(ctx context.Context, objectKey string,
 	if err := ctx.Err(); err != nil {
 		return nil, nil, err
 	}
-	file, err := p.root.Open(key)
+	name := key
+	if options.VersionID != "" {
+		if !validInternalID(options.VersionID) {
+			return nil, nil, ErrResourceNotExists
+		}
+		name = versionKey(key, options.VersionID)
+	}
+	file, err := p.root.Open(name)
 	if os.IsNotExist(err) {
 		return nil, nil, ErrResourceNotExists
 	}
 	if err != nil {
 		return nil, nil, err
 	}
-	info, err := objectInfoFromFile(file)
+	info, err := p.objectInfo(name, file)
 	if err != nil {
 		_ = file.Close()
 		return nil, nil, err
@@ -137,8 +200,8 @@ func (p *LocalStorageProvider) GetObject(ctx context.Context, objectKey string,
 	return file, info, nil
 }
 
-func (p *LocalStorageProvider) StatObject(ctx context.Context, objectKey string, _ GetObjectOptions) (*GetObjectInfo, error) {
-	reader, info, err := p.GetObject(ctx, objectKey, GetObjectOptions{})
+func (p *LocalStorageProvider) StatObject(ctx context.Context, objectKey string, options GetObjectOptions) (*GetObjectInfo, error) {
+	reader, info, err := p.GetObject(ctx, objectKey, GetObjectOptions{VersionID: options.VersionID})
 	if err != nil {
 		return nil, err
 	}
@@ -179,6 +242,9 @@ func (p *LocalStorageProvider) GetFileList(ctx context.Context, prefix string) (
 		if err := ctx.Err(); err != nil {
 			return nil, err
 		}
+		if key == "" && entry.Name() == internalDir {
+			continue
+		}
 		fileInfo, err := entry.Info()
 		if err != nil {
 			zap.L().Error("获取文件信息错误", zap.Error(err))
@@ -188,18 +254,81 @@ func (p *LocalStorageProvider) GetFileList(ctx context.Context, prefix string) (
 		if entry.IsDir() {
 			objectKey += "/"
 		}
-		list = append(list, FileListElement{
+		element := FileListElement{
 			Name:         fileInfo.Name(),
 			Size:         fileInfo.Size(),
-			Type:         p.getLocalFileType(objectKey, entry.IsDir()),
+			Type:         "dir",
 			LastModified: fileInfo.ModTime().Format(time.RFC3339),
 			ObjectKey:    objectKey,
-		})
+		}
+		if !entry.IsDir() {
+			meta := p.objectMetadata(objectKey, nil)
+			element.LastModified = p.lastModified(meta, fileInfo).Format(time.RFC3339)
+			element.Type = classifyMIME(meta.ContentType)
+			element.Metadata = meta.Custom
+			element.Tags = meta.Tags
+		}
+		list = append(list, element)
 	}
 	return list, nil
 }
 
-func objectInfoFromFile(file *os.File) (*GetObjectInfo, error) {
+// walkObjects 递归遍历前缀下的所有对象，不读取文件内容
+func (p *LocalStorageProvider) walkObjects(ctx context.Context, prefix string, fn func(ObjectEntry) error) error {
+	key, _, err := NormalizeObjectKey(prefix, true)
+	if err != nil {
+		return err
+	}
+	target := key
+	if target == "" {
+		target = "."
+	}
+	err = fs.WalkDir(p.root.FS(), target, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if name == internalDir {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		return fn(ObjectEntry{Key: name, Size: info.Size(), LastModified: info.ModTime()})
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// localChecksum 返回元数据记录的内容摘要。对象文件的大小或修改时间与写入元数据时不同，
+// 说明文件在存储之外被修改或是早期版本写入的，摘要不再可信，返回空字符串
+func localChecksum(meta *ObjectMetadata, stat fs.FileInfo) string {
+	if meta == nil || meta.Size != stat.Size() || meta.ModTime != stat.ModTime().UnixNano() {
+		return ""
+	}
+	return meta.SHA256
+}
+
+// stampMetadata 记录文件当前的大小与修改时间
+func (p *LocalStorageProvider) stampMetadata(name string, meta *ObjectMetadata) error {
+	stat, err := p.root.Lstat(name)
+	if err != nil {
+		return err
+	}
+	meta.Size = stat.Size()
+	meta.ModTime = stat.ModTime().UnixNano()
+	return nil
+}
+
+func (p *LocalStorageProvider) objectInfo(name string, file *os.File) (*GetObjectInfo, error) {
 	stat, err := file.Stat()
 	if err != nil {
 		return nil, err
@@ -207,53 +336,76 @@ func objectInfoFromFile(file *os.File) (*GetObjectInfo, error) {
 	if stat.IsDir() {
 		return nil, nil
 	}
+	meta := p.objectMetadata(name, file)
+	checksum := localChecksum(meta, stat)
 	return &GetObjectInfo{
-		ContentType:   getMimeType(file),
+		ContentType:   meta.ContentType,
+		Metadata:      meta.Custom,
 		ContentLength: stat.Size(),
 		AcceptRanges:  "bytes",
-		ETag:          fmt.Sprintf(`W/"%x-%x"`, stat.ModTime().UnixNano(), stat.Size()),
-		LastModified:  stat.ModTime(),
+		ETag:          localETag(checksum, stat),
+		SHA256:        checksum,
+		LastModified:  p.lastModified(meta, stat),
 	}, nil
 }
 
-func getMimeType(file *os.File) string {
-	if xattr.XATTR_SUPPORTED {
-		if value, err := xattr.FGet(file, "user.mimetype"); err == nil && len(value) > 0 {
-			return string(value)
-		}
+// lastModified 去重模式下多个对象共享同一文件的修改时间，改用元数据中记录的上传时间
+func (p *LocalStorageProvider) lastModified(meta *ObjectMetadata, stat fs.FileInfo) time.Time {
+	if p.options.Dedup && !meta.UploadedAt.IsZero() {
+		return meta.UploadedAt
 	}
-	_, _ = file.Seek(0, io.SeekStart)
-	mime, err := mimetype.DetectReader(file)
-	_, _ = file.Seek(0, io.SeekStart)
-	if err != nil {
-		return "application/octet-stream"
-	}
-	return mime.String()
+	return stat.ModTime()
 }
 
-func (p *LocalStorageProvider) getLocalFileType(objectKey string, isDir bool) string {
-	if isDir {
-		return "dir"
+// localETag 已知内容摘要时返回强 ETag，否则根据修改时间与大小生成弱 ETag
+func localETag(sha256 string, stat fs.FileInfo) string {
+	if sha256 != "" {
+		return `"` + sha256 + `"`
 	}
-	key, _, err := NormalizeObjectKey(objectKey, false)
+	return fmt.Sprintf(`W/"%x-%x"`, stat.ModTime().UnixNano(), stat.Size())
+}
+
+// objectMetadata 从元数据存储读取对象元数据，缺少内容类型时探测文件内容并回写。file 为空时按需打开。
+func (p *LocalStorageProvider) objectMetadata(name string, file *os.File) *ObjectMetadata {
+	meta, err := p.meta.Get(name)
 	if err != nil {
-		return "binary"
+		zap.L().Warn("读取对象元数据失败", zap.String("name", name), zap.Error(err))
 	}
-	file, err := p.root.Open(key)
-	if err != nil {
-		return "binary"
+	if meta == nil {
+		meta = &ObjectMetadata{}
+	}
+	if meta.ContentType != "" {
+		return meta
+	}
+	meta.ContentType = "application/octet-stream"
+	if file == nil {
+		opened, err := p.root.Open(name)
+		if err != nil {
+			return meta
+		}
+		defer func() { _ = opened.Close() }()
+		file = opened
+	}
+	meta.ContentType = detectMimeType(file)
+	if err := p.meta.PutContentType(name, meta.ContentType); err != nil {
+		zap.L().Debug("回写内容类型失败", zap.String("name", name), zap.Error(err))
+	}
+	return meta
+}
+
+// putMetadata 写入对象元数据，失败时读取方会退化为探测内容
+func (p *LocalStorageProvider) putMetadata(name string, meta *ObjectMetadata) {
+	if err := p.meta.Put(name, meta); err != nil {
+		zap.L().Warn("写入对象元数据失败", zap.String("name", name), zap.Error(err))
 	}
-	defer func() { _ = file.Close() }()
+}
 
-	mimeType := getMimeType(file)
-	switch {
-	case strings.HasPrefix(mimeType, "text/"):
-		return "text"
-	case mimeType == "application/json":
-		return "json"
-	case strings.HasPrefix(mimeType, "image/"):
-		return "image"
-	default:
-		return "binary"
+func detectMimeType(file io.ReadSeeker) string {
+	_, _ = file.Seek(0, io.SeekStart)
+	mime, err := mimetype.DetectReader(file)
+	_, _ = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return "application/octet-stream"
 	}
+	return mime.String()
 }
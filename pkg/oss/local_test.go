@@ -0,0 +1,47 @@
+package oss_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cube-go/pkg/oss"
+)
+
+func TestLocalETagAfterExternalChange(t *testing.T) {
+	p, dir := newLocalProviderDir(t, oss.LocalStorageOptions{Metadata: "bolt"})
+	ctx := context.Background()
+	if err := p.SaveObject(ctx, strings.NewReader("hello"), "a.txt", oss.SaveObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := p.StatObject(ctx, "a.txt", oss.GetObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.HasPrefix(info.ETag, "W/") || info.SHA256 == "" {
+		t.Fatalf("ETag = %q, SHA256 = %q, want strong ETag", info.ETag, info.SHA256)
+	}
+
+	// 在存储之外以相同大小改写内容
+	name := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(name, []byte("world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(name, time.Now(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := p.StatObject(ctx, "a.txt", oss.GetObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(changed.ETag, "W/") || changed.SHA256 != "" {
+		t.Errorf("after external change ETag = %q, SHA256 = %q, want weak ETag without checksum", changed.ETag, changed.SHA256)
+	}
+	err = p.SaveObject(ctx, strings.NewReader("again"), "a.txt", oss.SaveObjectOptions{IfMatch: info.ETag})
+	if err == nil {
+		t.Error("If-Match with the stale ETag succeeded")
+	}
+}
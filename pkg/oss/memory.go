@@ -0,0 +1,393 @@
+package oss
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryConfig struct {
+	MaxSize int64 `mapstructure:"maxSize"` // 容量上限，单位 MB，0 表示不限制
+}
+
+// MemoryStorageProvider 内存存储提供者，进程退出后数据丢失，适用于测试与临时存储桶。
+// 目录由对象键隐式构成，行为与本地存储一致；自行处理条件与范围请求。
+type MemoryStorageProvider struct {
+	options MemoryStorageOptions
+	mu      sync.RWMutex
+	objects map[string]*memoryObject
+	size    int64
+}
+
+// MemoryStorageOptions 内存存储提供者选项
+type MemoryStorageOptions struct {
+	MaxBytes int64 // 对象内容总字节数上限，0 表示不限制
+}
+
+type memoryObject struct {
+	data         []byte // 保存后不再修改，读取方可直接引用
+	contentType  string
+	sha256       string
+	lastModified time.Time
+	metadata     map[string]string
+	tags         map[string]string
+}
+
+func (o *memoryObject) objectInfo() *GetObjectInfo {
+	return &GetObjectInfo{
+		ContentType:   o.contentType,
+		ContentLength: int64(len(o.data)),
+		AcceptRanges:  "bytes",
+		ETag:          `"` + o.sha256 + `"`,
+		LastModified:  o.lastModified,
+		Metadata:      o.metadata,
+		SHA256:        o.sha256,
+	}
+}
+
+// memoryReader 内存对象读取器，关闭为空操作
+type memoryReader struct {
+	*bytes.Reader
+}
+
+func (memoryReader) Close() error {
+	return nil
+}
+
+// NewMemoryStorageProvider 创建内存存储提供者
+func NewMemoryStorageProvider(options MemoryStorageOptions) *MemoryStorageProvider {
+	return &MemoryStorageProvider{options: options, objects: make(map[string]*memoryObject)}
+}
+
+// SaveObject 保存对象，目标或其上级路径与已有目录、对象冲突时返回 ErrFileAlreadyExists
+func (p *MemoryStorageProvider) SaveObject(ctx context.Context, reader io.ReadSeeker, objectKey string, options SaveObjectOptions) error {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return ErrInvalidObjectKey
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	object := &memoryObject{
+		data:         data,
+		contentType:  detectMimeType(bytes.NewReader(data)),
+		sha256:       hex.EncodeToString(sum[:]),
+		lastModified: cmp.Or(options.UploadedAt, time.Now()),
+		metadata:     options.Metadata,
+		tags:         options.Tags,
+	}
+	if options.SHA256 != "" && !strings.EqualFold(options.SHA256, object.sha256) {
+		return ErrChecksumMismatch
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.isDir(key) {
+		return ErrFileAlreadyExists
+	}
+	for dir := path.Dir(key); dir != "."; dir = path.Dir(dir) {
+		if _, exists := p.objects[dir]; exists {
+			return ErrFileAlreadyExists
+		}
+	}
+	current, exists := p.objects[key]
+	switch {
+	case !exists && options.IfMatch != "":
+		return ErrPreconditionFailed
+	case exists && !options.Overwrite && options.IfMatch == "":
+		return ErrFileAlreadyExists
+	case exists && options.IfMatch != "" && !etagMatches(options.IfMatch, current.objectInfo().ETag):
+		return ErrPreconditionFailed
+	}
+	size := p.size + int64(len(data))
+	if exists {
+		size -= int64(len(current.data))
+	}
+	if p.options.MaxBytes > 0 && size > p.options.MaxBytes {
+		return ErrQuotaExceeded
+	}
+	p.objects[key] = object
+	p.size = size
+	return nil
+}
+
+// rewriteContent 以新内容替换对象，元数据、标签与修改时间保持不变
+func (p *MemoryStorageProvider) rewriteContent(ctx context.Context, objectKey string, reader io.ReadSeeker, ifMatch string) error {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return ErrInvalidObjectKey
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	current, exists := p.objects[key]
+	if !exists {
+		return ErrResourceNotExists
+	}
+	if ifMatch != "" && !etagMatches(ifMatch, current.objectInfo().ETag) {
+		return ErrPreconditionFailed
+	}
+	size := p.size + int64(len(data)) - int64(len(current.data))
+	if p.options.MaxBytes > 0 && size > p.options.MaxBytes {
+		return ErrQuotaExceeded
+	}
+	rewritten := *current
+	rewritten.data = data
+	rewritten.sha256 = hex.EncodeToString(sum[:])
+	p.objects[key] = &rewritten
+	p.size = size
+	return nil
+}
+
+// DeleteObject 删除对象或整个目录，目标不存在时仍视为成功
+func (p *MemoryStorageProvider) DeleteObject(ctx context.Context, objectKey string) error {
+	key, _, err := NormalizeObjectKey(objectKey, false)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for k, object := range p.objects {
+		if k == key || strings.HasPrefix(k, key+"/") {
+			p.size -= int64(len(object.data))
+			delete(p.objects, k)
+		}
+	}
+	return nil
+}
+
+// GetObject 获取对象，按 S3 的规则处理条件与范围请求
+func (p *MemoryStorageProvider) GetObject(ctx context.Context, objectKey string, options GetObjectOptions) (io.ReadCloser, *GetObjectInfo, error) {
+	data, info, err := p.read(ctx, objectKey, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return memoryReader{bytes.NewReader(data)}, info, nil
+}
+
+func (p *MemoryStorageProvider) StatObject(ctx context.Context, objectKey string, options GetObjectOptions) (*GetObjectInfo, error) {
+	_, info, err := p.read(ctx, objectKey, options)
+	return info, err
+}
+
+// read 取得对象内容中请求的范围及对应的对象信息
+func (p *MemoryStorageProvider) read(ctx context.Context, objectKey string, options GetObjectOptions) ([]byte, *GetObjectInfo, error) {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return nil, nil, ErrInvalidObjectKey
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if options.VersionID != "" {
+		return nil, nil, ErrResourceNotExists
+	}
+	p.mu.RLock()
+	object, exists := p.objects[key]
+	p.mu.RUnlock()
+	if !exists {
+		return nil, nil, ErrResourceNotExists
+	}
+
+	info := object.objectInfo()
+	if err := checkConditions(options.Conditions, info); err != nil {
+		return nil, nil, &ObjectResponseError{Err: err, Info: info}
+	}
+	if options.Range == "" {
+		return object.data, info, nil
+	}
+	size := int64(len(object.data))
+	start, length, ok := parseByteRange(options.Range, size)
+	if !ok {
+		info.ContentRange = "bytes */" + strconv.FormatInt(size, 10)
+		return nil, nil, &ObjectResponseError{Err: ErrInvalidRange, Info: info}
+	}
+	info.ContentLength = length
+	info.ContentRange = "bytes " + strconv.FormatInt(start, 10) + "-" + strconv.FormatInt(start+length-1, 10) + "/" + strconv.FormatInt(size, 10)
+	return object.data[start : start+length], info, nil
+}
+
+// GetFileList 获取目录下的直接子项，目录的大小为其中对象的总大小
+func (p *MemoryStorageProvider) GetFileList(ctx context.Context, prefix string) ([]FileListElement, error) {
+	key, _, err := NormalizeObjectKey(prefix, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if _, exists := p.objects[key]; exists {
+		return nil, ErrPathIsNotDir
+	}
+
+	children := make(map[string]*FileListElement)
+	for k, object := range p.objects {
+		rest, ok := strings.CutPrefix(k, key+"/")
+		if key == "" {
+			rest, ok = k, true
+		}
+		if !ok {
+			continue
+		}
+		name, _, nested := strings.Cut(rest, "/")
+		modified := object.lastModified.Format(time.RFC3339)
+		if !nested {
+			children[name] = &FileListElement{
+				Name:         name,
+				Size:         int64(len(object.data)),
+				Type:         classifyMIME(object.contentType),
+				LastModified: modified,
+				ObjectKey:    path.Join(key, name),
+				Metadata:     object.metadata,
+				Tags:         object.tags,
+			}
+			continue
+		}
+		element, exists := children[name]
+		if !exists {
+			element = &FileListElement{Name: name, Type: "dir", ObjectKey: path.Join(key, name) + "/"}
+			children[name] = element
+		}
+		element.Size += int64(len(object.data))
+		element.LastModified = max(element.LastModified, modified)
+	}
+	list := make([]FileListElement, 0, len(children))
+	for _, name := range slices.Sorted(maps.Keys(children)) {
+		list = append(list, *children[name])
+	}
+	return list, nil
+}
+
+func (p *MemoryStorageProvider) GetMetadata(ctx context.Context, objectKey string) (*UserMetadata, error) {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return nil, ErrInvalidObjectKey
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	object, exists := p.objects[key]
+	if !exists {
+		return nil, ErrResourceNotExists
+	}
+	return &UserMetadata{Metadata: object.metadata, Tags: object.tags}, nil
+}
+
+// UpdateMetadata 更新对象的自定义元数据与标签，内容与修改时间不变
+func (p *MemoryStorageProvider) UpdateMetadata(ctx context.Context, objectKey string, update UserMetadata) error {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return ErrInvalidObjectKey
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	object, exists := p.objects[key]
+	if !exists {
+		return ErrResourceNotExists
+	}
+	updated := *object
+	if update.Metadata != nil {
+		updated.metadata = update.Metadata
+	}
+	if update.Tags != nil {
+		updated.tags = update.Tags
+	}
+	p.objects[key] = &updated
+	return nil
+}
+
+func (p *MemoryStorageProvider) walkObjects(ctx context.Context, prefix string, fn func(ObjectEntry) error) error {
+	key, _, err := NormalizeObjectKey(prefix, true)
+	if err != nil {
+		return err
+	}
+	p.mu.RLock()
+	var entries []ObjectEntry
+	for k, object := range p.objects {
+		if key == "" || k == key || strings.HasPrefix(k, key+"/") {
+			entries = append(entries, ObjectEntry{Key: k, Size: int64(len(object.data)), LastModified: object.lastModified})
+		}
+	}
+	p.mu.RUnlock()
+	slices.SortFunc(entries, func(a, b ObjectEntry) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isDir 判断 key 下是否存在对象，需持有锁
+func (p *MemoryStorageProvider) isDir(key string) bool {
+	for k := range p.objects {
+		if strings.HasPrefix(k, key+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkConditions 按 RFC 9110 的顺序判断请求条件，时间精确到秒
+func checkConditions(conditions ObjectConditions, info *GetObjectInfo) error {
+	modified := info.LastModified.Truncate(time.Second)
+	if conditions.IfMatch != "" {
+		if !etagMatches(conditions.IfMatch, info.ETag) {
+			return ErrPreconditionFailed
+		}
+	} else if conditions.IfUnmodifiedSince != nil && modified.After(*conditions.IfUnmodifiedSince) {
+		return ErrPreconditionFailed
+	}
+	if conditions.IfNoneMatch != "" {
+		if etagMatchesWeak(conditions.IfNoneMatch, info.ETag) {
+			return ErrNotModified
+		}
+	} else if conditions.IfModifiedSince != nil && !modified.After(*conditions.IfModifiedSince) {
+		return ErrNotModified
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// UserMetadata 用户自定义元数据与标签
+type UserMetadata struct {
+	Metadata map[string]string `json:"metadata"`
+	Tags     map[string]string `json:"tags"`
+}
+
+// MetadataProvider 由支持自定义元数据与标签的存储提供者实现
+type MetadataProvider interface {
+	GetMetadata(ctx context.Context, objectKey string) (*UserMetadata, error)
+	// UpdateMetadata 替换对象的元数据与标签，为 nil 的字段保持不变
+	UpdateMetadata(ctx context.Context, objectKey string, update UserMetadata) error
+}
+
+var (
+	// ErrInvalidMetadata 自定义元数据或标签不合法
+	ErrInvalidMetadata = errors.New("invalid metadata")
+	// ErrMetadataNotSupported 存储桶不支持自定义元数据
+	ErrMetadataNotSupported = errors.New("metadata not supported")
+)
+
+// 限制与 S3 保持一致，保证两类存储桶行为相同
+const (
+	maxMetadataSize   = 2048 // 元数据键值总字节数上限
+	maxTags           = 10
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+var (
+	metadataKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+	tagPattern         = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+)
+
+// NormalizeUserMetadata 将元数据键转为小写并校验元数据与标签
+func NormalizeUserMetadata(m UserMetadata) (UserMetadata, error) {
+	var normalized UserMetadata
+	if m.Metadata != nil {
+		normalized.Metadata = make(map[string]string, len(m.Metadata))
+		size := 0
+		for key, value := range m.Metadata {
+			key = strings.ToLower(strings.TrimSpace(key))
+			if !metadataKeyPattern.MatchString(key) || !utf8.ValidString(value) {
+				return UserMetadata{}, ErrInvalidMetadata
+			}
+			size += len(key) + len(value)
+			normalized.Metadata[key] = value
+		}
+		if size > maxMetadataSize {
+			return UserMetadata{}, ErrInvalidMetadata
+		}
+	}
+	if m.Tags != nil {
+		if len(m.Tags) > maxTags {
+			return UserMetadata{}, ErrInvalidMetadata
+		}
+		for key, value := range m.Tags {
+			if key == "" || utf8.RuneCountInString(key) > maxTagKeyLength || utf8.RuneCountInString(value) > maxTagValueLength ||
+				!tagPattern.MatchString(key) || !tagPattern.MatchString(value) {
+				return UserMetadata{}, ErrInvalidMetadata
+			}
+		}
+		normalized.Tags = m.Tags
+	}
+	return normalized, nil
+}
+
+// encodeMetadataValue 以 RFC 2047 编码非 ASCII 元数据值，以便放入 HTTP 头
+func encodeMetadataValue(value string) string {
+	return mime.QEncoding.Encode("utf-8", value)
+}
+
+func decodeMetadataValue(value string) string {
+	decoded, err := new(mime.WordDecoder).DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
@@ -0,0 +1,92 @@
+package oss
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+// Scrub 依次巡检各副本。fix 为 true 时，副本中校验和不一致且无法自行修复的对象
+// 以其他副本中内容与记录的校验和一致的一份替换
+func (p *MirrorProvider) Scrub(ctx context.Context, fix bool) (*ScrubResult, error) {
+	result := &ScrubResult{Issues: []ScrubIssue{}}
+	for i, replica := range p.replicas {
+		scrubber, ok := As[ScrubProvider](replica)
+		if !ok {
+			continue
+		}
+		replicaResult, err := scrubber.Scrub(ctx, fix)
+		if err != nil {
+			return nil, err
+		}
+		result.Objects = max(result.Objects, replicaResult.Objects)
+		result.Bytes = max(result.Bytes, replicaResult.Bytes)
+		for _, issue := range replicaResult.Issues {
+			issue.Replica = p.names[i]
+			if fix && !issue.Fixed && issue.Kind == ScrubChecksumMismatch && issue.Expected != "" {
+				if source, err := p.repairReplica(ctx, i, issue.ObjectKey, issue.Expected); err != nil {
+					issue.Detail += ", repair failed: " + err.Error()
+				} else {
+					issue.Detail += ", restored from replica " + source
+					issue.Fixed = true
+					p.notifyChange(issue.ObjectKey)
+				}
+			}
+			result.Issues = append(result.Issues, issue)
+		}
+	}
+	return result, nil
+}
+
+// errNoHealthyReplica 其他副本中都没有内容与记录的校验和一致的对象
+var errNoHealthyReplica = errors.New("no replica holds the expected content")
+
+// repairReplica 从其他副本复制内容为 sum 的对象覆盖第 index 个副本，返回来源副本名称
+func (p *MirrorProvider) repairReplica(ctx context.Context, index int, key, sum string) (string, error) {
+	for i, replica := range p.replicas {
+		if i == index {
+			continue
+		}
+		err := copyObjectExpecting(ctx, replica, p.replicas[index], key, sum)
+		if errors.Is(err, ErrChecksumMismatch) || errors.Is(err, ErrResourceNotExists) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		// 去重的本地副本可能把新内容链接到同摘要的损坏 blob，写入后重新校验
+		if err := verifyChecksum(ctx, p.replicas[index], key, sum); err != nil {
+			return "", err
+		}
+		return p.names[i], nil
+	}
+	return "", errNoHealthyReplica
+}
+
+// verifyChecksum 读取对象并检查内容的 SHA-256
+func verifyChecksum(ctx context.Context, provider StorageProvider, key, sum string) error {
+	actual, err := contentChecksum(ctx, provider, key)
+	if err != nil {
+		return err
+	}
+	if actual != sum {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// contentChecksum 读取对象并计算内容的 SHA-256
+func contentChecksum(ctx context.Context, provider StorageProvider, key string) (string, error) {
+	reader, _, err := provider.GetObject(ctx, key, GetObjectOptions{})
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = reader.Close() }()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,481 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type mirrorConfig struct {
+	Replicas          []string `mapstructure:"replicas"`          // 组成镜像的存储桶，第一个为主副本
+	Mode              string   `mapstructure:"mode"`              // 复制方式：sync 或 async
+	QueueSize         int      `mapstructure:"queueSize"`         // 异步复制队列长度
+	ReconcileInterval int      `mapstructure:"reconcileInterval"` // 校准间隔，单位分钟，0 表示只手动校准
+	DeleteExtra       bool     `mapstructure:"deleteExtra"`       // 校准时删除副本中主副本没有的对象，默认只报告
+}
+
+// 镜像复制方式
+const (
+	MirrorSync  = "sync"  // 写入返回前复制到所有副本
+	MirrorAsync = "async" // 写入主副本后由后台队列复制
+)
+
+const (
+	defaultMirrorQueueSize = 1024
+	mirrorDrainTimeout     = 30 * time.Second // 关闭时处理队列中剩余任务的时限
+)
+
+// ReconcileHook 定期校准完成后调用，参数为镜像存储桶名称与校准结果，用于记录审计日志；需在 Init 之前设置
+var ReconcileHook func(bucket string, report *MirrorReport)
+
+var (
+	// ErrInvalidMirror 镜像存储桶配置不正确
+	ErrInvalidMirror = errors.New("mirror bucket requires at least two non-mirror replicas")
+	// ErrUnknownMirrorMode 未知镜像复制方式
+	ErrUnknownMirrorMode = errors.New("unknown mirror mode")
+	// ErrNotMirror 存储桶不是镜像存储桶
+	ErrNotMirror = errors.New("not a mirror bucket")
+)
+
+type mirrorTask struct {
+	key    string
+	delete bool
+}
+
+// MirrorReport 镜像校准结果
+type MirrorReport struct {
+	Checked int               `json:"checked"` // 主副本中的对象数
+	Copied  int               `json:"copied"`  // 复制到副本的对象数
+	Deleted int               `json:"deleted"` // 从副本删除的多余对象数
+	Extra   []MirrorExtra     `json:"extra"`   // 副本中主副本没有的对象
+	Failed  map[string]string `json:"failed"`  // 以“副本/对象键”为键
+}
+
+// MirrorExtra 副本中主副本没有的对象
+type MirrorExtra struct {
+	Replica   string `json:"replica"`
+	ObjectKey string `json:"object_key"`
+	Deleted   bool   `json:"deleted"` // 启用 deleteExtra 时已从副本删除
+}
+
+// MirrorStatus 镜像存储桶状态
+type MirrorStatus struct {
+	Replicas []string `json:"replicas"`
+	Mode     string   `json:"mode"`
+	Pending  int      `json:"pending"`  // 异步队列中待复制的任务数
+	Failures int64    `json:"failures"` // 启动以来复制到副本失败的次数，差异由校准修复
+}
+
+// MirrorProvider 由多个存储桶组成的镜像存储桶。
+// 写入先落到主副本，再同步或经异步队列复制到其余副本；读取在主副本出错时依次切换到其余副本。
+// 副本之间的差异由校准任务以主副本为准修复。
+type MirrorProvider struct {
+	names       []string
+	replicas    []StorageProvider
+	mode        string
+	deleteExtra bool
+	queue       chan mirrorTask
+	failures    atomic.Int64
+	changeNotifier
+	reconciling sync.Mutex
+}
+
+// NewMirrorProvider 创建镜像存储提供者，replicas 与 names 一一对应
+func NewMirrorProvider(names []string, replicas []StorageProvider, options mirrorConfig) (*MirrorProvider, error) {
+	if len(replicas) < 2 || len(names) != len(replicas) {
+		return nil, ErrInvalidMirror
+	}
+	for _, replica := range replicas {
+		if _, ok := As[*MirrorProvider](replica); ok {
+			return nil, ErrInvalidMirror
+		}
+	}
+	mode := options.Mode
+	if mode == "" {
+		mode = MirrorSync
+	}
+	if mode != MirrorSync && mode != MirrorAsync {
+		return nil, ErrUnknownMirrorMode
+	}
+	p := &MirrorProvider{names: names, replicas: replicas, mode: mode, deleteExtra: options.DeleteExtra}
+	if mode == MirrorAsync {
+		size := options.QueueSize
+		if size <= 0 {
+			size = defaultMirrorQueueSize
+		}
+		p.queue = make(chan mirrorTask, size)
+	}
+	return p, nil
+}
+
+func (p *MirrorProvider) primary() StorageProvider {
+	return p.replicas[0]
+}
+
+// Seekable 与主副本一致，切换到不可随机读取的副本时会先缓存到临时文件
+func (p *MirrorProvider) Seekable() bool {
+	return IsSeekable(p.primary())
+}
+
+// Status 返回镜像存储桶状态
+func (p *MirrorProvider) Status() MirrorStatus {
+	return MirrorStatus{Replicas: p.names, Mode: p.mode, Pending: len(p.queue), Failures: p.failures.Load()}
+}
+
+// SaveObject 保存到主副本后复制到其余副本。
+// 对象以主副本为准，副本写入失败时仍返回成功，只记录日志并计入 Status 的失败次数，由校准修复；
+// 未配置定期校准时需手动校准
+func (p *MirrorProvider) SaveObject(ctx context.Context, reader io.ReadSeeker, objectKey string, options SaveObjectOptions) error {
+	if err := p.primary().SaveObject(ctx, reader, objectKey, options); err != nil {
+		return err
+	}
+	if p.mode == MirrorAsync {
+		p.enqueue(mirrorTask{key: objectKey})
+		return nil
+	}
+	options.Overwrite, options.IfMatch = true, ""
+	for i := 1; i < len(p.replicas); i++ {
+		_, err := reader.Seek(0, io.SeekStart)
+		if err == nil {
+			err = saveCopy(ctx, p.replicas[i], reader, objectKey, options)
+		}
+		if err != nil {
+			p.replicaFailed("镜像复制失败", i, objectKey, err)
+		}
+	}
+	return nil
+}
+
+// DeleteObject 从主副本删除后同步或异步删除其余副本中的对象
+func (p *MirrorProvider) DeleteObject(ctx context.Context, objectKey string) error {
+	if err := p.primary().DeleteObject(ctx, objectKey); err != nil {
+		return err
+	}
+	if p.mode == MirrorAsync {
+		p.enqueue(mirrorTask{key: objectKey, delete: true})
+		return nil
+	}
+	for i := 1; i < len(p.replicas); i++ {
+		if err := p.replicas[i].DeleteObject(ctx, objectKey); err != nil {
+			p.replicaFailed("镜像删除失败", i, objectKey, err)
+		}
+	}
+	return nil
+}
+
+// GetObject 获取对象，主副本出错时切换到其余副本
+func (p *MirrorProvider) GetObject(ctx context.Context, objectKey string, options GetObjectOptions) (io.ReadCloser, *GetObjectInfo, error) {
+	var lastErr error
+	for i, replica := range p.replicas {
+		reader, info, err := replica.GetObject(ctx, objectKey, options)
+		if err == nil {
+			if i > 0 && p.Seekable() && !IsSeekable(replica) {
+				return spoolObject(reader, info)
+			}
+			return reader, info, nil
+		}
+		if !p.failover(ctx, i, err) {
+			return nil, nil, err
+		}
+		lastErr = err
+	}
+	return nil, nil, lastErr
+}
+
+func (p *MirrorProvider) StatObject(ctx context.Context, objectKey string, options GetObjectOptions) (*GetObjectInfo, error) {
+	var lastErr error
+	for i, replica := range p.replicas {
+		info, err := replica.StatObject(ctx, objectKey, options)
+		if err == nil || !p.failover(ctx, i, err) {
+			return info, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// GetFileList 获取文件列表，主副本出错时切换到其余副本
+func (p *MirrorProvider) GetFileList(ctx context.Context, prefix string) ([]FileListElement, error) {
+	var lastErr error
+	for i, replica := range p.replicas {
+		list, err := replica.GetFileList(ctx, prefix)
+		if err == nil || !p.failover(ctx, i, err) {
+			return list, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// GetMetadata 获取对象的自定义元数据与标签，主副本出错时切换到其余副本
+func (p *MirrorProvider) GetMetadata(ctx context.Context, objectKey string) (*UserMetadata, error) {
+	lastErr := ErrMetadataNotSupported
+	for i, replica := range p.replicas {
+		metadata, ok := As[MetadataProvider](replica)
+		if !ok {
+			continue
+		}
+		result, err := metadata.GetMetadata(ctx, objectKey)
+		if err == nil || !p.failover(ctx, i, err) {
+			return result, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// UpdateMetadata 更新主副本的元数据后同步到其余副本
+func (p *MirrorProvider) UpdateMetadata(ctx context.Context, objectKey string, update UserMetadata) error {
+	metadata, ok := As[MetadataProvider](p.primary())
+	if !ok {
+		return ErrMetadataNotSupported
+	}
+	if err := metadata.UpdateMetadata(ctx, objectKey, update); err != nil {
+		return err
+	}
+	for i := 1; i < len(p.replicas); i++ {
+		if metadata, ok := As[MetadataProvider](p.replicas[i]); ok {
+			if err := metadata.UpdateMetadata(ctx, objectKey, update); err != nil {
+				p.replicaFailed("镜像元数据同步失败", i, objectKey, err)
+			}
+		}
+	}
+	return nil
+}
+
+// failover 判断读取错误是否应切换到下一个副本，对象不存在等由请求本身导致的错误不切换
+func (p *MirrorProvider) failover(ctx context.Context, index int, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrResourceNotExists) || errors.Is(err, ErrNotModified) ||
+		errors.Is(err, ErrPreconditionFailed) || errors.Is(err, ErrInvalidRange) ||
+		errors.Is(err, ErrInvalidObjectKey) || errors.Is(err, ErrPathIsNotDir) {
+		return false
+	}
+	zap.L().Warn("镜像副本读取失败，切换到下一个副本", zap.String("replica", p.names[index]), zap.Error(err))
+	return true
+}
+
+func (p *MirrorProvider) enqueue(task mirrorTask) {
+	select {
+	case p.queue <- task:
+	default:
+		zap.L().Warn("镜像复制队列已满，等待校准修复", zap.String("key", task.key))
+	}
+}
+
+// replicate 执行异步复制任务
+func (p *MirrorProvider) replicate(ctx context.Context, task mirrorTask) {
+	for i := 1; i < len(p.replicas); i++ {
+		var err error
+		if task.delete {
+			err = p.replicas[i].DeleteObject(ctx, task.key)
+		} else {
+			err = copyObject(ctx, p.primary(), p.replicas[i], task.key)
+		}
+		if err != nil && !errors.Is(err, ErrResourceNotExists) {
+			p.replicaFailed("镜像复制失败", i, task.key, err)
+		}
+	}
+}
+
+// replicaFailed 记录写入副本失败，主副本的写入不受影响
+func (p *MirrorProvider) replicaFailed(msg string, index int, key string, err error) {
+	p.failures.Add(1)
+	zap.L().Error(msg, zap.String("replica", p.names[index]), zap.String("key", key), zap.Error(err))
+}
+
+// Reconcile 以主副本为准修复各副本：补齐缺失或内容不同的对象。
+// 大小相同的对象比较 SHA-256，任一侧未记录摘要时读取内容计算；
+// 副本中多余的对象默认只报告，启用 deleteExtra 时才删除，避免主副本丢失数据时连带清空其余副本
+func (p *MirrorProvider) Reconcile(ctx context.Context) (*MirrorReport, error) {
+	p.reconciling.Lock()
+	defer p.reconciling.Unlock()
+
+	source, err := collectObjects(ctx, p.primary())
+	if err != nil {
+		return nil, err
+	}
+	report := &MirrorReport{Checked: len(source), Extra: []MirrorExtra{}, Failed: make(map[string]string)}
+	for i := 1; i < len(p.replicas); i++ {
+		target, err := collectObjects(ctx, p.replicas[i])
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			report.Failed[p.names[i]] = err.Error()
+			continue
+		}
+		for key, entry := range source {
+			current, exists := target[key]
+			if exists && current.Size == entry.Size {
+				differs, err := p.checksumDiffers(ctx, key, i)
+				if err != nil {
+					if ctx.Err() != nil {
+						return nil, ctx.Err()
+					}
+					report.Failed[p.names[i]+"/"+key] = err.Error()
+					continue
+				}
+				if !differs {
+					continue
+				}
+			}
+			if err := copyObject(ctx, p.primary(), p.replicas[i], key); err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				report.Failed[p.names[i]+"/"+key] = err.Error()
+				continue
+			}
+			report.Copied++
+		}
+		for key := range target {
+			if _, exists := source[key]; exists {
+				continue
+			}
+			extra := MirrorExtra{Replica: p.names[i], ObjectKey: key}
+			if p.deleteExtra {
+				if err := p.replicas[i].DeleteObject(ctx, key); err != nil {
+					if ctx.Err() != nil {
+						return nil, ctx.Err()
+					}
+					report.Failed[p.names[i]+"/"+key] = err.Error()
+					continue
+				}
+				extra.Deleted = true
+				report.Deleted++
+			}
+			report.Extra = append(report.Extra, extra)
+		}
+	}
+	return report, nil
+}
+
+// checksumDiffers 比较主副本与副本中对象的 SHA-256
+func (p *MirrorProvider) checksumDiffers(ctx context.Context, key string, index int) (bool, error) {
+	source, err := objectChecksum(ctx, p.primary(), key)
+	if err != nil {
+		return false, err
+	}
+	target, err := objectChecksum(ctx, p.replicas[index], key)
+	if err != nil {
+		return false, err
+	}
+	return source != target, nil
+}
+
+// objectChecksum 返回对象记录的 SHA-256，未记录时读取内容计算
+func objectChecksum(ctx context.Context, provider StorageProvider, key string) (string, error) {
+	info, err := provider.StatObject(ctx, key, GetObjectOptions{})
+	if err != nil {
+		return "", err
+	}
+	if info.SHA256 != "" {
+		return strings.ToLower(info.SHA256), nil
+	}
+	return contentChecksum(ctx, provider, key)
+}
+
+func collectObjects(ctx context.Context, provider StorageProvider) (map[string]ObjectEntry, error) {
+	objects := make(map[string]ObjectEntry)
+	err := WalkObjects(ctx, provider, "", func(entry ObjectEntry) error {
+		objects[entry.Key] = entry
+		return nil
+	})
+	return objects, err
+}
+
+// mirrorReplicator 执行异步复制队列与定期校准
+type mirrorReplicator struct {
+	name     string
+	provider *MirrorProvider
+	interval time.Duration
+	stop     chan struct{}
+	ctx      context.Context // 校准使用，关闭时取消
+	cancel   context.CancelFunc
+	drain    context.Context // 复制任务使用，关闭后超过 mirrorDrainTimeout 时取消
+	abort    context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func startMirrorReplicator(provider *MirrorProvider, name string, reconcileMinutes int) *mirrorReplicator {
+	r := &mirrorReplicator{
+		name:     name,
+		provider: provider,
+		interval: time.Duration(reconcileMinutes) * time.Minute,
+		stop:     make(chan struct{}),
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	r.drain, r.abort = context.WithCancel(context.Background())
+	if provider.queue != nil {
+		r.wg.Add(1)
+		go r.work()
+	}
+	if r.interval > 0 {
+		r.wg.Add(1)
+		go r.reconcileLoop()
+	}
+	return r
+}
+
+// work 依次执行复制任务，关闭时在时限内处理队列中剩余的任务，未完成的由下次校准修复
+func (r *mirrorReplicator) work() {
+	defer r.wg.Done()
+	for {
+		select {
+		case task := <-r.provider.queue:
+			r.provider.replicate(r.drain, task)
+		case <-r.stop:
+			for r.drain.Err() == nil {
+				select {
+				case task := <-r.provider.queue:
+					r.provider.replicate(r.drain, task)
+				default:
+					return
+				}
+			}
+			zap.L().Warn("关闭时未能复制完队列中的任务，等待校准修复", zap.String("bucket", r.name),
+				zap.Int("pending", len(r.provider.queue)))
+			return
+		}
+	}
+}
+
+func (r *mirrorReplicator) reconcileLoop() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			report, err := r.provider.Reconcile(r.ctx)
+			if err != nil {
+				zap.L().Error("镜像校准失败", zap.Error(err))
+				continue
+			}
+			if ReconcileHook != nil {
+				ReconcileHook(r.name, report)
+			}
+			zap.L().Info("镜像校准完成", zap.String("bucket", r.name), zap.Strings("replicas", r.provider.names),
+				zap.Int("copied", report.Copied), zap.Int("extra", len(report.Extra)), zap.Int("deleted", report.Deleted),
+				zap.Int("failed", len(report.Failed)))
+		}
+	}
+}
+
+func (r *mirrorReplicator) Close() error {
+	close(r.stop)
+	r.cancel()
+	timer := time.AfterFunc(mirrorDrainTimeout, r.abort)
+	r.wg.Wait()
+	timer.Stop()
+	r.abort()
+	return nil
+}
@@ -0,0 +1,174 @@
+package oss_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cube-go/pkg/oss"
+)
+
+func TestMirrorReconcileExtra(t *testing.T) {
+	ctx := context.Background()
+	for _, deleteExtra := range []bool{false, true} {
+		primary := oss.NewMemoryStorageProvider(oss.MemoryStorageOptions{})
+		secondary := oss.NewMemoryStorageProvider(oss.MemoryStorageOptions{})
+		options := oss.MirrorOptions(oss.MirrorSync)
+		if deleteExtra {
+			options = oss.MirrorOptionsDeleteExtra(oss.MirrorSync)
+		}
+		mirror, err := oss.NewMirrorProvider([]string{"a", "b"}, []oss.StorageProvider{primary, secondary}, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := primary.SaveObject(ctx, strings.NewReader("missing"), "missing.txt", oss.SaveObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := secondary.SaveObject(ctx, strings.NewReader("extra"), "extra.txt", oss.SaveObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := mirror.Reconcile(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []oss.MirrorExtra{{Replica: "b", ObjectKey: "extra.txt", Deleted: deleteExtra}}
+		if report.Copied != 1 || len(report.Extra) != 1 || report.Extra[0] != want[0] {
+			t.Errorf("deleteExtra=%v: report = %+v, want one copied and extra %+v", deleteExtra, report, want)
+		}
+		_, err = secondary.StatObject(ctx, "extra.txt", oss.GetObjectOptions{})
+		if deleteExtra != errors.Is(err, oss.ErrResourceNotExists) {
+			t.Errorf("deleteExtra=%v: StatObject(extra.txt) on replica = %v", deleteExtra, err)
+		}
+	}
+}
+
+func TestCompositeMembers(t *testing.T) {
+	members, err := oss.CompositeMembers(map[string][]string{"m": {"a", "b"}}, map[string][2]string{"t": {"c", "d"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if members["a"] != "m" || members["d"] != "t" || len(members) != 4 {
+		t.Errorf("members = %v", members)
+	}
+	cases := []struct {
+		mirrors map[string][]string
+		tiers   map[string][2]string
+	}{
+		{mirrors: map[string][]string{"m": {"a", "b"}}, tiers: map[string][2]string{"t": {"a", "c"}}},
+		{mirrors: map[string][]string{"m": {"a", "b"}, "n": {"c", "b"}}},
+		{mirrors: map[string][]string{"m": {"a", "a"}}},
+		{tiers: map[string][2]string{"t": {"a", "a"}}},
+	}
+	for _, c := range cases {
+		if _, err := oss.CompositeMembers(c.mirrors, c.tiers); !errors.Is(err, oss.ErrBucketInUse) {
+			t.Errorf("CompositeMembers(%v, %v) = %v, want ErrBucketInUse", c.mirrors, c.tiers, err)
+		}
+	}
+}
+
+func TestMirrorSyncCountsReplicaFailures(t *testing.T) {
+	ctx := context.Background()
+	primary := oss.NewMemoryStorageProvider(oss.MemoryStorageOptions{})
+	full := oss.NewMemoryStorageProvider(oss.MemoryStorageOptions{MaxBytes: 1})
+	mirror, err := oss.NewMirrorProvider([]string{"a", "b"}, []oss.StorageProvider{primary, full}, oss.MirrorOptions(oss.MirrorSync))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 对象以主副本为准，副本写入失败不影响结果
+	if err := mirror.SaveObject(ctx, strings.NewReader("hello"), "hello.txt", oss.SaveObjectOptions{}); err != nil {
+		t.Fatalf("SaveObject with a failing replica = %v, want nil", err)
+	}
+	if got := mirror.Status().Failures; got != 1 {
+		t.Errorf("Status().Failures = %d, want 1", got)
+	}
+}
+
+func TestCreationOrder(t *testing.T) {
+	// 分层存储桶的热层是镜像存储桶，普通存储桶排在配置末尾
+	order := oss.CreationOrder(map[string][]string{"m": {"a", "b"}}, map[string][2]string{"t": {"m", "c"}}, "a", "b", "c")
+	position := make(map[string]int, len(order))
+	for i, name := range order {
+		position[name] = i
+	}
+	if len(order) != 5 || position["m"] < position["a"] || position["m"] < position["b"] ||
+		position["t"] < position["m"] || position["t"] < position["c"] {
+		t.Errorf("creation order = %v, want members before the buckets that use them", order)
+	}
+}
+
+func TestMirrorReconcileKeepsUploadInfo(t *testing.T) {
+	ctx := context.Background()
+	primary := newLocalProvider(t, oss.LocalStorageOptions{Metadata: "bolt"})
+	secondary := newLocalProvider(t, oss.LocalStorageOptions{Metadata: "bolt"})
+	mirror, err := oss.NewMirrorProvider([]string{"a", "b"}, []oss.StorageProvider{primary, secondary}, oss.MirrorOptions(oss.MirrorAsync))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadedAt := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	err = primary.SaveObject(ctx, strings.NewReader("hello"), "hello.txt", oss.SaveObjectOptions{
+		OriginalName: "Hello.txt",
+		Uploader:     "alice",
+		Metadata:     map[string]string{"author": "alice"},
+		UploadedAt:   uploadedAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mirror.Reconcile(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := oss.LocalMetadata(secondary, "hello.txt")
+	if err != nil || meta == nil {
+		t.Fatalf("replica metadata = %v, %v", meta, err)
+	}
+	if meta.OriginalName != "Hello.txt" || meta.Uploader != "alice" || meta.Custom["author"] != "alice" || !meta.UploadedAt.Equal(uploadedAt) {
+		t.Errorf("replica metadata = %+v, want the upload info of the primary", meta)
+	}
+	info, err := secondary.StatObject(ctx, "hello.txt", oss.GetObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.LastModified.Equal(uploadedAt) {
+		t.Errorf("replica LastModified = %v, want %v", info.LastModified, uploadedAt)
+	}
+}
+
+func TestMirrorReconcileHashesWithoutChecksum(t *testing.T) {
+	ctx := context.Background()
+	primary := oss.NewMemoryStorageProvider(oss.MemoryStorageOptions{})
+	secondary, dir := newLocalProviderDir(t, oss.LocalStorageOptions{Metadata: "bolt"})
+	mirror, err := oss.NewMirrorProvider([]string{"a", "b"}, []oss.StorageProvider{primary, secondary}, oss.MirrorOptions(oss.MirrorAsync))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.SaveObject(ctx, strings.NewReader("hello"), "hello.txt", oss.SaveObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// 副本中大小相同但内容不同，且未记录摘要
+	if err := os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("HELLO"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := secondary.StatObject(ctx, "hello.txt", oss.GetObjectOptions{}); err != nil || info.SHA256 != "" {
+		t.Fatalf("replica StatObject = %+v, %v, want no checksum", info, err)
+	}
+
+	report, err := mirror.Reconcile(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Copied != 1 || len(report.Failed) != 0 {
+		t.Errorf("report = %+v, want the differing replica copied", report)
+	}
+	if got, err := readObject(t, secondary, "hello.txt"); err != nil || got != "hello" {
+		t.Errorf("replica content = %q, %v, want %q", got, err, "hello")
+	}
+	if report, err := mirror.Reconcile(ctx); err != nil || report.Copied != 0 {
+		t.Errorf("second reconcile = %+v, %v, want nothing copied", report, err)
+	}
+}
@@ -0,0 +1,525 @@
+package osstest
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// FakeS3 进程内的 S3 模拟服务，只实现存储提供者用到的接口：
+// 对象的读写删除与复制、条件与范围请求、SHA-256 校验和、用户元数据、标签以及 ListObjectsV2。
+// 存储桶在首次写入时自动创建，仅支持路径风格的请求。
+type FakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]map[string]*fakeObject // 存储桶 -> 对象键 -> 对象
+	maxKeys int
+}
+
+type fakeObject struct {
+	data         []byte
+	etag         string
+	contentType  string
+	lastModified time.Time
+	metadata     map[string]string
+	tags         map[string]string
+	checksum     string // base64 编码的 SHA-256，上传时未提供则为空
+}
+
+// NewFakeS3 启动模拟服务并返回连接到它的客户端，测试结束时自动关闭
+func NewFakeS3(tb testing.TB) *s3.Client {
+	tb.Helper()
+	fake := &FakeS3{objects: make(map[string]map[string]*fakeObject), maxKeys: 1000}
+	server := httptest.NewServer(fake)
+	tb.Cleanup(server.Close)
+	return s3.New(s3.Options{
+		BaseEndpoint:     aws.String(server.URL),
+		Region:           "us-east-1",
+		Credentials:      credentials.NewStaticCredentialsProvider("test", "test", ""),
+		UsePathStyle:     true,
+		HTTPClient:       server.Client(),
+		RetryMaxAttempts: 1,
+	})
+}
+
+func (f *FakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case bucket == "":
+		writeError(w, r, http.StatusNotImplemented, "NotImplemented")
+	case key == "" && r.Method == http.MethodGet && query.Get("list-type") == "2":
+		f.listObjects(w, r, bucket)
+	case key == "" && r.Method == http.MethodPost && query.Has("delete"):
+		f.deleteObjects(w, r, bucket)
+	case key == "":
+		writeError(w, r, http.StatusNotImplemented, "NotImplemented")
+	case query.Has("tagging"):
+		f.tagging(w, r, bucket, key)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		f.copyObject(w, r, bucket, key)
+	case r.Method == http.MethodPut:
+		f.putObject(w, r, bucket, key)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		f.getObject(w, r, bucket, key)
+	case r.Method == http.MethodDelete:
+		delete(f.objects[bucket], key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (f *FakeS3) bucket(name string) map[string]*fakeObject {
+	objects, ok := f.objects[name]
+	if !ok {
+		objects = make(map[string]*fakeObject)
+		f.objects[name] = objects
+	}
+	return objects
+}
+
+func (f *FakeS3) putObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	data, trailers, err := readBody(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+	current, exists := f.bucket(bucket)[key]
+	if r.Header.Get("If-None-Match") == "*" && exists {
+		writeError(w, r, http.StatusPreconditionFailed, "PreconditionFailed")
+		return
+	}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !exists {
+			writeError(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if !etagListMatches(ifMatch, current.etag) {
+			writeError(w, r, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+	}
+	sum := sha256.Sum256(data)
+	actual := base64.StdEncoding.EncodeToString(sum[:])
+	checksum := cmp.Or(r.Header.Get("X-Amz-Checksum-Sha256"), trailers.Get("X-Amz-Checksum-Sha256"))
+	if checksum != "" && checksum != actual {
+		writeError(w, r, http.StatusBadRequest, "BadDigest")
+		return
+	}
+	tags, err := url.ParseQuery(r.Header.Get("X-Amz-Tagging"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "InvalidTag")
+		return
+	}
+	object := newFakeObject(data, r.Header.Get("Content-Type"), requestMetadata(r.Header))
+	object.checksum = checksum
+	object.tags = make(map[string]string, len(tags))
+	for k := range tags {
+		object.tags[k] = tags.Get(k)
+	}
+	f.bucket(bucket)[key] = object
+	w.Header().Set("ETag", object.etag)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (f *FakeS3) copyObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	sourceBucket, sourceKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	src, exists := f.bucket(sourceBucket)[sourceKey]
+	if !exists {
+		writeError(w, r, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	if ifMatch := r.Header.Get("X-Amz-Copy-Source-If-Match"); ifMatch != "" && !etagListMatches(ifMatch, src.etag) {
+		writeError(w, r, http.StatusPreconditionFailed, "PreconditionFailed")
+		return
+	}
+	object := newFakeObject(src.data, src.contentType, src.metadata)
+	object.checksum = src.checksum
+	object.tags = src.tags
+	if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+		object.contentType = r.Header.Get("Content-Type")
+		object.metadata = requestMetadata(r.Header)
+	}
+	f.bucket(bucket)[key] = object
+	writeXML(w, http.StatusOK, struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		ETag         string
+		LastModified string
+	}{ETag: object.etag, LastModified: object.lastModified.Format(time.RFC3339)})
+}
+
+func (f *FakeS3) getObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	object, exists := f.bucket(bucket)[key]
+	if !exists {
+		writeError(w, r, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	header := w.Header()
+	header.Set("ETag", object.etag)
+	header.Set("Last-Modified", object.lastModified.Format(http.TimeFormat))
+	header.Set("Accept-Ranges", "bytes")
+	if status := checkConditions(r.Header, object); status != http.StatusOK {
+		writeError(w, r, status, http.StatusText(status))
+		return
+	}
+	header.Set("Content-Type", object.contentType)
+	for k, v := range object.metadata {
+		header.Set("X-Amz-Meta-"+k, v)
+	}
+
+	size := int64(len(object.data))
+	data := object.data
+	status := http.StatusOK
+	if spec := r.Header.Get("Range"); spec != "" {
+		start, length, ok := parseRange(spec, size)
+		if !ok {
+			header.Set("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+			writeError(w, r, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+			return
+		}
+		data = data[start : start+length]
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, size))
+		status = http.StatusPartialContent
+	} else if object.checksum != "" && r.Header.Get("X-Amz-Checksum-Mode") == "ENABLED" {
+		header.Set("X-Amz-Checksum-Sha256", object.checksum)
+		header.Set("X-Amz-Checksum-Type", "FULL_OBJECT")
+	}
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(data)
+	}
+}
+
+func (f *FakeS3) tagging(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	object, exists := f.bucket(bucket)[key]
+	if !exists {
+		writeError(w, r, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		result := fakeTagging{}
+		for _, k := range slices.Sorted(maps.Keys(object.tags)) {
+			result.TagSet = append(result.TagSet, fakeTag{Key: k, Value: object.tags[k]})
+		}
+		writeXML(w, http.StatusOK, result)
+	case http.MethodPut:
+		data, _, err := readBody(r)
+		var tagging fakeTagging
+		if err == nil {
+			err = xml.Unmarshal(data, &tagging)
+		}
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		tags := make(map[string]string, len(tagging.TagSet))
+		for _, tag := range tagging.TagSet {
+			tags[tag.Key] = tag.Value
+		}
+		object.tags = tags
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		object.tags = nil
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+type fakeTagging struct {
+	XMLName xml.Name  `xml:"Tagging"`
+	TagSet  []fakeTag `xml:"TagSet>Tag"`
+}
+
+type fakeTag struct {
+	Key   string
+	Value string
+}
+
+type fakeContent struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int64
+	StorageClass string
+}
+
+type fakePrefix struct {
+	Prefix string
+}
+
+func (f *FakeS3) listObjects(w http.ResponseWriter, r *http.Request, bucket string) {
+	query := r.URL.Query()
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	maxKeys := f.maxKeys
+	if value, err := strconv.Atoi(query.Get("max-keys")); err == nil && value > 0 {
+		maxKeys = min(value, maxKeys)
+	}
+	after := cmp.Or(query.Get("continuation-token"), query.Get("start-after"))
+
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Name                  string
+		Prefix                string
+		Delimiter             string `xml:",omitempty"`
+		MaxKeys               int
+		KeyCount              int
+		IsTruncated           bool
+		NextContinuationToken string        `xml:",omitempty"`
+		Contents              []fakeContent `xml:"Contents"`
+		CommonPrefixes        []fakePrefix  `xml:"CommonPrefixes"`
+	}{Name: bucket, Prefix: prefix, Delimiter: delimiter, MaxKeys: maxKeys}
+
+	objects := f.bucket(bucket)
+	last := "" // 已返回的最后一项，公共前缀以其后的最大键表示，以便跳过前缀下的其余对象
+	for _, key := range slices.Sorted(maps.Keys(objects)) {
+		if key <= after || key <= last || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		entry := key
+		if delimiter != "" {
+			if index := strings.Index(key[len(prefix):], delimiter); index >= 0 {
+				entry = key[:len(prefix)+index+len(delimiter)]
+			}
+		}
+		if result.KeyCount == maxKeys {
+			result.IsTruncated = true
+			result.NextContinuationToken = last
+			break
+		}
+		if entry != key {
+			result.CommonPrefixes = append(result.CommonPrefixes, fakePrefix{Prefix: entry})
+			last = entry + "\xff"
+		} else {
+			object := objects[key]
+			result.Contents = append(result.Contents, fakeContent{
+				Key:          key,
+				LastModified: object.lastModified.Format(time.RFC3339),
+				ETag:         object.etag,
+				Size:         int64(len(object.data)),
+				StorageClass: "STANDARD",
+			})
+			last = key
+		}
+		result.KeyCount++
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+func (f *FakeS3) deleteObjects(w http.ResponseWriter, r *http.Request, bucket string) {
+	data, _, err := readBody(r)
+	var request struct {
+		Objects []struct{ Key string } `xml:"Object"`
+	}
+	if err == nil {
+		err = xml.Unmarshal(data, &request)
+	}
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	result := struct {
+		XMLName xml.Name               `xml:"DeleteResult"`
+		Deleted []struct{ Key string } `xml:"Deleted"`
+	}{}
+	for _, object := range request.Objects {
+		delete(f.bucket(bucket), object.Key)
+		result.Deleted = append(result.Deleted, struct{ Key string }{object.Key})
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+func newFakeObject(data []byte, contentType string, metadata map[string]string) *fakeObject {
+	sum := md5.Sum(data)
+	if contentType == "" {
+		contentType = "binary/octet-stream"
+	}
+	return &fakeObject{
+		data:         data,
+		etag:         `"` + hex.EncodeToString(sum[:]) + `"`,
+		contentType:  contentType,
+		lastModified: time.Now().UTC().Truncate(time.Second),
+		metadata:     metadata,
+	}
+}
+
+// readBody 读取请求体，解码 aws-chunked 编码并返回其中的尾部校验和
+func readBody(r *http.Request) ([]byte, http.Header, error) {
+	trailers := http.Header{}
+	if !strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") &&
+		!strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		data, err := io.ReadAll(r.Body)
+		return data, trailers, err
+	}
+	reader := bufio.NewReader(r.Body)
+	var body bytes.Buffer
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, nil, err
+		}
+		sizeText, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeText, 16, 64)
+		if err != nil {
+			return nil, nil, err
+		}
+		if size == 0 {
+			break
+		}
+		if _, err := io.CopyN(&body, reader, size); err != nil {
+			return nil, nil, err
+		}
+		if _, err := reader.Discard(2); err != nil {
+			return nil, nil, err
+		}
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			trailers.Set(name, strings.TrimSpace(value))
+		}
+		if err != nil || line == "" {
+			break
+		}
+	}
+	return body.Bytes(), trailers, nil
+}
+
+func requestMetadata(header http.Header) map[string]string {
+	var metadata map[string]string
+	for name, values := range header {
+		if key, ok := strings.CutPrefix(name, "X-Amz-Meta-"); ok && len(values) > 0 {
+			if metadata == nil {
+				metadata = make(map[string]string)
+			}
+			metadata[strings.ToLower(key)] = values[0]
+		}
+	}
+	return metadata
+}
+
+// checkConditions 按 S3 的规则判断读取条件
+func checkConditions(header http.Header, object *fakeObject) int {
+	if ifMatch := header.Get("If-Match"); ifMatch != "" {
+		if !etagListMatches(ifMatch, object.etag) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(header.Get("If-Unmodified-Since")); err == nil && object.lastModified.After(since) {
+		return http.StatusPreconditionFailed
+	}
+	if ifNoneMatch := header.Get("If-None-Match"); ifNoneMatch != "" {
+		if etagListMatchesWeak(ifNoneMatch, object.etag) {
+			return http.StatusNotModified
+		}
+	} else if since, err := http.ParseTime(header.Get("If-Modified-Since")); err == nil && !object.lastModified.After(since) {
+		return http.StatusNotModified
+	}
+	return http.StatusOK
+}
+
+// etagListMatches 用于 If-Match，按强比较
+func etagListMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// etagListMatchesWeak 用于 If-None-Match，忽略弱校验前缀
+func etagListMatchesWeak(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRange 解析单个字节范围，返回起始位置与长度
+func parseRange(spec string, size int64) (int64, int64, bool) {
+	spec, ok := strings.CutPrefix(spec, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	startText, endText, _ := strings.Cut(spec, "-")
+	if startText == "" {
+		suffix, err := strconv.ParseInt(endText, 10, 64)
+		if err != nil || suffix <= 0 || size == 0 {
+			return 0, 0, false
+		}
+		suffix = min(suffix, size)
+		return size - suffix, suffix, true
+	}
+	start, err := strconv.ParseInt(startText, 10, 64)
+	if err != nil || start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if endText != "" {
+		if end, err = strconv.ParseInt(endText, 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+		end = min(end, size-1)
+	}
+	return start, end - start + 1, true
+}
+
+func writeXML(w http.ResponseWriter, status int, value any) {
+	data, err := xml.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(data)
+}
+
+// writeError 返回 S3 格式的错误，HEAD 请求与 304 响应不带响应体
+func writeError(w http.ResponseWriter, r *http.Request, status int, code string) {
+	if r.Method == http.MethodHead || status == http.StatusNotModified {
+		w.WriteHeader(status)
+		return
+	}
+	writeXML(w, status, struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: code, Message: code})
+}
@@ -0,0 +1,654 @@
+// Package osstest 提供存储提供者的一致性测试，各实现运行同一组用例以保证行为一致。
+//
+// 用例覆盖保存、读取、查询、列表、删除、条件与范围请求、上下文取消以及并发写入。
+// 可随机读取的提供者（见 oss.SeekableProvider）不处理条件与范围请求，相应用例改为检查读取器可随机读取。
+// 已清空的目录可能仍出现在列表中（如 WebDAV 的空集合），但列出该目录时必须为空。
+package osstest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cube-go/pkg/oss"
+)
+
+// Factory 为每个用例创建一个空的存储提供者
+type Factory func(t *testing.T) oss.StorageProvider
+
+// Run 对存储提供者运行全部一致性用例
+func Run(t *testing.T, newProvider Factory) {
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, p oss.StorageProvider)
+	}{
+		{"SaveAndGet", testSaveAndGet},
+		{"Stat", testStat},
+		{"EmptyObject", testEmptyObject},
+		{"LargeObject", testLargeObject},
+		{"NoOverwrite", testNoOverwrite},
+		{"IfMatch", testIfMatch},
+		{"Checksum", testChecksum},
+		{"Missing", testMissing},
+		{"InvalidKeys", testInvalidKeys},
+		{"List", testList},
+		{"Walk", testWalk},
+		{"DeleteFile", testDeleteFile},
+		{"DeleteDir", testDeleteDir},
+		{"Conditions", testConditions},
+		{"Ranges", testRanges},
+		{"Metadata", testMetadata},
+		{"MetadataUnsupported", testMetadataUnsupported},
+		{"Cancel", testCancel},
+		{"ConcurrentCreate", testConcurrentCreate},
+		{"ConcurrentKeys", testConcurrentKeys},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.fn(t, newProvider(t))
+		})
+	}
+}
+
+const helloText = "hello, conformance\n"
+
+func testSaveAndGet(t *testing.T, p oss.StorageProvider) {
+	before := time.Now().Add(-time.Minute)
+	mustSave(t, p, "docs/hello.txt", helloText, oss.SaveObjectOptions{})
+	content, info := mustGet(t, p, "docs/hello.txt", oss.GetObjectOptions{})
+	if content != helloText {
+		t.Fatalf("content = %q, want %q", content, helloText)
+	}
+	if info.ContentLength != int64(len(helloText)) {
+		t.Errorf("ContentLength = %d, want %d", info.ContentLength, len(helloText))
+	}
+	if !strings.HasPrefix(info.ContentType, "text/plain") {
+		t.Errorf("ContentType = %q, want text/plain", info.ContentType)
+	}
+	if info.ETag == "" || strings.HasPrefix(info.ETag, "W/") {
+		t.Errorf("ETag = %q, want a strong ETag", info.ETag)
+	}
+	if info.LastModified.Before(before) || info.LastModified.After(time.Now().Add(time.Minute)) {
+		t.Errorf("LastModified = %v, want about now", info.LastModified)
+	}
+	if info.SHA256 != "" && info.SHA256 != sha256Hex(helloText) {
+		t.Errorf("SHA256 = %q, want %q", info.SHA256, sha256Hex(helloText))
+	}
+}
+
+func testStat(t *testing.T, p oss.StorageProvider) {
+	mustSave(t, p, "stat.json", `{"a":1}`, oss.SaveObjectOptions{})
+	_, got := mustGet(t, p, "stat.json", oss.GetObjectOptions{})
+	info, err := p.StatObject(context.Background(), "stat.json", oss.GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("StatObject: %v", err)
+	}
+	if info.ETag != got.ETag || info.ContentLength != got.ContentLength || info.ContentType != got.ContentType {
+		t.Errorf("StatObject = %+v, GetObject = %+v", info, got)
+	}
+	if !info.LastModified.Equal(got.LastModified) {
+		t.Errorf("StatObject LastModified = %v, GetObject = %v", info.LastModified, got.LastModified)
+	}
+}
+
+func testEmptyObject(t *testing.T, p oss.StorageProvider) {
+	mustSave(t, p, "empty", "", oss.SaveObjectOptions{})
+	content, info := mustGet(t, p, "empty", oss.GetObjectOptions{})
+	if content != "" || info.ContentLength != 0 {
+		t.Errorf("got %d bytes, ContentLength = %d, want empty", len(content), info.ContentLength)
+	}
+}
+
+func testLargeObject(t *testing.T, p oss.StorageProvider) {
+	data := make([]byte, 3<<20+123)
+	_, _ = rand.Read(data)
+	mustSave(t, p, "large.bin", string(data), oss.SaveObjectOptions{SHA256: sha256Hex(string(data))})
+	content, info := mustGet(t, p, "large.bin", oss.GetObjectOptions{})
+	if content != string(data) {
+		t.Fatalf("content differs, got %d bytes, want %d", len(content), len(data))
+	}
+	if info.ContentLength != int64(len(data)) {
+		t.Errorf("ContentLength = %d, want %d", info.ContentLength, len(data))
+	}
+}
+
+func testNoOverwrite(t *testing.T, p oss.StorageProvider) {
+	mustSave(t, p, "once.txt", "first", oss.SaveObjectOptions{})
+	_, first := mustGet(t, p, "once.txt", oss.GetObjectOptions{})
+	err := save(p, "once.txt", "second", oss.SaveObjectOptions{})
+	if !errors.Is(err, oss.ErrFileAlreadyExists) {
+		t.Fatalf("save without Overwrite = %v, want ErrFileAlreadyExists", err)
+	}
+	if content, _ := mustGet(t, p, "once.txt", oss.GetObjectOptions{}); content != "first" {
+		t.Fatalf("content = %q after rejected save, want %q", content, "first")
+	}
+	mustSave(t, p, "once.txt", "second", oss.SaveObjectOptions{Overwrite: true})
+	content, second := mustGet(t, p, "once.txt", oss.GetObjectOptions{})
+	if content != "second" {
+		t.Fatalf("content = %q after overwrite, want %q", content, "second")
+	}
+	if second.ETag == first.ETag {
+		t.Errorf("ETag unchanged after overwrite: %q", second.ETag)
+	}
+}
+
+func testIfMatch(t *testing.T, p oss.StorageProvider) {
+	err := save(p, "cas.txt", "v0", oss.SaveObjectOptions{IfMatch: `"missing"`})
+	if !errors.Is(err, oss.ErrPreconditionFailed) {
+		t.Fatalf("IfMatch on missing object = %v, want ErrPreconditionFailed", err)
+	}
+	mustSave(t, p, "cas.txt", "v1", oss.SaveObjectOptions{})
+	_, info := mustGet(t, p, "cas.txt", oss.GetObjectOptions{})
+	err = save(p, "cas.txt", "v2", oss.SaveObjectOptions{IfMatch: `"0123456789abcdef"`})
+	if !errors.Is(err, oss.ErrPreconditionFailed) {
+		t.Fatalf("IfMatch with stale ETag = %v, want ErrPreconditionFailed", err)
+	}
+	// If-Match 使用强比较，弱 ETag 不匹配
+	err = save(p, "cas.txt", "v2", oss.SaveObjectOptions{IfMatch: "W/" + strings.TrimPrefix(info.ETag, "W/")})
+	if !errors.Is(err, oss.ErrPreconditionFailed) {
+		t.Fatalf("IfMatch with weak ETag = %v, want ErrPreconditionFailed", err)
+	}
+	mustSave(t, p, "cas.txt", "v2", oss.SaveObjectOptions{IfMatch: info.ETag})
+	if content, _ := mustGet(t, p, "cas.txt", oss.GetObjectOptions{}); content != "v2" {
+		t.Fatalf("content = %q, want %q", content, "v2")
+	}
+	mustSave(t, p, "cas.txt", "v3", oss.SaveObjectOptions{IfMatch: "*"})
+}
+
+func testChecksum(t *testing.T, p oss.StorageProvider) {
+	err := save(p, "sum.txt", helloText, oss.SaveObjectOptions{SHA256: sha256Hex("something else")})
+	if !errors.Is(err, oss.ErrChecksumMismatch) {
+		t.Fatalf("save with wrong SHA256 = %v, want ErrChecksumMismatch", err)
+	}
+	if _, _, err := p.GetObject(context.Background(), "sum.txt", oss.GetObjectOptions{}); !errors.Is(err, oss.ErrResourceNotExists) {
+		t.Fatalf("GetObject after rejected save = %v, want ErrResourceNotExists", err)
+	}
+	mustSave(t, p, "sum.txt", helloText, oss.SaveObjectOptions{SHA256: strings.ToUpper(sha256Hex(helloText))})
+}
+
+func testMissing(t *testing.T, p oss.StorageProvider) {
+	ctx := context.Background()
+	if _, _, err := p.GetObject(ctx, "nope.txt", oss.GetObjectOptions{}); !errors.Is(err, oss.ErrResourceNotExists) {
+		t.Errorf("GetObject = %v, want ErrResourceNotExists", err)
+	}
+	if _, err := p.StatObject(ctx, "nope.txt", oss.GetObjectOptions{}); !errors.Is(err, oss.ErrResourceNotExists) {
+		t.Errorf("StatObject = %v, want ErrResourceNotExists", err)
+	}
+	if err := p.DeleteObject(ctx, "nope.txt"); err != nil {
+		t.Errorf("DeleteObject = %v, want nil", err)
+	}
+	if err := p.DeleteObject(ctx, "nope/"); err != nil {
+		t.Errorf("DeleteObject dir = %v, want nil", err)
+	}
+	list, err := p.GetFileList(ctx, "nope/")
+	if err != nil || len(list) != 0 {
+		t.Errorf("GetFileList = %v, %v, want empty list", list, err)
+	}
+}
+
+func testInvalidKeys(t *testing.T, p oss.StorageProvider) {
+	ctx := context.Background()
+	for _, key := range []string{"", "/abs.txt", "../up.txt", "a/../../b.txt", "a//b.txt", "./a.txt", `a\b.txt`, ".cube/x", "dir/"} {
+		if err := save(p, key, "x", oss.SaveObjectOptions{}); !errors.Is(err, oss.ErrInvalidObjectKey) {
+			t.Errorf("SaveObject(%q) = %v, want ErrInvalidObjectKey", key, err)
+		}
+		if _, _, err := p.GetObject(ctx, key, oss.GetObjectOptions{}); !errors.Is(err, oss.ErrInvalidObjectKey) {
+			t.Errorf("GetObject(%q) = %v, want ErrInvalidObjectKey", key, err)
+		}
+		if _, err := p.StatObject(ctx, key, oss.GetObjectOptions{}); !errors.Is(err, oss.ErrInvalidObjectKey) {
+			t.Errorf("StatObject(%q) = %v, want ErrInvalidObjectKey", key, err)
+		}
+		if key == "dir/" {
+			continue
+		}
+		if err := p.DeleteObject(ctx, key); !errors.Is(err, oss.ErrInvalidObjectKey) {
+			t.Errorf("DeleteObject(%q) = %v, want ErrInvalidObjectKey", key, err)
+		}
+	}
+	if _, err := p.GetFileList(ctx, "../"); !errors.Is(err, oss.ErrInvalidObjectKey) {
+		t.Errorf("GetFileList(%q) = %v, want ErrInvalidObjectKey", "../", err)
+	}
+}
+
+// seedTree 写入 list/ 下的对象，返回对象键到内容的映射
+func seedTree(t *testing.T, p oss.StorageProvider) map[string]string {
+	objects := map[string]string{
+		"list/a.txt":            "alpha",
+		"list/b.json":           `{"b":true}`,
+		"list/sub/c.txt":        "gamma",
+		"list/sub/deep/d.txt":   "delta",
+		"list/sub/deep/e.jsonl": "{}\n",
+		"other.txt":             "other",
+	}
+	for _, key := range slices.Sorted(maps.Keys(objects)) {
+		mustSave(t, p, key, objects[key], oss.SaveObjectOptions{})
+	}
+	return objects
+}
+
+func testList(t *testing.T, p oss.StorageProvider) {
+	seedTree(t, p)
+	for _, prefix := range []string{"list", "list/"} {
+		list := mustList(t, p, prefix)
+		want := []string{"list/a.txt", "list/b.json", "list/sub/"}
+		if got := objectKeys(list); !slices.Equal(got, want) {
+			t.Fatalf("GetFileList(%q) = %v, want %v", prefix, got, want)
+		}
+		for _, element := range list {
+			switch element.ObjectKey {
+			case "list/a.txt":
+				if element.Name != "a.txt" || element.Size != 5 || element.Type != "text" {
+					t.Errorf("file element = %+v", element)
+				}
+				if _, err := time.Parse(time.RFC3339, element.LastModified); err != nil {
+					t.Errorf("LastModified = %q: %v", element.LastModified, err)
+				}
+			case "list/b.json":
+				if element.Type != "json" {
+					t.Errorf("json element Type = %q", element.Type)
+				}
+			case "list/sub/":
+				if element.Name != "sub" || element.Type != "dir" {
+					t.Errorf("dir element = %+v", element)
+				}
+			}
+		}
+	}
+	if got, want := objectKeys(mustList(t, p, "")), []string{"list/", "other.txt"}; !slices.Equal(got, want) {
+		t.Errorf("GetFileList(root) = %v, want %v", got, want)
+	}
+	if got, want := objectKeys(mustList(t, p, "list/sub/deep")), []string{"list/sub/deep/d.txt", "list/sub/deep/e.jsonl"}; !slices.Equal(got, want) {
+		t.Errorf("GetFileList(deep) = %v, want %v", got, want)
+	}
+}
+
+func testWalk(t *testing.T, p oss.StorageProvider) {
+	objects := seedTree(t, p)
+	got := make(map[string]int64)
+	err := oss.WalkObjects(context.Background(), p, "list", func(entry oss.ObjectEntry) error {
+		got[entry.Key] = entry.Size
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkObjects: %v", err)
+	}
+	for key, content := range objects {
+		size, ok := got[key]
+		if strings.HasPrefix(key, "list/") != ok || (ok && size != int64(len(content))) {
+			t.Errorf("WalkObjects entry %q = %d, %v", key, size, ok)
+		}
+	}
+	if len(got) != len(objects)-1 {
+		t.Errorf("WalkObjects visited %v", slices.Sorted(maps.Keys(got)))
+	}
+}
+
+func testDeleteFile(t *testing.T, p oss.StorageProvider) {
+	seedTree(t, p)
+	mustDelete(t, p, "list/a.txt")
+	if _, _, err := p.GetObject(context.Background(), "list/a.txt", oss.GetObjectOptions{}); !errors.Is(err, oss.ErrResourceNotExists) {
+		t.Fatalf("GetObject after delete = %v, want ErrResourceNotExists", err)
+	}
+	if got, want := objectKeys(mustList(t, p, "list")), []string{"list/b.json", "list/sub/"}; !slices.Equal(got, want) {
+		t.Errorf("GetFileList after delete = %v, want %v", got, want)
+	}
+}
+
+func testDeleteDir(t *testing.T, p oss.StorageProvider) {
+	seedTree(t, p)
+	mustDelete(t, p, "list/sub/")
+	for _, key := range []string{"list/sub/c.txt", "list/sub/deep/d.txt"} {
+		if _, err := p.StatObject(context.Background(), key, oss.GetObjectOptions{}); !errors.Is(err, oss.ErrResourceNotExists) {
+			t.Errorf("StatObject(%q) after dir delete = %v, want ErrResourceNotExists", key, err)
+		}
+	}
+	if got, want := objectKeys(mustList(t, p, "list")), []string{"list/a.txt", "list/b.json"}; !slices.Equal(got, want) {
+		t.Errorf("GetFileList after dir delete = %v, want %v", got, want)
+	}
+	// 删除目录中最后的对象后，空目录可以不再出现在列表中，仍出现时其列表必须为空
+	mustDelete(t, p, "list/a.txt")
+	mustDelete(t, p, "list/b.json")
+	got := objectKeys(mustList(t, p, ""))
+	if !slices.Equal(got, []string{"other.txt"}) && !slices.Equal(got, []string{"list/", "other.txt"}) {
+		t.Errorf("GetFileList(root) after emptying list/ = %v, want [other.txt]", got)
+	}
+	if got := mustList(t, p, "list"); len(got) != 0 {
+		t.Errorf("GetFileList(list) after emptying = %v, want empty", objectKeys(got))
+	}
+}
+
+func testConditions(t *testing.T, p oss.StorageProvider) {
+	mustSave(t, p, "cond.txt", helloText, oss.SaveObjectOptions{})
+	reader, info, err := p.GetObject(context.Background(), "cond.txt", oss.GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	_ = reader.Close()
+	if oss.IsSeekable(p) {
+		testSeekable(t, p)
+		return
+	}
+
+	modified := info.LastModified
+	later, earlier := modified.Add(time.Hour), modified.Add(-time.Hour)
+	cases := []struct {
+		name       string
+		conditions oss.ObjectConditions
+		want       error
+	}{
+		{"IfMatch", oss.ObjectConditions{IfMatch: info.ETag}, nil},
+		{"IfMatchAny", oss.ObjectConditions{IfMatch: "*"}, nil},
+		{"IfMatchStale", oss.ObjectConditions{IfMatch: `"stale"`}, oss.ErrPreconditionFailed},
+		{"IfNoneMatch", oss.ObjectConditions{IfNoneMatch: info.ETag}, oss.ErrNotModified},
+		{"IfNoneMatchList", oss.ObjectConditions{IfNoneMatch: `"stale", ` + info.ETag}, oss.ErrNotModified},
+		{"IfNoneMatchStale", oss.ObjectConditions{IfNoneMatch: `"stale"`}, nil},
+		{"IfModifiedSinceLater", oss.ObjectConditions{IfModifiedSince: &later}, oss.ErrNotModified},
+		{"IfModifiedSinceEarlier", oss.ObjectConditions{IfModifiedSince: &earlier}, nil},
+		{"IfUnmodifiedSinceEarlier", oss.ObjectConditions{IfUnmodifiedSince: &earlier}, oss.ErrPreconditionFailed},
+		{"IfUnmodifiedSinceLater", oss.ObjectConditions{IfUnmodifiedSince: &later}, nil},
+		// If-Match 存在时忽略 If-Unmodified-Since，If-None-Match 存在时忽略 If-Modified-Since
+		{"IfMatchOverridesUnmodified", oss.ObjectConditions{IfMatch: info.ETag, IfUnmodifiedSince: &earlier}, nil},
+		{"IfNoneMatchOverridesModified", oss.ObjectConditions{IfNoneMatch: `"stale"`, IfModifiedSince: &later}, nil},
+	}
+	for _, c := range cases {
+		options := oss.GetObjectOptions{Conditions: c.conditions}
+		reader, _, err := p.GetObject(context.Background(), "cond.txt", options)
+		if reader != nil {
+			_ = reader.Close()
+		}
+		if !errors.Is(err, c.want) || (c.want == nil && err != nil) {
+			t.Errorf("%s: GetObject = %v, want %v", c.name, err, c.want)
+		}
+		if _, err := p.StatObject(context.Background(), "cond.txt", options); !errors.Is(err, c.want) || (c.want == nil && err != nil) {
+			t.Errorf("%s: StatObject = %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+// testSeekable 可随机读取的提供者交由调用方处理条件与范围，读取器必须可随机读取
+func testSeekable(t *testing.T, p oss.StorageProvider) {
+	reader, _, err := p.GetObject(context.Background(), "cond.txt", oss.GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+	seeker, ok := reader.(io.ReadSeeker)
+	if !ok {
+		t.Fatalf("seekable provider returned %T, want io.ReadSeeker", reader)
+	}
+	if _, err := seeker.Seek(7, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	if rest, err := io.ReadAll(seeker); err != nil || string(rest) != helloText[7:] {
+		t.Errorf("content after Seek = %q, %v, want %q", rest, err, helloText[7:])
+	}
+}
+
+func testRanges(t *testing.T, p oss.StorageProvider) {
+	if oss.IsSeekable(p) {
+		t.Skip("seekable providers leave ranges to the caller")
+	}
+	mustSave(t, p, "range.txt", helloText, oss.SaveObjectOptions{})
+	size := len(helloText)
+	cases := []struct {
+		spec       string
+		start, end int
+	}{
+		{"bytes=0-4", 0, 4},
+		{"bytes=7-", 7, size - 1},
+		{"bytes=-3", size - 3, size - 1},
+		{"bytes=-1000", 0, size - 1},
+		{"bytes=2-1000", 2, size - 1},
+		{fmt.Sprintf("bytes=%d-%d", size-1, size-1), size - 1, size - 1},
+	}
+	for _, c := range cases {
+		content, info := mustGet(t, p, "range.txt", oss.GetObjectOptions{Range: c.spec})
+		if want := helloText[c.start : c.end+1]; content != want {
+			t.Errorf("%s: content = %q, want %q", c.spec, content, want)
+		}
+		if want := fmt.Sprintf("bytes %d-%d/%d", c.start, c.end, size); info.ContentRange != want {
+			t.Errorf("%s: ContentRange = %q, want %q", c.spec, info.ContentRange, want)
+		}
+		if info.ContentLength != int64(c.end-c.start+1) {
+			t.Errorf("%s: ContentLength = %d, want %d", c.spec, info.ContentLength, c.end-c.start+1)
+		}
+	}
+	for _, spec := range []string{fmt.Sprintf("bytes=%d-", size), "bytes=5-2"} {
+		reader, _, err := p.GetObject(context.Background(), "range.txt", oss.GetObjectOptions{Range: spec})
+		if reader != nil {
+			_ = reader.Close()
+		}
+		if spec == "bytes=5-2" {
+			// 语法无效的范围按 RFC 9110 可以忽略，此时返回完整对象
+			if err != nil && !errors.Is(err, oss.ErrInvalidRange) {
+				t.Errorf("%s: GetObject = %v", spec, err)
+			}
+			continue
+		}
+		if !errors.Is(err, oss.ErrInvalidRange) {
+			t.Errorf("%s: GetObject = %v, want ErrInvalidRange", spec, err)
+		}
+	}
+	// 条件满足时才返回范围内容
+	_, info := mustGet(t, p, "range.txt", oss.GetObjectOptions{})
+	content, _ := mustGet(t, p, "range.txt", oss.GetObjectOptions{Range: "bytes=0-4", Conditions: oss.ObjectConditions{IfMatch: info.ETag}})
+	if content != helloText[:5] {
+		t.Errorf("conditional range content = %q", content)
+	}
+	_, _, err := p.GetObject(context.Background(), "range.txt", oss.GetObjectOptions{Range: "bytes=0-4", Conditions: oss.ObjectConditions{IfMatch: `"stale"`}})
+	if !errors.Is(err, oss.ErrPreconditionFailed) {
+		t.Errorf("range with stale If-Match = %v, want ErrPreconditionFailed", err)
+	}
+}
+
+func testMetadata(t *testing.T, p oss.StorageProvider) {
+	metadata, ok := oss.As[oss.MetadataProvider](p)
+	if !ok {
+		t.Skip("provider does not support metadata")
+	}
+	ctx := context.Background()
+	mustSave(t, p, "meta.txt", helloText, oss.SaveObjectOptions{
+		Metadata: map[string]string{"author": "alice"},
+		Tags:     map[string]string{"env": "test"},
+	})
+	current, err := metadata.GetMetadata(ctx, "meta.txt")
+	if err != nil {
+		t.Fatalf("GetMetadata: %v", err)
+	}
+	if current.Metadata["author"] != "alice" || current.Tags["env"] != "test" {
+		t.Fatalf("GetMetadata = %+v", current)
+	}
+	if err := metadata.UpdateMetadata(ctx, "meta.txt", oss.UserMetadata{Tags: map[string]string{"env": "prod"}}); err != nil {
+		t.Fatalf("UpdateMetadata: %v", err)
+	}
+	current, err = metadata.GetMetadata(ctx, "meta.txt")
+	if err != nil || current.Metadata["author"] != "alice" || current.Tags["env"] != "prod" {
+		t.Fatalf("GetMetadata after tag update = %+v, %v", current, err)
+	}
+	if content, _ := mustGet(t, p, "meta.txt", oss.GetObjectOptions{}); content != helloText {
+		t.Errorf("content changed by UpdateMetadata: %q", content)
+	}
+	list := mustList(t, p, "")
+	if len(list) != 1 || list[0].Metadata["author"] != "alice" || list[0].Tags["env"] != "prod" {
+		t.Errorf("GetFileList = %+v, want metadata and tags", list)
+	}
+	if _, err := metadata.GetMetadata(ctx, "nope.txt"); !errors.Is(err, oss.ErrResourceNotExists) {
+		t.Errorf("GetMetadata(missing) = %v, want ErrResourceNotExists", err)
+	}
+	if err := metadata.UpdateMetadata(ctx, "nope.txt", oss.UserMetadata{Tags: map[string]string{}}); !errors.Is(err, oss.ErrResourceNotExists) {
+		t.Errorf("UpdateMetadata(missing) = %v, want ErrResourceNotExists", err)
+	}
+}
+
+// testMetadataUnsupported 不支持自定义元数据的存储提供者不能静默丢弃上传时指定的元数据与标签
+func testMetadataUnsupported(t *testing.T, p oss.StorageProvider) {
+	if _, ok := oss.As[oss.MetadataProvider](p); ok {
+		t.Skip("provider supports metadata")
+	}
+	err := save(p, "meta.txt", helloText, oss.SaveObjectOptions{Tags: map[string]string{"env": "test"}})
+	if !errors.Is(err, oss.ErrMetadataNotSupported) {
+		t.Fatalf("SaveObject with tags = %v, want ErrMetadataNotSupported", err)
+	}
+	if _, err := p.StatObject(context.Background(), "meta.txt", oss.GetObjectOptions{}); !errors.Is(err, oss.ErrResourceNotExists) {
+		t.Errorf("StatObject after rejected save = %v, want ErrResourceNotExists", err)
+	}
+	mustSave(t, p, "meta.txt", helloText, oss.SaveObjectOptions{OriginalName: "meta.txt"})
+}
+
+func testCancel(t *testing.T, p oss.StorageProvider) {
+	mustSave(t, p, "kept.txt", helloText, oss.SaveObjectOptions{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.SaveObject(ctx, strings.NewReader(helloText), "cancelled.txt", oss.SaveObjectOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("SaveObject = %v, want context.Canceled", err)
+	}
+	if _, err := p.StatObject(context.Background(), "cancelled.txt", oss.GetObjectOptions{}); !errors.Is(err, oss.ErrResourceNotExists) {
+		t.Errorf("StatObject after cancelled save = %v, want ErrResourceNotExists", err)
+	}
+	if reader, _, err := p.GetObject(ctx, "kept.txt", oss.GetObjectOptions{}); !errors.Is(err, context.Canceled) {
+		if reader != nil {
+			_ = reader.Close()
+		}
+		t.Errorf("GetObject = %v, want context.Canceled", err)
+	}
+	if _, err := p.GetFileList(ctx, ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetFileList = %v, want context.Canceled", err)
+	}
+	if err := p.DeleteObject(ctx, "kept.txt"); !errors.Is(err, context.Canceled) {
+		t.Errorf("DeleteObject = %v, want context.Canceled", err)
+	}
+	if content, _ := mustGet(t, p, "kept.txt", oss.GetObjectOptions{}); content != helloText {
+		t.Errorf("content = %q after cancelled delete", content)
+	}
+}
+
+func testConcurrentCreate(t *testing.T, p oss.StorageProvider) {
+	const writers = 16
+	var wg sync.WaitGroup
+	errs := make([]error, writers)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = save(p, "race.txt", fmt.Sprintf("writer %02d", i), oss.SaveObjectOptions{})
+		}()
+	}
+	wg.Wait()
+	winner := -1
+	for i, err := range errs {
+		switch {
+		case err == nil && winner >= 0:
+			t.Fatalf("writers %d and %d both created the object", winner, i)
+		case err == nil:
+			winner = i
+		case !errors.Is(err, oss.ErrFileAlreadyExists):
+			t.Errorf("writer %d: %v, want ErrFileAlreadyExists", i, err)
+		}
+	}
+	if winner < 0 {
+		t.Fatal("no writer created the object")
+	}
+	if content, _ := mustGet(t, p, "race.txt", oss.GetObjectOptions{}); content != fmt.Sprintf("writer %02d", winner) {
+		t.Errorf("content = %q, want writer %02d", content, winner)
+	}
+}
+
+func testConcurrentKeys(t *testing.T, p oss.StorageProvider) {
+	const workers = 16
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := fmt.Sprintf("many/%02d.txt", i)
+			content := strings.Repeat(fmt.Sprint(i), 100+i)
+			if err := save(p, key, content, oss.SaveObjectOptions{}); err != nil {
+				t.Errorf("SaveObject(%q): %v", key, err)
+				return
+			}
+			for range 3 {
+				reader, _, err := p.GetObject(context.Background(), key, oss.GetObjectOptions{})
+				if err != nil {
+					t.Errorf("GetObject(%q): %v", key, err)
+					return
+				}
+				got, err := io.ReadAll(reader)
+				_ = reader.Close()
+				if err != nil || string(got) != content {
+					t.Errorf("GetObject(%q) = %d bytes, %v", key, len(got), err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if list := mustList(t, p, "many"); len(list) != workers {
+		t.Errorf("GetFileList = %d entries, want %d", len(list), workers)
+	}
+}
+
+func save(p oss.StorageProvider, key, content string, options oss.SaveObjectOptions) error {
+	return p.SaveObject(context.Background(), bytes.NewReader([]byte(content)), key, options)
+}
+
+func mustSave(t *testing.T, p oss.StorageProvider, key, content string, options oss.SaveObjectOptions) {
+	t.Helper()
+	if err := save(p, key, content, options); err != nil {
+		t.Fatalf("SaveObject(%q): %v", key, err)
+	}
+}
+
+func mustGet(t *testing.T, p oss.StorageProvider, key string, options oss.GetObjectOptions) (string, *oss.GetObjectInfo) {
+	t.Helper()
+	reader, info, err := p.GetObject(context.Background(), key, options)
+	if err != nil {
+		t.Fatalf("GetObject(%q, %+v): %v", key, options, err)
+	}
+	defer func() { _ = reader.Close() }()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read %q: %v", key, err)
+	}
+	return string(content), info
+}
+
+func mustDelete(t *testing.T, p oss.StorageProvider, key string) {
+	t.Helper()
+	if err := p.DeleteObject(context.Background(), key); err != nil {
+		t.Fatalf("DeleteObject(%q): %v", key, err)
+	}
+}
+
+func mustList(t *testing.T, p oss.StorageProvider, prefix string) []oss.FileListElement {
+	t.Helper()
+	list, err := p.GetFileList(context.Background(), prefix)
+	if err != nil {
+		t.Fatalf("GetFileList(%q): %v", prefix, err)
+	}
+	return list
+}
+
+// objectKeys 返回排序后的对象键，列表顺序不属于约定
+func objectKeys(list []oss.FileListElement) []string {
+	keys := make([]string, 0, len(list))
+	for _, element := range list {
+		keys = append(keys, element.ObjectKey)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,141 @@
+package osstest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTPServer 进程内 SSH/SFTP 服务器，直接读写本机文件系统，仅支持 sftp 子系统
+type SFTPServer struct {
+	Addr   string
+	Config *ssh.ClientConfig // 连接该服务器所用的客户端配置
+
+	listener net.Listener
+	server   *ssh.ServerConfig
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewSFTPServer 启动监听本地回环地址的 SFTP 服务器，测试结束时关闭
+func NewSFTPServer(tb testing.TB) *SFTPServer {
+	tb.Helper()
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	server := &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if meta.User() == "cube" && string(password) == "cube" {
+				return nil, nil
+			}
+			return nil, errors.New("invalid credentials")
+		},
+	}
+	server.AddHostKey(signer)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	s := &SFTPServer{
+		Addr: listener.Addr().String(),
+		Config: &ssh.ClientConfig{
+			User:            "cube",
+			Auth:            []ssh.AuthMethod{ssh.Password("cube")},
+			HostKeyCallback: ssh.FixedHostKey(signer.PublicKey()),
+		},
+		listener: listener,
+		server:   server,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.accept()
+	tb.Cleanup(func() {
+		_ = listener.Close()
+		s.DropConnections()
+		s.wg.Wait()
+	})
+	return s
+}
+
+// DropConnections 断开当前所有连接，用于测试客户端重连
+func (s *SFTPServer) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+func (s *SFTPServer) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serve(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+func (s *SFTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	_, channels, requests, err := ssh.NewServerConn(conn, s.server)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer channel.Close()
+			for req := range requests {
+				// 子系统请求的负载为带长度前缀的子系统名称
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+				server, err := sftp.NewServer(channel)
+				if err != nil {
+					return
+				}
+				_ = server.Serve()
+				return
+			}
+		}()
+	}
+}
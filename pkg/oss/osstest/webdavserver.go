@@ -0,0 +1,69 @@
+package osstest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/webdav"
+)
+
+// webdavPrefix 测试服务器的 WebDAV 根路径，用于检验客户端对 href 前缀的处理
+const webdavPrefix = "/remote.php/dav/files/cube"
+
+// NewWebDAVServer 启动基于内存文件系统的 WebDAV 服务器，返回根集合地址，测试结束时关闭。
+// 在 golang.org/x/net/webdav 之上补充了 PUT 的 If-Match 与 If-None-Match 条件，行为与 Nextcloud 一致；
+// 使用 Basic 认证，用户名与密码均为 cube。
+func NewWebDAVServer(tb testing.TB) string {
+	tb.Helper()
+	handler := &webdav.Handler{
+		Prefix:     webdavPrefix,
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	}
+	// 修改操作串行执行，使条件检查与写入原子
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "cube" || password != "cube" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="cube"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, "PROPFIND", http.MethodOptions:
+			handler.ServeHTTP(w, r)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodPut && !putConditionsMet(handler, r) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	tb.Cleanup(server.Close)
+	return server.URL + webdavPrefix
+}
+
+// putConditionsMet 按当前资源判断 PUT 请求的 If-Match 与 If-None-Match 条件
+func putConditionsMet(handler *webdav.Handler, r *http.Request) bool {
+	name := strings.TrimPrefix(r.URL.Path, webdavPrefix)
+	info, err := handler.FileSystem.Stat(r.Context(), name)
+	exists := err == nil
+	etag := ""
+	if exists && !info.IsDir() {
+		head := httptest.NewRecorder()
+		handler.ServeHTTP(head, httptest.NewRequestWithContext(r.Context(), http.MethodHead, r.URL.Path, nil))
+		etag = head.Header().Get("ETag")
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch == "*" && exists {
+		return false
+	}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		return etag != "" && (ifMatch == "*" || ifMatch == etag)
+	}
+	return true
+}
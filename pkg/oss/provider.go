@@ -2,22 +2,66 @@ package oss
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"io"
 	"io/fs"
+	"regexp"
 	"strings"
 	"time"
 )
 
 // StorageProvider 定义存储服务接口
 type StorageProvider interface {
-	SaveObject(ctx context.Context, reader io.ReadSeeker, objectKey string) error
+	SaveObject(ctx context.Context, reader io.ReadSeeker, objectKey string, options SaveObjectOptions) error
 	DeleteObject(ctx context.Context, objectKey string) error
 	GetObject(ctx context.Context, objectKey string, options GetObjectOptions) (io.ReadCloser, *GetObjectInfo, error)
 	StatObject(ctx context.Context, objectKey string, options GetObjectOptions) (*GetObjectInfo, error)
 	GetFileList(ctx context.Context, prefix string) ([]FileListElement, error)
 }
 
+// SeekableProvider 由返回可随机读取对象的存储提供者实现。
+// 此类提供者不处理条件与范围请求，交由调用方基于读取器自行处理。
+type SeekableProvider interface {
+	Seekable() bool
+}
+
+// IsSeekable 判断存储提供者返回的对象是否可随机读取
+func IsSeekable(p StorageProvider) bool {
+	seekable, ok := As[SeekableProvider](p)
+	return ok && seekable.Seekable()
+}
+
+// As 沿装饰链查找实现了 T 的存储提供者，用法类似 errors.As
+func As[T any](p StorageProvider) (T, bool) {
+	for p != nil {
+		if target, ok := p.(T); ok {
+			return target, true
+		}
+		wrapper, ok := p.(interface{ Unwrap() StorageProvider })
+		if !ok {
+			break
+		}
+		p = wrapper.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// SaveObjectOptions 保存对象选项
+type SaveObjectOptions struct {
+	Overwrite bool   // 允许覆盖已有对象
+	IfMatch   string // 覆盖时要求当前对象的 ETag 匹配，为 * 时要求对象存在
+
+	OriginalName string            // 上传时的原始文件名
+	Uploader     string            // 上传者标识
+	Metadata     map[string]string // 自定义元数据，需先经过 NormalizeUserMetadata
+	Tags         map[string]string // 标签
+	SHA256       string            // 内容的 SHA-256 十六进制摘要，非空时存储前校验
+	UploadedAt   time.Time         // 非零时作为上传与修改时间，复制或迁移已有对象时保留原时间；S3 与 WebDAV 忽略
+}
+
 type ObjectConditions struct {
 	IfMatch           string
 	IfNoneMatch       string
@@ -28,15 +72,18 @@ type ObjectConditions struct {
 type GetObjectOptions struct {
 	Conditions ObjectConditions
 	Range      string
+	VersionID  string // 历史版本 ID，为空时读取当前版本
 }
 
 // FileListElement 文件列表元素
 type FileListElement struct {
-	Name         string `json:"name"`
-	Size         int64  `json:"size"`
-	Type         string `json:"type"`
-	LastModified string `json:"last_modified"`
-	ObjectKey    string `json:"object_key"`
+	Name         string            `json:"name"`
+	Size         int64             `json:"size"`
+	Type         string            `json:"type"`
+	LastModified string            `json:"last_modified"`
+	ObjectKey    string            `json:"object_key"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
 }
 
 // GetObjectInfo 获取对象内容
@@ -47,6 +94,8 @@ type GetObjectInfo struct {
 	AcceptRanges  string
 	ETag          string
 	LastModified  time.Time
+	Metadata      map[string]string // 自定义元数据
+	SHA256        string            // 完整对象的 SHA-256 十六进制摘要，未知时为空
 }
 
 type ObjectResponseError struct {
@@ -77,8 +126,13 @@ var (
 	ErrPreconditionFailed = errors.New("precondition failed")
 	// ErrInvalidRange 请求范围不合法
 	ErrInvalidRange = errors.New("invalid range")
+	// ErrChecksumMismatch 内容校验和不匹配
+	ErrChecksumMismatch = errors.New("checksum mismatch")
 )
 
+// internalDir 存储桶内部数据目录，不对外暴露
+const internalDir = ".cube"
+
 // NormalizeObjectKey 将对象键规范为不带尾斜杠的相对路径。
 func NormalizeObjectKey(objectKey string, allowEmpty bool) (string, bool, error) {
 	isDir := strings.HasSuffix(objectKey, "/")
@@ -86,8 +140,60 @@ func NormalizeObjectKey(objectKey string, allowEmpty bool) (string, bool, error)
 	if key == "" && allowEmpty {
 		return "", isDir, nil
 	}
-	if key == "." || strings.Contains(key, `\`) || !fs.ValidPath(key) {
+	if key == "." || strings.Contains(key, `\`) || !fs.ValidPath(key) || isInternalKey(key) {
 		return "", false, ErrInvalidObjectKey
 	}
 	return key, isDir, nil
 }
+
+// isInternalKey 判断对象键是否位于内部数据目录
+func isInternalKey(key string) bool {
+	return key == internalDir || strings.HasPrefix(key, internalDir+"/")
+}
+
+// etagMatches 按 RFC 9110 对 If-Match 使用强比较：弱 ETag 不匹配任何值，* 匹配任何存在的对象
+func etagMatches(header, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") || strings.HasPrefix(etag, "W/") {
+			continue
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// etagMatchesWeak 按 RFC 9110 对 If-None-Match 使用弱比较，忽略弱校验前缀
+func etagMatchesWeak(header, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+var internalIDRegex = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}(\.[0-9]{9})?Z-[0-9a-f]{8}$`)
+
+// newInternalID 生成按时间排序的内部条目 ID，用于回收站与历史版本
+func newInternalID(now time.Time) string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return now.UTC().Format("20060102T150405.000000000Z") + "-" + hex.EncodeToString(suffix)
+}
+
+func validInternalID(id string) bool {
+	return internalIDRegex.MatchString(id)
+}
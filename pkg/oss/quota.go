@@ -0,0 +1,389 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"go.uber.org/zap"
+)
+
+type quotaConfig struct {
+	MaxSize           int64 `mapstructure:"maxSize"`           // 桶容量上限，单位 MB
+	MaxObjects        int64 `mapstructure:"maxObjects"`        // 桶对象数上限
+	PrefixMaxSize     int64 `mapstructure:"prefixMaxSize"`     // 每个顶层目录的容量上限，单位 MB
+	PrefixMaxObjects  int64 `mapstructure:"prefixMaxObjects"`  // 每个顶层目录的对象数上限
+	ReconcileInterval int   `mapstructure:"reconcileInterval"` // 全量校准间隔，单位分钟
+}
+
+func (c quotaConfig) enabled() bool {
+	return c.MaxSize > 0 || c.MaxObjects > 0 || c.PrefixMaxSize > 0 || c.PrefixMaxObjects > 0
+}
+
+var (
+	// ErrQuotaExceeded 超出存储配额
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrQuotaVersioning 配额不统计历史版本，不能与多版本同时启用
+	ErrQuotaVersioning = errors.New("quota cannot be combined with versioning")
+)
+
+// QuotaUsage 配额用量
+type QuotaUsage struct {
+	Size       int64 `json:"size"`
+	Objects    int64 `json:"objects"`
+	MaxSize    int64 `json:"max_size"`
+	MaxObjects int64 `json:"max_objects"`
+}
+
+// QuotaReport 存储桶配额报告
+type QuotaReport struct {
+	QuotaUsage
+	Prefixes     map[string]QuotaUsage `json:"prefixes"`
+	ReconciledAt time.Time             `json:"reconciled_at"`
+}
+
+type quotaCounter struct {
+	size    int64
+	objects int64
+}
+
+// quotaJournal 记录校准遍历期间发生的用量变化，遍历结束后叠加到遍历结果上
+type quotaJournal struct {
+	total    quotaCounter
+	prefixes map[string]quotaCounter
+}
+
+func (j *quotaJournal) add(prefix string, size, objects int64) {
+	j.total.size += size
+	j.total.objects += objects
+	counter := j.prefixes[prefix]
+	j.prefixes[prefix] = quotaCounter{size: counter.size + size, objects: counter.objects + objects}
+}
+
+// QuotaProvider 在存储提供者外层统计用量并限制配额。
+// 用量包含回收站中的对象，删除进入回收站不释放配额，彻底删除后才释放；历史版本不计入用量，因此不能与多版本同时启用
+type QuotaProvider struct {
+	StorageProvider
+	limits       quotaConfig
+	reconcileMu  sync.Mutex
+	mu           sync.Mutex
+	total        quotaCounter
+	prefixes     map[string]quotaCounter
+	journal      *quotaJournal
+	reconciledAt time.Time
+	stop         chan struct{}
+	wg           sync.WaitGroup
+}
+
+// NewQuotaProvider 创建配额存储提供者，会先进行一次全量统计
+func NewQuotaProvider(ctx context.Context, provider StorageProvider, limits quotaConfig) (*QuotaProvider, error) {
+	if versions, ok := As[VersionProvider](provider); ok && versions.VersioningEnabled() {
+		return nil, ErrQuotaVersioning
+	}
+	p := &QuotaProvider{
+		StorageProvider: provider,
+		limits:          limits,
+		prefixes:        make(map[string]quotaCounter),
+		stop:            make(chan struct{}),
+	}
+	if err := p.Reconcile(ctx); err != nil {
+		return nil, err
+	}
+	if limits.ReconcileInterval > 0 {
+		p.wg.Add(1)
+		go p.reconcileLoop(time.Duration(limits.ReconcileInterval) * time.Minute)
+	}
+	return p, nil
+}
+
+// Unwrap 返回被装饰的存储提供者
+func (p *QuotaProvider) Unwrap() StorageProvider {
+	return p.StorageProvider
+}
+
+func (p *QuotaProvider) Close() error {
+	close(p.stop)
+	p.wg.Wait()
+	if closer, ok := p.StorageProvider.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// SaveObject 检查配额后保存对象，覆盖已有对象时只计算大小差值
+func (p *QuotaProvider) SaveObject(ctx context.Context, reader io.ReadSeeker, objectKey string, options SaveObjectOptions) error {
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err = reader.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	delta := quotaCounter{size: size, objects: 1}
+	if info, err := p.StorageProvider.StatObject(ctx, objectKey, GetObjectOptions{}); err == nil {
+		delta = quotaCounter{size: size - info.ContentLength}
+	}
+	prefix := quotaPrefix(objectKey)
+	if err := p.reserve(prefix, delta); err != nil {
+		return err
+	}
+	if err := p.StorageProvider.SaveObject(ctx, reader, objectKey, options); err != nil {
+		p.release(prefix, delta.size, delta.objects)
+		return err
+	}
+	return nil
+}
+
+// DeleteObject 删除对象并扣减用量，启用回收站时对象移入回收站，用量不变
+func (p *QuotaProvider) DeleteObject(ctx context.Context, objectKey string) error {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil {
+		return err
+	}
+	if p.TrashEnabled() {
+		return p.StorageProvider.DeleteObject(ctx, objectKey)
+	}
+	removed := make(map[string]quotaCounter)
+	if isDir {
+		err = WalkObjects(ctx, p.StorageProvider, key+"/", func(entry ObjectEntry) error {
+			prefix := quotaPrefix(entry.Key)
+			removed[prefix] = quotaCounter{size: removed[prefix].size + entry.Size, objects: removed[prefix].objects + 1}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	} else if info, err := p.StorageProvider.StatObject(ctx, key, GetObjectOptions{}); err == nil {
+		removed[quotaPrefix(key)] = quotaCounter{size: info.ContentLength, objects: 1}
+	} else if !errors.Is(err, ErrResourceNotExists) {
+		return err
+	}
+
+	if err := p.StorageProvider.DeleteObject(ctx, objectKey); err != nil {
+		return err
+	}
+	for prefix, counter := range removed {
+		p.release(prefix, counter.size, counter.objects)
+	}
+	return nil
+}
+
+func (p *QuotaProvider) TrashEnabled() bool {
+	trash, ok := As[TrashProvider](p.StorageProvider)
+	return ok && trash.TrashEnabled()
+}
+
+func (p *QuotaProvider) ListTrash(ctx context.Context) ([]TrashItem, error) {
+	trash, ok := As[TrashProvider](p.StorageProvider)
+	if !ok {
+		return nil, ErrTrashDisabled
+	}
+	return trash.ListTrash(ctx)
+}
+
+// RestoreTrash 还原回收站条目，回收站中的对象已计入用量，还原不改变用量
+func (p *QuotaProvider) RestoreTrash(ctx context.Context, id string) (*TrashItem, error) {
+	trash, ok := As[TrashProvider](p.StorageProvider)
+	if !ok {
+		return nil, ErrTrashDisabled
+	}
+	return trash.RestoreTrash(ctx, id)
+}
+
+// PurgeTrash 彻底删除回收站条目并释放其用量
+func (p *QuotaProvider) PurgeTrash(ctx context.Context, id string) error {
+	trash, ok := As[TrashProvider](p.StorageProvider)
+	if !ok {
+		return ErrTrashDisabled
+	}
+	items, err := trash.ListTrash(ctx)
+	if err != nil {
+		return err
+	}
+	index := slices.IndexFunc(items, func(item TrashItem) bool { return item.ID == id })
+	if index < 0 {
+		return ErrTrashItemNotFound
+	}
+	if err := trash.PurgeTrash(ctx, id); err != nil {
+		return err
+	}
+	usage := trashUsage(items[index])
+	p.release(trashPrefix(items[index]), usage.size, usage.objects)
+	return nil
+}
+
+// Reconcile 全量遍历存储桶与回收站，校准用量统计。
+// 遍历不持有锁，期间预占与归还的用量记录在 journal 中，遍历结束后叠加到结果上
+func (p *QuotaProvider) Reconcile(ctx context.Context) error {
+	p.reconcileMu.Lock()
+	defer p.reconcileMu.Unlock()
+	journal := &quotaJournal{prefixes: make(map[string]quotaCounter)}
+	p.mu.Lock()
+	p.journal = journal
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.journal = nil
+		p.mu.Unlock()
+	}()
+
+	usage := &quotaJournal{prefixes: make(map[string]quotaCounter)}
+	err := WalkObjects(ctx, p.StorageProvider, "", func(entry ObjectEntry) error {
+		usage.add(quotaPrefix(entry.Key), entry.Size, 1)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if p.TrashEnabled() {
+		items, err := p.ListTrash(ctx)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			counter := trashUsage(item)
+			usage.add(trashPrefix(item), counter.size, counter.objects)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total = quotaCounter{
+		size:    max(usage.total.size+journal.total.size, 0),
+		objects: max(usage.total.objects+journal.total.objects, 0),
+	}
+	for prefix, delta := range journal.prefixes {
+		usage.add(prefix, delta.size, delta.objects)
+	}
+	p.prefixes = make(map[string]quotaCounter, len(usage.prefixes))
+	for prefix, counter := range usage.prefixes {
+		if counter.objects > 0 {
+			p.prefixes[prefix] = quotaCounter{size: max(counter.size, 0), objects: counter.objects}
+		}
+	}
+	p.reconciledAt = time.Now()
+	return nil
+}
+
+// Report 返回当前用量报告
+func (p *QuotaProvider) Report() QuotaReport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	report := QuotaReport{
+		QuotaUsage: QuotaUsage{
+			Size:       p.total.size,
+			Objects:    p.total.objects,
+			MaxSize:    p.limits.MaxSize * humanize.MiByte,
+			MaxObjects: p.limits.MaxObjects,
+		},
+		Prefixes:     make(map[string]QuotaUsage, len(p.prefixes)),
+		ReconciledAt: p.reconciledAt,
+	}
+	for prefix, counter := range p.prefixes {
+		if prefix == "" {
+			continue
+		}
+		report.Prefixes[prefix] = QuotaUsage{
+			Size:       counter.size,
+			Objects:    counter.objects,
+			MaxSize:    p.limits.PrefixMaxSize * humanize.MiByte,
+			MaxObjects: p.limits.PrefixMaxObjects,
+		}
+	}
+	return report
+}
+
+// reserve 预占用量，超出配额时返回 ErrQuotaExceeded
+func (p *QuotaProvider) reserve(prefix string, delta quotaCounter) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if exceeds(p.total, delta, p.limits.MaxSize, p.limits.MaxObjects) {
+		return ErrQuotaExceeded
+	}
+	counter := p.prefixes[prefix]
+	if prefix != "" && exceeds(counter, delta, p.limits.PrefixMaxSize, p.limits.PrefixMaxObjects) {
+		return ErrQuotaExceeded
+	}
+	p.total.size += delta.size
+	p.total.objects += delta.objects
+	p.prefixes[prefix] = quotaCounter{size: counter.size + delta.size, objects: counter.objects + delta.objects}
+	if p.journal != nil {
+		p.journal.add(prefix, delta.size, delta.objects)
+	}
+	return nil
+}
+
+// release 归还用量
+func (p *QuotaProvider) release(prefix string, size, objects int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total.size = max(p.total.size-size, 0)
+	p.total.objects = max(p.total.objects-objects, 0)
+	counter := p.prefixes[prefix]
+	counter.size = max(counter.size-size, 0)
+	counter.objects = max(counter.objects-objects, 0)
+	if counter.objects == 0 {
+		delete(p.prefixes, prefix)
+	} else {
+		p.prefixes[prefix] = counter
+	}
+	if p.journal != nil {
+		p.journal.add(prefix, -size, -objects)
+	}
+}
+
+func (p *QuotaProvider) reconcileLoop(interval time.Duration) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			if err := p.Reconcile(context.Background()); err != nil {
+				zap.L().Error("配额校准失败", zap.Error(err))
+			}
+		}
+	}
+}
+
+func exceeds(counter, delta quotaCounter, maxSizeMB, maxObjects int64) bool {
+	if maxSizeMB > 0 && delta.size > 0 && counter.size+delta.size > maxSizeMB*humanize.MiByte {
+		return true
+	}
+	return maxObjects > 0 && delta.objects > 0 && counter.objects+delta.objects > maxObjects
+}
+
+// trashUsage 返回回收站条目占用的用量
+func trashUsage(item TrashItem) quotaCounter {
+	return quotaCounter{size: item.Size, objects: max(item.Objects, 1)}
+}
+
+// trashPrefix 返回回收站条目计入的顶层目录，目录条目下的对象都位于该目录的第一级路径下
+func trashPrefix(item TrashItem) string {
+	key, isDir, err := NormalizeObjectKey(item.ObjectKey, false)
+	if err != nil {
+		return ""
+	}
+	if isDir {
+		top, _, _ := strings.Cut(key, "/")
+		return top
+	}
+	return quotaPrefix(key)
+}
+
+// quotaPrefix 返回对象键的顶层目录，根目录下的对象返回空字符串
+func quotaPrefix(objectKey string) string {
+	key := strings.TrimPrefix(path.Clean("/"+objectKey), "/")
+	if top, _, found := strings.Cut(key, "/"); found {
+		return top
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+package oss_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cube-go/pkg/oss"
+)
+
+func newLocalProvider(t *testing.T, options oss.LocalStorageOptions) *oss.LocalStorageProvider {
+	t.Helper()
+	p, _ := newLocalProviderDir(t, options)
+	return p
+}
+
+// newLocalProviderDir 在临时目录创建本地存储，同时返回目录以便直接操作文件
+func newLocalProviderDir(t *testing.T, options oss.LocalStorageOptions) (*oss.LocalStorageProvider, string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := filepath.Rel(wd, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := oss.NewLocalStorageProvider(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+	return p, dir
+}
+
+func TestQuotaTrash(t *testing.T) {
+	ctx := context.Background()
+	local := newLocalProvider(t, oss.LocalStorageOptions{Trash: true, Metadata: "bolt"})
+	p, err := oss.NewQuotaProviderWithLimits(ctx, local, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+	save := func(key string) error {
+		return p.SaveObject(ctx, strings.NewReader(key), key, oss.SaveObjectOptions{})
+	}
+	for _, key := range []string{"a.txt", "album/b.txt", "album/c.txt"} {
+		if err := save(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 移入回收站的对象仍计入用量
+	if err := p.DeleteObject(ctx, "album/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := save("d.txt"); !errors.Is(err, oss.ErrQuotaExceeded) {
+		t.Fatalf("SaveObject with a full trash = %v, want ErrQuotaExceeded", err)
+	}
+	if err := p.Reconcile(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Report(); got.Objects != 3 || got.Prefixes["album"].Objects != 2 {
+		t.Fatalf("usage after reconcile = %+v, want 3 objects with 2 in album", got)
+	}
+
+	trash, ok := oss.As[oss.TrashProvider](oss.StorageProvider(p))
+	if !ok || !trash.TrashEnabled() {
+		t.Fatal("trash not reachable through the quota provider")
+	}
+	if _, ok := trash.(*oss.QuotaProvider); !ok {
+		t.Fatalf("trash resolved to %T, want *oss.QuotaProvider", trash)
+	}
+	items, err := trash.ListTrash(ctx)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("ListTrash = %v, %v", items, err)
+	}
+	if _, err := trash.RestoreTrash(ctx, items[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Report().Objects; got != 3 {
+		t.Fatalf("objects after restore = %d, want 3", got)
+	}
+
+	// 彻底删除后释放用量
+	if err := p.DeleteObject(ctx, "album/"); err != nil {
+		t.Fatal(err)
+	}
+	if items, err = trash.ListTrash(ctx); err != nil || len(items) != 1 {
+		t.Fatalf("ListTrash = %v, %v", items, err)
+	}
+	if err := trash.PurgeTrash(ctx, items[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Report(); got.Objects != 1 || len(got.Prefixes) != 0 {
+		t.Fatalf("usage after purge = %+v, want 1 object", got)
+	}
+	if err := save("d.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuotaRejectsVersioning(t *testing.T) {
+	local := newLocalProvider(t, oss.LocalStorageOptions{Versioning: true, Metadata: "bolt"})
+	if _, err := oss.NewQuotaProviderWithLimits(context.Background(), local, 0, 3); !errors.Is(err, oss.ErrQuotaVersioning) {
+		t.Fatalf("NewQuotaProvider with versioning = %v, want ErrQuotaVersioning", err)
+	}
+}
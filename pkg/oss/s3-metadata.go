@@ -0,0 +1,132 @@
+package oss
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// GetMetadata 获取对象的自定义元数据与标签
+func (p *S3StorageProvider) GetMetadata(ctx context.Context, objectKey string) (*UserMetadata, error) {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return nil, ErrInvalidObjectKey
+	}
+	head, err := p.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(p.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, mapS3Error(err)
+	}
+	tags, err := p.getTags(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &UserMetadata{Metadata: decodeS3Metadata(head.Metadata), Tags: tags}, nil
+}
+
+// UpdateMetadata 更新对象的自定义元数据与标签。
+// S3 不支持原地修改用户元数据，需以 REPLACE 方式将对象复制到自身。
+func (p *S3StorageProvider) UpdateMetadata(ctx context.Context, objectKey string, update UserMetadata) error {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return ErrInvalidObjectKey
+	}
+	head, err := p.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(p.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return mapS3Error(err)
+	}
+	if update.Metadata != nil {
+		_, err = p.client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:            aws.String(p.bucketName),
+			Key:               aws.String(key),
+			CopySource:        aws.String(copySource(p.bucketName, key)),
+			CopySourceIfMatch: head.ETag,
+			ContentType:       head.ContentType,
+			Metadata:          encodeS3Metadata(update.Metadata),
+			MetadataDirective: types.MetadataDirectiveReplace,
+		})
+		if err != nil {
+			return mapS3Error(err)
+		}
+	}
+	if update.Tags == nil {
+		return nil
+	}
+	if len(update.Tags) == 0 {
+		_, err = p.client.DeleteObjectTagging(ctx, &s3.DeleteObjectTaggingInput{
+			Bucket: aws.String(p.bucketName),
+			Key:    aws.String(key),
+		})
+		return mapS3Error(err)
+	}
+	tagSet := make([]types.Tag, 0, len(update.Tags))
+	for k, v := range update.Tags {
+		tagSet = append(tagSet, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	_, err = p.client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(p.bucketName),
+		Key:     aws.String(key),
+		Tagging: &types.Tagging{TagSet: tagSet},
+	})
+	return mapS3Error(err)
+}
+
+func (p *S3StorageProvider) getTags(ctx context.Context, key string) (map[string]string, error) {
+	result, err := p.client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(p.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, mapS3Error(err)
+	}
+	if len(result.TagSet) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(result.TagSet))
+	for _, tag := range result.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
+}
+
+func encodeS3Metadata(metadata map[string]string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	encoded := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		encoded[k] = encodeMetadataValue(v)
+	}
+	return encoded
+}
+
+func decodeS3Metadata(metadata map[string]string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	decoded := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		decoded[k] = decodeMetadataValue(v)
+	}
+	return decoded
+}
+
+// encodeS3Tagging 将标签编码为 x-amz-tagging 所需的查询字符串
+func encodeS3Tagging(tags map[string]string) *string {
+	if len(tags) == 0 {
+		return nil
+	}
+	values := make(url.Values, len(tags))
+	for k, v := range tags {
+		values.Set(k, v)
+	}
+	return aws.String(values.Encode())
+}
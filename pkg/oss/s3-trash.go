@@ -0,0 +1,264 @@
+package oss
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+)
+
+// TrashEnabled 是否启用回收站
+func (p *S3StorageProvider) TrashEnabled() bool {
+	return p.options.Trash
+}
+
+// moveToTrash 将对象或目录复制到回收站后删除，目标不存在时不做处理。
+// 目录只删除已复制到回收站的对象，期间新上传的对象保留；失败时清除已复制的部分，避免留下没有条目的数据
+func (p *S3StorageProvider) moveToTrash(ctx context.Context, key string, isDir bool) (err error) {
+	item := TrashItem{ObjectKey: key, DeletedAt: time.Now()}
+	item.ID = newInternalID(item.DeletedAt)
+	dataKey := path.Join(trashDir, item.ID, trashDataName)
+	var committed bool
+	defer func() {
+		if err != nil && !committed {
+			p.discardTrash(ctx, item.ID)
+		}
+	}()
+
+	if !isDir {
+		head, err := p.client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(p.bucketName),
+			Key:    aws.String(key),
+		})
+		if err = mapS3Error(err); errors.Is(err, ErrResourceNotExists) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		item.Size = aws.ToInt64(head.ContentLength)
+		if err := p.copyObject(ctx, key, dataKey); err != nil {
+			return err
+		}
+		if err := p.putTrashItem(ctx, item); err != nil {
+			return err
+		}
+		_, err = p.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(p.bucketName),
+			Key:    aws.String(key),
+		})
+		return mapS3Error(err)
+	}
+
+	item.ObjectKey += "/"
+	var copied []string
+	err = p.walkObjects(ctx, item.ObjectKey, func(entry ObjectEntry) error {
+		relative := strings.TrimPrefix(entry.Key, item.ObjectKey)
+		if err := p.copyObject(ctx, entry.Key, dataKey+"/"+relative); err != nil {
+			return err
+		}
+		copied = append(copied, entry.Key)
+		item.Size += entry.Size
+		item.Objects++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if item.Objects == 0 {
+		return nil
+	}
+	if err := p.putTrashItem(ctx, item); err != nil {
+		return err
+	}
+	// 条目已写入，此后部分对象删除失败时已删除的对象仍需从回收站还原，不再清除
+	committed = true
+	return deleteKeys(ctx, p.client, p.bucketName, copied)
+}
+
+// discardTrash 清除未能完成的回收站条目的数据
+func (p *S3StorageProvider) discardTrash(ctx context.Context, id string) {
+	if err := deleteFolderContents(context.WithoutCancel(ctx), p.client, p.bucketName, path.Join(trashDir, id)+"/"); err != nil {
+		zap.L().Warn("清除未完成的回收站条目失败", zap.String("id", id), zap.Error(err))
+	}
+}
+
+// ListTrash 列出回收站条目，按删除时间倒序
+func (p *S3StorageProvider) ListTrash(ctx context.Context) ([]TrashItem, error) {
+	if !p.options.Trash {
+		return nil, ErrTrashDisabled
+	}
+	paginator := s3.NewListObjectsV2Paginator(p.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(p.bucketName),
+		Prefix:    aws.String(trashDir + "/"),
+		Delimiter: aws.String("/"),
+	})
+	items := make([]TrashItem, 0)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, mapS3Error(err)
+		}
+		for _, common := range page.CommonPrefixes {
+			id := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(common.Prefix), trashDir+"/"), "/")
+			if !validInternalID(id) {
+				continue
+			}
+			item, err := p.readTrashItem(ctx, id)
+			if err != nil {
+				continue
+			}
+			items = append(items, *item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+	return items, nil
+}
+
+// RestoreTrash 将回收站条目还原到原位置，原位置已存在时返回 ErrFileAlreadyExists
+func (p *S3StorageProvider) RestoreTrash(ctx context.Context, id string) (*TrashItem, error) {
+	if !p.options.Trash {
+		return nil, ErrTrashDisabled
+	}
+	if !validInternalID(id) {
+		return nil, ErrTrashItemNotFound
+	}
+	item, err := p.readTrashItem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	key, isDir, err := NormalizeObjectKey(item.ObjectKey, false)
+	if err != nil {
+		return nil, err
+	}
+	dataKey := path.Join(trashDir, id, trashDataName)
+
+	if !isDir {
+		if _, err := p.StatObject(ctx, key, GetObjectOptions{}); err == nil {
+			return nil, ErrFileAlreadyExists
+		} else if !errors.Is(err, ErrResourceNotExists) {
+			return nil, err
+		}
+		if err := p.copyObject(ctx, dataKey, key); err != nil {
+			return nil, err
+		}
+	} else {
+		existing, err := p.GetFileList(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if len(existing) > 0 {
+			return nil, ErrFileAlreadyExists
+		}
+		err = p.walkTrashData(ctx, dataKey+"/", func(objectKey string) error {
+			return p.copyObject(ctx, objectKey, key+"/"+strings.TrimPrefix(objectKey, dataKey+"/"))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return item, deleteFolderContents(ctx, p.client, p.bucketName, path.Join(trashDir, id)+"/")
+}
+
+// PurgeTrash 永久删除回收站条目
+func (p *S3StorageProvider) PurgeTrash(ctx context.Context, id string) error {
+	if !p.options.Trash {
+		return ErrTrashDisabled
+	}
+	if !validInternalID(id) {
+		return ErrTrashItemNotFound
+	}
+	if _, err := p.readTrashItem(ctx, id); err != nil {
+		return err
+	}
+	return deleteFolderContents(ctx, p.client, p.bucketName, path.Join(trashDir, id)+"/")
+}
+
+func (p *S3StorageProvider) putTrashItem(ctx context.Context, item TrashItem) error {
+	meta, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	_, err = p.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(p.bucketName),
+		Key:         aws.String(path.Join(trashDir, item.ID, trashMetaName)),
+		Body:        bytes.NewReader(meta),
+		ContentType: aws.String("application/json"),
+	})
+	return mapS3Error(err)
+}
+
+func (p *S3StorageProvider) readTrashItem(ctx context.Context, id string) (*TrashItem, error) {
+	result, err := p.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(p.bucketName),
+		Key:    aws.String(path.Join(trashDir, id, trashMetaName)),
+	})
+	if err != nil {
+		if errors.Is(mapS3Error(err), ErrResourceNotExists) {
+			return nil, ErrTrashItemNotFound
+		}
+		return nil, mapS3Error(err)
+	}
+	defer func() { _ = result.Body.Close() }()
+	data, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, err
+	}
+	var item TrashItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, err
+	}
+	item.ID = id
+	return &item, nil
+}
+
+// walkTrashData 遍历内部前缀下的所有对象键
+func (p *S3StorageProvider) walkTrashData(ctx context.Context, prefix string, fn func(objectKey string) error) error {
+	paginator := s3.NewListObjectsV2Paginator(p.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(p.bucketName),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return mapS3Error(err)
+		}
+		for _, file := range page.Contents {
+			if err := fn(aws.ToString(file.Key)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copyObject 在同一存储桶内复制对象
+func (p *S3StorageProvider) copyObject(ctx context.Context, source, target string) error {
+	_, err := p.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(p.bucketName),
+		Key:        aws.String(target),
+		CopySource: aws.String(copySource(p.bucketName, source)),
+	})
+	return mapS3Error(err)
+}
+
+// copySource 生成 URL 编码的复制源
+func copySource(bucketName, key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return bucketName + "/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,211 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// VersioningEnabled 是否启用多版本
+func (p *S3StorageProvider) VersioningEnabled() bool {
+	return p.options.Versioning != ""
+}
+
+// enableBucketVersioning 确保 S3 存储桶已开启原生版本控制
+func enableBucketVersioning(ctx context.Context, client *s3.Client, bucketName string) error {
+	result, err := client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: aws.String(bucketName)})
+	if err != nil {
+		return mapS3Error(err)
+	}
+	if result.Status == types.BucketVersioningStatusEnabled {
+		return nil
+	}
+	_, err = client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(bucketName),
+		VersioningConfiguration: &types.VersioningConfiguration{Status: types.BucketVersioningStatusEnabled},
+	})
+	return mapS3Error(err)
+}
+
+// versionTarget 返回读取历史版本时使用的对象键与版本 ID
+func (p *S3StorageProvider) versionTarget(key, versionID string) (*string, *string, error) {
+	switch p.options.Versioning {
+	case S3VersioningNative:
+		return aws.String(key), aws.String(versionID), nil
+	case S3VersioningSuffix:
+		if !validInternalID(versionID) {
+			return nil, nil, ErrResourceNotExists
+		}
+		return aws.String(versionKey(key, versionID)), nil, nil
+	default:
+		return nil, nil, ErrResourceNotExists
+	}
+}
+
+// archiveCurrent 在覆盖前将当前版本复制到内部目录，仅用于 suffix 模式
+// 指定 ifMatch 时先校验当前版本，避免条件写入失败后留下多余的历史版本
+func (p *S3StorageProvider) archiveCurrent(ctx context.Context, key, ifMatch string) error {
+	if p.options.Versioning != S3VersioningSuffix {
+		return nil
+	}
+	info, err := p.StatObject(ctx, key, GetObjectOptions{})
+	if errors.Is(err, ErrResourceNotExists) {
+		if ifMatch != "" {
+			return ErrPreconditionFailed
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if ifMatch != "" && !etagMatches(ifMatch, info.ETag) {
+		return ErrPreconditionFailed
+	}
+	return p.copyObject(ctx, key, versionKey(key, newInternalID(time.Now())))
+}
+
+// ListVersions 列出对象的所有版本，按时间倒序
+func (p *S3StorageProvider) ListVersions(ctx context.Context, objectKey string) ([]ObjectVersion, error) {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return nil, ErrInvalidObjectKey
+	}
+	switch p.options.Versioning {
+	case S3VersioningNative:
+		return p.listNativeVersions(ctx, key)
+	case S3VersioningSuffix:
+		return p.listSuffixVersions(ctx, key)
+	default:
+		return nil, ErrVersioningDisabled
+	}
+}
+
+func (p *S3StorageProvider) listNativeVersions(ctx context.Context, key string) ([]ObjectVersion, error) {
+	paginator := s3.NewListObjectVersionsPaginator(p.client, &s3.ListObjectVersionsInput{
+		Bucket: aws.String(p.bucketName),
+		Prefix: aws.String(key),
+	})
+	versions := make([]ObjectVersion, 0)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, mapS3Error(err)
+		}
+		for _, version := range page.Versions {
+			if aws.ToString(version.Key) != key {
+				continue
+			}
+			versions = append(versions, ObjectVersion{
+				VersionID:    aws.ToString(version.VersionId),
+				Size:         aws.ToInt64(version.Size),
+				ETag:         aws.ToString(version.ETag),
+				LastModified: aws.ToTime(version.LastModified),
+				IsLatest:     aws.ToBool(version.IsLatest),
+			})
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
+	return versions, nil
+}
+
+func (p *S3StorageProvider) listSuffixVersions(ctx context.Context, key string) ([]ObjectVersion, error) {
+	versions := make([]ObjectVersion, 0)
+	if info, err := p.StatObject(ctx, key, GetObjectOptions{}); err == nil {
+		versions = append(versions, ObjectVersion{
+			Size:         info.ContentLength,
+			ETag:         info.ETag,
+			LastModified: info.LastModified,
+			IsLatest:     true,
+		})
+	} else if !errors.Is(err, ErrResourceNotExists) {
+		return nil, err
+	}
+
+	prefix := versionKey(key, "")
+	paginator := s3.NewListObjectsV2Paginator(p.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(p.bucketName),
+		Prefix: aws.String(prefix),
+	})
+	archived := make([]ObjectVersion, 0)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, mapS3Error(err)
+		}
+		for _, file := range page.Contents {
+			id := strings.TrimPrefix(aws.ToString(file.Key), prefix)
+			if !validInternalID(id) {
+				continue
+			}
+			archived = append(archived, ObjectVersion{
+				VersionID:    id,
+				Size:         aws.ToInt64(file.Size),
+				ETag:         aws.ToString(file.ETag),
+				LastModified: aws.ToTime(file.LastModified),
+			})
+		}
+	}
+	sort.Slice(archived, func(i, j int) bool {
+		return archived[i].VersionID > archived[j].VersionID
+	})
+	return append(versions, archived...), nil
+}
+
+// RestoreVersion 将历史版本恢复为当前版本，当前版本会被保留为新的历史版本
+func (p *S3StorageProvider) RestoreVersion(ctx context.Context, objectKey string, versionID string) error {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return ErrInvalidObjectKey
+	}
+	if p.options.Versioning == "" {
+		return ErrVersioningDisabled
+	}
+	if _, err := p.StatObject(ctx, key, GetObjectOptions{VersionID: versionID}); errors.Is(err, ErrResourceNotExists) {
+		return ErrVersionNotFound
+	} else if err != nil {
+		return err
+	}
+	if p.options.Versioning == S3VersioningNative {
+		_, err = p.client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:     aws.String(p.bucketName),
+			Key:        aws.String(key),
+			CopySource: aws.String(copySource(p.bucketName, key) + "?versionId=" + url.QueryEscape(versionID)),
+		})
+		return mapS3Error(err)
+	}
+	if err := p.archiveCurrent(ctx, key, ""); err != nil {
+		return err
+	}
+	return p.copyObject(ctx, versionKey(key, versionID), key)
+}
+
+// DeleteVersion 删除历史版本
+func (p *S3StorageProvider) DeleteVersion(ctx context.Context, objectKey string, versionID string) error {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return ErrInvalidObjectKey
+	}
+	if p.options.Versioning == "" {
+		return ErrVersioningDisabled
+	}
+	if _, err := p.StatObject(ctx, key, GetObjectOptions{VersionID: versionID}); errors.Is(err, ErrResourceNotExists) {
+		return ErrVersionNotFound
+	} else if err != nil {
+		return err
+	}
+	input := &s3.DeleteObjectInput{Bucket: aws.String(p.bucketName)}
+	if input.Key, input.VersionId, err = p.versionTarget(key, versionID); err != nil {
+		return err
+	}
+	_, err = p.client.DeleteObject(ctx, input)
+	return mapS3Error(err)
+}
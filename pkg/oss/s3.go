@@ -2,10 +2,16 @@ package oss
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"path"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -22,15 +28,25 @@ import (
 type S3StorageProvider struct {
 	client     *s3.Client
 	bucketName string
+	options    S3StorageOptions
+}
+
+// S3StorageOptions S3存储提供者选项
+type S3StorageOptions struct {
+	Trash      bool   // 删除时移入回收站
+	Versioning string // 多版本实现方式，为空时不启用
+	// ListMetadata 列出文件时逐个读取内容类型、自定义元数据与标签，每个对象额外两次请求；
+	// 未启用时按扩展名推断类型，启用对象索引后由索引提供元数据
+	ListMetadata bool
 }
 
 // NewS3StorageProvider 创建S3存储提供者
-func NewS3StorageProvider(client *s3.Client, bucketName string) StorageProvider {
-	return &S3StorageProvider{client: client, bucketName: bucketName}
+func NewS3StorageProvider(client *s3.Client, bucketName string, options S3StorageOptions) StorageProvider {
+	return &S3StorageProvider{client: client, bucketName: bucketName, options: options}
 }
 
 // SaveObject 存储对象
-func (p *S3StorageProvider) SaveObject(ctx context.Context, reader io.ReadSeeker, objectKey string) error {
+func (p *S3StorageProvider) SaveObject(ctx context.Context, reader io.ReadSeeker, objectKey string, options SaveObjectOptions) error {
 	key, isDir, err := NormalizeObjectKey(objectKey, false)
 	if err != nil || isDir {
 		return ErrInvalidObjectKey
@@ -43,16 +59,74 @@ func (p *S3StorageProvider) SaveObject(ctx context.Context, reader io.ReadSeeker
 		return err
 	}
 
-	_, err = p.client.PutObject(ctx, &s3.PutObjectInput{
+	input := &s3.PutObjectInput{
 		Bucket:      aws.String(p.bucketName),
 		Key:         aws.String(key),
 		Body:        reader,
 		ContentType: aws.String(mime.String()),
 		IfNoneMatch: aws.String("*"),
-	})
-	if errors.Is(mapS3Error(err), ErrPreconditionFailed) {
+		Metadata:    encodeS3Metadata(options.Metadata),
+		Tagging:     encodeS3Tagging(options.Tags),
+	}
+	if options.SHA256 != "" {
+		digest, err := hex.DecodeString(options.SHA256)
+		if err != nil || len(digest) != sha256.Size {
+			return ErrChecksumMismatch
+		}
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+		input.ChecksumSHA256 = aws.String(base64.StdEncoding.EncodeToString(digest))
+	}
+	if options.Overwrite || options.IfMatch != "" || p.options.Versioning != "" {
+		input.IfNoneMatch = nil
+		input.IfMatch = optionalString(options.IfMatch)
+		if err := p.archiveCurrent(ctx, key, options.IfMatch); err != nil {
+			return err
+		}
+	}
+	_, err = p.client.PutObject(ctx, input)
+	if input.IfNoneMatch != nil && errors.Is(mapS3Error(err), ErrPreconditionFailed) {
 		return ErrFileAlreadyExists
 	}
+	if input.IfMatch != nil && errors.Is(mapS3Error(err), ErrResourceNotExists) {
+		return ErrPreconditionFailed
+	}
+	return mapS3Error(err)
+}
+
+// rewriteContent 以新内容替换对象，保留内容类型、自定义元数据与标签，后缀方式的多版本不保留历史版本。
+// 原生多版本的存储桶仍会由 S3 保留旧版本
+func (p *S3StorageProvider) rewriteContent(ctx context.Context, objectKey string, reader io.ReadSeeker, ifMatch string) error {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return ErrInvalidObjectKey
+	}
+	head, err := p.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:  aws.String(p.bucketName),
+		Key:     aws.String(key),
+		IfMatch: optionalString(ifMatch),
+	})
+	if err != nil {
+		return mapS3Error(err)
+	}
+	tags, err := p.getTags(ctx, key)
+	if err != nil {
+		return err
+	}
+	if ifMatch == "" {
+		ifMatch = aws.ToString(head.ETag)
+	}
+	_, err = p.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(p.bucketName),
+		Key:         aws.String(key),
+		Body:        reader,
+		ContentType: head.ContentType,
+		Metadata:    head.Metadata,
+		Tagging:     encodeS3Tagging(tags),
+		IfMatch:     aws.String(ifMatch),
+	})
+	if errors.Is(mapS3Error(err), ErrResourceNotExists) {
+		return ErrPreconditionFailed
+	}
 	return mapS3Error(err)
 }
 
@@ -62,7 +136,9 @@ func (p *S3StorageProvider) DeleteObject(ctx context.Context, objectKey string)
 	if err != nil {
 		return err
 	}
-	if isDir {
+	if p.options.Trash {
+		err = p.moveToTrash(ctx, key, isDir)
+	} else if isDir {
 		err = deleteFolderContents(ctx, p.client, p.bucketName, key+"/")
 	} else {
 		_, err = p.client.DeleteObject(ctx, &s3.DeleteObjectInput{
@@ -84,9 +160,15 @@ func (p *S3StorageProvider) GetObject(ctx context.Context, objectKey string, opt
 		return nil, nil, ErrInvalidObjectKey
 	}
 	input := &s3.GetObjectInput{
-		Bucket: aws.String(p.bucketName),
-		Key:    aws.String(key),
-		Range:  optionalString(options.Range),
+		Bucket:       aws.String(p.bucketName),
+		Key:          aws.String(key),
+		Range:        optionalString(options.Range),
+		ChecksumMode: types.ChecksumModeEnabled,
+	}
+	if options.VersionID != "" {
+		if input.Key, input.VersionId, err = p.versionTarget(key, options.VersionID); err != nil {
+			return nil, nil, err
+		}
 	}
 	applyGetConditions(input, options.Conditions)
 	result, err := p.client.GetObject(ctx, input)
@@ -100,6 +182,8 @@ func (p *S3StorageProvider) GetObject(ctx context.Context, objectKey string, opt
 		AcceptRanges:  "bytes",
 		ETag:          aws.ToString(result.ETag),
 		LastModified:  aws.ToTime(result.LastModified),
+		Metadata:      decodeS3Metadata(result.Metadata),
+		SHA256:        s3ChecksumHex(result.ChecksumSHA256, result.ChecksumType),
 	}, nil
 }
 
@@ -109,9 +193,15 @@ func (p *S3StorageProvider) StatObject(ctx context.Context, objectKey string, op
 		return nil, ErrInvalidObjectKey
 	}
 	input := &s3.HeadObjectInput{
-		Bucket: aws.String(p.bucketName),
-		Key:    aws.String(key),
-		Range:  optionalString(options.Range),
+		Bucket:       aws.String(p.bucketName),
+		Key:          aws.String(key),
+		Range:        optionalString(options.Range),
+		ChecksumMode: types.ChecksumModeEnabled,
+	}
+	if options.VersionID != "" {
+		if input.Key, input.VersionId, err = p.versionTarget(key, options.VersionID); err != nil {
+			return nil, err
+		}
 	}
 	applyHeadConditions(input, options.Conditions)
 	result, err := p.client.HeadObject(ctx, input)
@@ -125,10 +215,12 @@ func (p *S3StorageProvider) StatObject(ctx context.Context, objectKey string, op
 		AcceptRanges:  "bytes",
 		ETag:          aws.ToString(result.ETag),
 		LastModified:  aws.ToTime(result.LastModified),
+		Metadata:      decodeS3Metadata(result.Metadata),
+		SHA256:        s3ChecksumHex(result.ChecksumSHA256, result.ChecksumType),
 	}, nil
 }
 
-// GetFileList 获取文件列表
+// GetFileList 获取文件列表，默认只使用列表请求返回的信息
 func (p *S3StorageProvider) GetFileList(ctx context.Context, requestedPrefix string) ([]FileListElement, error) {
 	key, _, err := NormalizeObjectKey(requestedPrefix, true)
 	if err != nil {
@@ -151,6 +243,9 @@ func (p *S3StorageProvider) GetFileList(ctx context.Context, requestedPrefix str
 		}
 		for _, common := range page.CommonPrefixes {
 			commonPrefix := aws.ToString(common.Prefix)
+			if commonPrefix == internalDir+"/" {
+				continue
+			}
 			fileList = append(fileList, FileListElement{
 				Name:      strings.TrimSuffix(strings.TrimPrefix(commonPrefix, prefix), "/"),
 				ObjectKey: commonPrefix,
@@ -163,27 +258,67 @@ func (p *S3StorageProvider) GetFileList(ctx context.Context, requestedPrefix str
 			if strings.Contains(name, "/") {
 				continue
 			}
-			fileList = append(fileList, FileListElement{
+			element := FileListElement{
 				LastModified: aws.ToTime(file.LastModified).Local().Format(time.RFC3339),
 				Name:         name,
 				ObjectKey:    objectKey,
 				Size:         aws.ToInt64(file.Size),
-				Type:         getS3FileType(ctx, p.client, p.bucketName, objectKey),
-			})
+				Type:         classifyMIME(mime.TypeByExtension(path.Ext(name))),
+			}
+			if !p.options.ListMetadata {
+				fileList = append(fileList, element)
+				continue
+			}
+			if head, err := p.client.HeadObject(ctx, &s3.HeadObjectInput{
+				Bucket: aws.String(p.bucketName),
+				Key:    aws.String(objectKey),
+			}); err == nil {
+				element.Type = classifyMIME(aws.ToString(head.ContentType))
+				element.Metadata = decodeS3Metadata(head.Metadata)
+			}
+			if tags, err := p.getTags(ctx, objectKey); err == nil {
+				element.Tags = tags
+			}
+			fileList = append(fileList, element)
 		}
 	}
 	return fileList, nil
 }
 
-func getS3FileType(ctx context.Context, client *s3.Client, bucketName, objectKey string) string {
-	result, err := client.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(objectKey),
-	})
+// walkObjects 递归遍历前缀下的所有对象
+func (p *S3StorageProvider) walkObjects(ctx context.Context, requestedPrefix string, fn func(ObjectEntry) error) error {
+	key, _, err := NormalizeObjectKey(requestedPrefix, true)
 	if err != nil {
-		return "binary"
+		return err
+	}
+	prefix := key
+	if prefix != "" {
+		prefix += "/"
 	}
-	return classifyMIME(aws.ToString(result.ContentType))
+	paginator := s3.NewListObjectsV2Paginator(p.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(p.bucketName),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return mapS3Error(err)
+		}
+		for _, file := range page.Contents {
+			if strings.HasSuffix(aws.ToString(file.Key), "/") || isInternalKey(aws.ToString(file.Key)) {
+				continue
+			}
+			err := fn(ObjectEntry{
+				Key:          aws.ToString(file.Key),
+				Size:         aws.ToInt64(file.Size),
+				LastModified: aws.ToTime(file.LastModified),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func classifyMIME(mimeType string) string {
@@ -209,12 +344,26 @@ func deleteFolderContents(ctx context.Context, client *s3.Client, bucketName, pr
 		if err != nil {
 			return mapS3Error(err)
 		}
-		if len(page.Contents) == 0 {
-			continue
-		}
-		objects := make([]types.ObjectIdentifier, 0, len(page.Contents))
+		keys := make([]string, 0, len(page.Contents))
 		for _, object := range page.Contents {
-			objects = append(objects, types.ObjectIdentifier{Key: object.Key})
+			keys = append(keys, aws.ToString(object.Key))
+		}
+		if err := deleteKeys(ctx, client, bucketName, keys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// s3DeleteBatch 单次 DeleteObjects 请求最多删除的对象数
+const s3DeleteBatch = 1000
+
+// deleteKeys 批量删除指定的对象
+func deleteKeys(ctx context.Context, client *s3.Client, bucketName string, keys []string) error {
+	for batch := range slices.Chunk(keys, s3DeleteBatch) {
+		objects := make([]types.ObjectIdentifier, 0, len(batch))
+		for _, key := range batch {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
 		}
 		result, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
 			Bucket: aws.String(bucketName),
@@ -248,6 +397,18 @@ func applyHeadConditions(input *s3.HeadObjectInput, conditions ObjectConditions)
 	input.IfUnmodifiedSince = conditions.IfUnmodifiedSince
 }
 
+// s3ChecksumHex 将 S3 返回的完整对象 SHA-256 转为十六进制，分片上传产生的组合校验和无法使用
+func s3ChecksumHex(value *string, checksumType types.ChecksumType) string {
+	if value == nil || checksumType == types.ChecksumTypeComposite {
+		return ""
+	}
+	digest, err := base64.StdEncoding.DecodeString(*value)
+	if err != nil || len(digest) != sha256.Size {
+		return ""
+	}
+	return hex.EncodeToString(digest)
+}
+
 func optionalString(value string) *string {
 	if value == "" {
 		return nil
@@ -276,6 +437,8 @@ func mapS3Error(err error) error {
 			return wrapS3ResponseError(err, ErrPreconditionFailed)
 		case "InvalidRange", "RequestedRangeNotSatisfiable":
 			return wrapS3ResponseError(err, ErrInvalidRange)
+		case "BadDigest", "XAmzContentChecksumMismatch":
+			return ErrChecksumMismatch
 		}
 	}
 	switch s3StatusCode(err) {
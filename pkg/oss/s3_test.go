@@ -0,0 +1,41 @@
+package oss_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"cube-go/pkg/oss"
+	"cube-go/pkg/oss/osstest"
+)
+
+func TestS3TrashFolder(t *testing.T) {
+	ctx := context.Background()
+	p := oss.NewS3StorageProvider(osstest.NewFakeS3(t), "trash", oss.S3StorageOptions{Trash: true})
+	for _, key := range []string{"album/a.txt", "album/sub/b.txt", "other.txt"} {
+		if err := p.SaveObject(ctx, strings.NewReader(key), key, oss.SaveObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.DeleteObject(ctx, "album/"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := listKeys(t, p, ""), []string{"other.txt"}; !slices.Equal(got, want) {
+		t.Fatalf("GetFileList after delete = %v, want %v", got, want)
+	}
+
+	trash := p.(oss.TrashProvider)
+	items, err := trash.ListTrash(ctx)
+	if err != nil || len(items) != 1 || items[0].Objects != 2 {
+		t.Fatalf("ListTrash = %+v, %v", items, err)
+	}
+	if _, err := trash.RestoreTrash(ctx, items[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"album/a.txt", "album/sub/b.txt"} {
+		if content, err := readObject(t, p, key); err != nil || content != key {
+			t.Errorf("GetObject(%q) after restore = %q, %v", key, content, err)
+		}
+	}
+}
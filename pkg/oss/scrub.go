@@ -0,0 +1,44 @@
+package oss
+
+import "context"
+
+// 巡检问题类型
+const (
+	ScrubChecksumMismatch = "checksum_mismatch" // 内容与记录的校验和不一致，可能是位衰减或在外部被修改，只能从历史版本或镜像副本修复
+	ScrubMissingChecksum  = "missing_checksum"  // 缺少校验和或内容类型
+	ScrubInvalidMetadata  = "invalid_metadata"  // 元数据无法解析
+	ScrubOrphanMetadata   = "orphan_metadata"   // 元数据对应的文件已不存在
+	ScrubStaleXattr       = "stale_xattr"       // 已改用数据库存储后残留的扩展属性
+	ScrubOrphanBlob       = "orphan_blob"       // 去重 blob 已没有对象引用
+	ScrubStaleTemp        = "stale_temp"        // 崩溃遗留的临时文件
+	ScrubUnreadable       = "unreadable"        // 文件无法读取
+	ScrubOrphanThumbnail  = "orphan_thumbnail"  // 缩略图已没有对应的源对象
+)
+
+// ScrubIssue 巡检发现的问题
+type ScrubIssue struct {
+	Kind      string `json:"kind"`
+	ObjectKey string `json:"object_key"`
+	Replica   string `json:"replica,omitempty"`  // 镜像存储桶中发现问题的副本
+	Expected  string `json:"expected,omitempty"` // 校验和不一致时元数据记录的校验和
+	Detail    string `json:"detail,omitempty"`
+	Fixed     bool   `json:"fixed"`
+}
+
+// ScrubResult 单个存储桶的巡检结果
+type ScrubResult struct {
+	Objects int64        `json:"objects"`
+	Bytes   int64        `json:"bytes"`
+	Issues  []ScrubIssue `json:"issues"`
+}
+
+func (r *ScrubResult) add(kind, key, detail string, fixed bool) {
+	r.Issues = append(r.Issues, ScrubIssue{Kind: kind, ObjectKey: key, Detail: detail, Fixed: fixed})
+}
+
+// ScrubProvider 由支持完整性巡检的存储提供者实现
+type ScrubProvider interface {
+	// Scrub 重新计算所有对象的校验和并检查元数据，fix 为 true 时修复可修复的问题。
+	// 校验和不一致的对象视为损坏，不会改写记录的校验和
+	Scrub(ctx context.Context, fix bool) (*ScrubResult, error)
+}
@@ -0,0 +1,103 @@
+package oss_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cube-go/pkg/oss"
+)
+
+// corruptFile 在存储之外以相同大小改写对象文件
+func corruptFile(t *testing.T, dir, key string) {
+	t.Helper()
+	name := filepath.Join(dir, filepath.FromSlash(key))
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[0] ^= 0xff
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mismatches(result *oss.ScrubResult) []oss.ScrubIssue {
+	var issues []oss.ScrubIssue
+	for _, issue := range result.Issues {
+		if issue.Kind == oss.ScrubChecksumMismatch {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+func TestScrubDoesNotRewriteChecksum(t *testing.T) {
+	ctx := context.Background()
+	p, dir := newLocalProviderDir(t, oss.LocalStorageOptions{Metadata: "bolt"})
+	if err := p.SaveObject(ctx, strings.NewReader("hello"), "a.txt", oss.SaveObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	corruptFile(t, dir, "a.txt")
+	for range 2 {
+		result, err := p.Scrub(ctx, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if issues := mismatches(result); len(issues) != 1 || issues[0].Fixed {
+			t.Fatalf("Scrub(fix) = %+v, want one unfixed mismatch on every run", result.Issues)
+		}
+	}
+}
+
+func TestScrubRepairsFromVersion(t *testing.T) {
+	ctx := context.Background()
+	p, dir := newLocalProviderDir(t, oss.LocalStorageOptions{Metadata: "bolt", Versioning: true})
+	// 第二次写入相同内容，历史版本中保留一份完好的副本
+	for range 2 {
+		if err := p.SaveObject(ctx, strings.NewReader("hello"), "a.txt", oss.SaveObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	corruptFile(t, dir, "a.txt")
+	result, err := p.Scrub(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := mismatches(result); len(issues) != 1 || !issues[0].Fixed {
+		t.Fatalf("Scrub(fix) = %+v, want the mismatch repaired", result.Issues)
+	}
+	if content, err := readObject(t, p, "a.txt"); err != nil || content != "hello" {
+		t.Errorf("content after repair = %q, %v", content, err)
+	}
+	if result, err := p.Scrub(ctx, false); err != nil || len(mismatches(result)) != 0 {
+		t.Errorf("Scrub after repair = %+v, %v", result, err)
+	}
+}
+
+func TestMirrorScrubRepairsFromReplica(t *testing.T) {
+	ctx := context.Background()
+	primary, dir := newLocalProviderDir(t, oss.LocalStorageOptions{Metadata: "bolt"})
+	secondary, _ := newLocalProviderDir(t, oss.LocalStorageOptions{Metadata: "bolt"})
+	mirror, err := oss.NewMirrorProvider([]string{"a", "b"}, []oss.StorageProvider{primary, secondary}, oss.MirrorOptions(oss.MirrorSync))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mirror.SaveObject(ctx, strings.NewReader("hello"), "a.txt", oss.SaveObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	corruptFile(t, dir, "a.txt")
+	result, err := mirror.Scrub(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues := mismatches(result)
+	if len(issues) != 1 || !issues[0].Fixed || issues[0].Replica != "a" {
+		t.Fatalf("Scrub(fix) = %+v, want the primary repaired from the secondary", result.Issues)
+	}
+	if content, err := readObject(t, primary, "a.txt"); err != nil || content != "hello" {
+		t.Errorf("primary content after repair = %q, %v", content, err)
+	}
+}
@@ -0,0 +1,195 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSearch 搜索条件不合法
+var ErrInvalidSearch = errors.New("invalid search query")
+
+// errSearchLimit 结果已满，用于提前结束遍历
+var errSearchLimit = errors.New("search limit reached")
+
+// searchCandidateLimit 未建立索引时每次搜索最多检查的候选对象数
+var searchCandidateLimit = 1000
+
+// SearchQuery 对象搜索条件，为零值的条件不参与过滤
+type SearchQuery struct {
+	Prefix         string    // 只搜索该目录下的对象
+	Name           string    // 含 * ? [ 时为通配模式，否则为对象键的子串，均不区分大小写；通配模式含 / 时匹配相对于 Prefix 的路径
+	Type           string    // MIME 类型，如 application/pdf 或 image/*；也可以是分类 text、image、json、binary
+	MinSize        *int64    // 单位字节
+	MaxSize        *int64    // 单位字节
+	ModifiedAfter  time.Time // 包含该时刻
+	ModifiedBefore time.Time // 不包含该时刻
+}
+
+// Validate 检查通配模式与范围
+func (q SearchQuery) Validate() error {
+	if q.glob() {
+		if _, err := path.Match(strings.ToLower(q.Name), ""); err != nil {
+			return ErrInvalidSearch
+		}
+	}
+	if q.MinSize != nil && q.MaxSize != nil && *q.MinSize > *q.MaxSize {
+		return ErrInvalidSearch
+	}
+	if !q.ModifiedAfter.IsZero() && !q.ModifiedBefore.IsZero() && !q.ModifiedAfter.Before(q.ModifiedBefore) {
+		return ErrInvalidSearch
+	}
+	return nil
+}
+
+func (q SearchQuery) glob() bool {
+	return strings.ContainsAny(q.Name, "*?[")
+}
+
+// matchName 匹配对象键。通配模式不含 / 时匹配文件名，否则与打包下载相同，匹配相对于 Prefix 的路径；
+// Prefix 须已规范化
+func (q SearchQuery) matchName(key string) bool {
+	if q.Name == "" {
+		return true
+	}
+	pattern, key := strings.ToLower(q.Name), strings.ToLower(key)
+	if !q.glob() {
+		return strings.Contains(key, pattern)
+	}
+	if !strings.Contains(pattern, "/") {
+		key = path.Base(key)
+	} else if q.Prefix != "" {
+		key = strings.TrimPrefix(key, strings.ToLower(q.Prefix)+"/")
+	}
+	matched, _ := path.Match(pattern, key)
+	return matched
+}
+
+// matchObject 匹配遍历时即可得到的对象键、大小与修改时间
+func (q SearchQuery) matchObject(key string, size int64, modified time.Time) bool {
+	if q.MinSize != nil && size < *q.MinSize {
+		return false
+	}
+	if q.MaxSize != nil && size > *q.MaxSize {
+		return false
+	}
+	if !q.ModifiedAfter.IsZero() && modified.Before(q.ModifiedAfter) {
+		return false
+	}
+	if !q.ModifiedBefore.IsZero() && !modified.Before(q.ModifiedBefore) {
+		return false
+	}
+	return q.matchName(key)
+}
+
+// matchType 匹配 MIME 类型或分类
+func (q SearchQuery) matchType(contentType string) bool {
+	if q.Type == "" {
+		return true
+	}
+	want := strings.ToLower(q.Type)
+	if !strings.Contains(want, "/") {
+		return classifyMIME(contentType) == want
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if major, found := strings.CutSuffix(want, "/*"); found {
+		return strings.HasPrefix(mediaType, major+"/")
+	}
+	return mediaType == want
+}
+
+func (q SearchQuery) matchEntry(entry IndexEntry) bool {
+	return q.matchObject(entry.Key, entry.Size, entry.LastModified) && q.matchType(entry.ContentType)
+}
+
+// SearchObjects 按对象键顺序返回存储桶中键大于 after 的至多 limit 个匹配对象。
+// 对象索引已建立时查询索引；否则遍历后端，只为键、大小与时间均匹配的对象读取对象信息。
+// 遍历后端时至多检查对象键最小的 searchCandidateLimit 个候选，候选未检查完时返回最后检查的对象键 resume，
+// 此时结果可能不足 limit 个，应从 resume 之后继续搜索。
+func SearchObjects(ctx context.Context, p StorageProvider, bucket string, query SearchQuery, after string, limit int) (results []IndexEntry, resume string, err error) {
+	prefix, _, err := NormalizeObjectKey(query.Prefix, true)
+	if err != nil {
+		return nil, "", err
+	}
+	query.Prefix = prefix
+	results = make([]IndexEntry, 0)
+	if limit <= 0 {
+		return results, "", nil
+	}
+	if indexed, ok := As[*IndexedProvider](p); ok && indexed.ready.Load() {
+		err := indexed.index.scan(ctx, indexed.bucket, prefix, after, func(entry IndexEntry) error {
+			if !query.matchEntry(entry) {
+				return nil
+			}
+			entry.Bucket = bucket
+			results = append(results, entry)
+			if len(results) >= limit {
+				return errSearchLimit
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errSearchLimit) {
+			return nil, "", err
+		}
+		return results, "", nil
+	}
+
+	// 后端遍历不保证对象键顺序，只保留对象键最小的候选，超出时排序截断
+	var candidates []string
+	var truncated bool
+	var bound string // 截断后不再保留大于该对象键的候选
+	err = WalkObjects(ctx, p, prefix, func(entry ObjectEntry) error {
+		if entry.Key <= after || truncated && entry.Key > bound {
+			return nil
+		}
+		candidate := query
+		if entry.LastModified.IsZero() {
+			// 修改时间未知，读取对象信息后再判断
+			candidate.ModifiedAfter, candidate.ModifiedBefore = time.Time{}, time.Time{}
+		}
+		if !candidate.matchObject(entry.Key, entry.Size, entry.LastModified) {
+			return nil
+		}
+		candidates = append(candidates, entry.Key)
+		if len(candidates) >= 2*searchCandidateLimit {
+			slices.Sort(candidates)
+			candidates, truncated = candidates[:searchCandidateLimit], true
+			bound = candidates[len(candidates)-1]
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrResourceNotExists) {
+		return nil, "", err
+	}
+	slices.Sort(candidates)
+	if len(candidates) > searchCandidateLimit {
+		candidates, truncated = candidates[:searchCandidateLimit], true
+	}
+	for _, key := range candidates {
+		entry, err := statIndexEntry(ctx, p, bucket, key)
+		if errors.Is(err, ErrResourceNotExists) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if !query.matchEntry(*entry) {
+			continue
+		}
+		results = append(results, *entry)
+		if len(results) >= limit {
+			return results, "", nil
+		}
+	}
+	if truncated {
+		resume = candidates[len(candidates)-1]
+	}
+	return results, resume, nil
+}
@@ -0,0 +1,153 @@
+package oss_test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"cube-go/pkg/oss"
+)
+
+func TestSearchObjects(t *testing.T) {
+	ctx := context.Background()
+	seed := func(t *testing.T, p oss.StorageProvider) {
+		objects := map[string]string{
+			"docs/report.PDF":       "%PDF-1.4\n" + strings.Repeat("x", 100),
+			"docs/notes.txt":        "notes",
+			"docs/2024/summary.txt": "summary of the year",
+			"data/config.json":      `{"a":1}`,
+			"readme.txt":            "read me",
+		}
+		for key, content := range objects {
+			if err := p.SaveObject(ctx, strings.NewReader(content), key, oss.SaveObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	size := func(n int64) *int64 { return &n }
+	cases := []struct {
+		name  string
+		query oss.SearchQuery
+		want  []string
+	}{
+		{"all", oss.SearchQuery{}, []string{"data/config.json", "docs/2024/summary.txt", "docs/notes.txt", "docs/report.PDF", "readme.txt"}},
+		{"substring", oss.SearchQuery{Name: "NOTE"}, []string{"docs/notes.txt"}},
+		{"glob on name", oss.SearchQuery{Name: "*.txt"}, []string{"docs/2024/summary.txt", "docs/notes.txt", "readme.txt"}},
+		{"glob on key", oss.SearchQuery{Name: "docs/*.txt"}, []string{"docs/notes.txt"}},
+		{"prefix", oss.SearchQuery{Prefix: "docs/", Name: "*.txt"}, []string{"docs/2024/summary.txt", "docs/notes.txt"}},
+		{"glob relative to prefix", oss.SearchQuery{Prefix: "docs", Name: "2024/*.txt"}, []string{"docs/2024/summary.txt"}},
+		{"category", oss.SearchQuery{Type: "json"}, []string{"data/config.json"}},
+		{"mime type", oss.SearchQuery{Type: "application/pdf"}, []string{"docs/report.PDF"}},
+		{"mime wildcard", oss.SearchQuery{Type: "text/*", Prefix: "docs"}, []string{"docs/2024/summary.txt", "docs/notes.txt"}},
+		{"size range", oss.SearchQuery{MinSize: size(6), MaxSize: size(19)}, []string{"data/config.json", "docs/2024/summary.txt", "readme.txt"}},
+		{"modified after", oss.SearchQuery{ModifiedAfter: time.Now().Add(time.Hour)}, []string{}},
+		{"modified range", oss.SearchQuery{ModifiedAfter: time.Now().Add(-time.Hour), ModifiedBefore: time.Now().Add(time.Hour), Name: "readme"}, []string{"readme.txt"}},
+	}
+	providers := map[string]func(t *testing.T) oss.StorageProvider{
+		"backend": func(t *testing.T) oss.StorageProvider {
+			return oss.NewMemoryStorageProvider(oss.MemoryStorageOptions{})
+		},
+		"index": func(t *testing.T) oss.StorageProvider {
+			index := openIndex(t, filepath.Join(t.TempDir(), "objects.db"))
+			return newIndexedProvider(t, oss.NewMemoryStorageProvider(oss.MemoryStorageOptions{}), index)
+		},
+	}
+	for name, newProvider := range providers {
+		t.Run(name, func(t *testing.T) {
+			p := newProvider(t)
+			seed(t, p)
+			for _, c := range cases {
+				if err := c.query.Validate(); err != nil {
+					t.Fatalf("%s: Validate: %v", c.name, err)
+				}
+				results, _, err := oss.SearchObjects(ctx, p, "test", c.query, "", 100)
+				if err != nil {
+					t.Fatalf("%s: %v", c.name, err)
+				}
+				keys := make([]string, 0, len(results))
+				for _, result := range results {
+					keys = append(keys, result.Key)
+					if result.Bucket != "test" || result.ContentType == "" {
+						t.Errorf("%s: result = %+v", c.name, result)
+					}
+				}
+				if !slices.Equal(keys, c.want) {
+					t.Errorf("%s: keys = %v, want %v", c.name, keys, c.want)
+				}
+			}
+
+			// 分页时从上一页最后一个对象键之后继续
+			var pages [][]string
+			after := ""
+			for {
+				results, _, err := oss.SearchObjects(ctx, p, "test", oss.SearchQuery{Name: "*.txt"}, after, 2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(results) == 0 {
+					break
+				}
+				var page []string
+				for _, result := range results {
+					page = append(page, result.Key)
+				}
+				pages = append(pages, page)
+				after = results[len(results)-1].Key
+			}
+			if len(pages) != 2 || !slices.Equal(pages[0], []string{"docs/2024/summary.txt", "docs/notes.txt"}) || !slices.Equal(pages[1], []string{"readme.txt"}) {
+				t.Errorf("pages = %v", pages)
+			}
+		})
+	}
+
+	for _, query := range []oss.SearchQuery{
+		{Name: "[a-"},
+		{MinSize: size(10), MaxSize: size(1)},
+		{ModifiedAfter: time.Now(), ModifiedBefore: time.Now().Add(-time.Hour)},
+	} {
+		if err := query.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want ErrInvalidSearch", query)
+		}
+	}
+}
+
+// 未建立索引时候选过多只检查对象键最小的一部分，返回的 resume 用于继续搜索
+func TestSearchObjectsCandidateLimit(t *testing.T) {
+	defer oss.SetSearchCandidateLimit(2)()
+	ctx := context.Background()
+	p := oss.NewMemoryStorageProvider(oss.MemoryStorageOptions{})
+	for _, key := range []string{"e.txt", "b.txt", "a.bin", "d.bin", "c.txt", "f.bin", "g.txt"} {
+		content := key
+		if strings.HasSuffix(key, ".bin") {
+			content = "\x00\x01\x02"
+		}
+		// 类型只能在读取对象信息后判断，.bin 对象会成为候选但不在结果中
+		if err := p.SaveObject(ctx, strings.NewReader(content), key, oss.SaveObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var keys []string
+	after := ""
+	for range 10 {
+		results, resume, err := oss.SearchObjects(ctx, p, "test", oss.SearchQuery{Type: "text"}, after, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, result := range results {
+			keys = append(keys, result.Key)
+		}
+		if resume == "" {
+			break
+		}
+		if resume <= after {
+			t.Fatalf("resume = %q after %q", resume, after)
+		}
+		after = resume
+	}
+	if want := []string{"b.txt", "c.txt", "e.txt", "g.txt"}; !slices.Equal(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+}
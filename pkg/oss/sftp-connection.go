@@ -0,0 +1,225 @@
+package oss
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var (
+	// ErrPoolClosed 连接池已关闭
+	ErrPoolClosed = errors.New("sftp pool closed")
+	// ErrKnownHostsRequired 未配置 knownHosts，且未显式允许不校验主机密钥
+	ErrKnownHostsRequired = errors.New("sftp knownHosts is required unless insecureIgnoreHostKey is set")
+)
+
+// SFTPPool SFTP 连接池。SFTP 客户端支持并发请求，池中的连接轮流使用而非独占借出；
+// 连接在首次使用时建立，断开后在下次使用时重新建立。
+type SFTPPool struct {
+	address string
+	config  *ssh.ClientConfig
+	slots   []sftpSlot
+	mu      sync.Mutex
+	next    int
+	closed  bool
+}
+
+type sftpSlot struct {
+	mu   sync.Mutex
+	conn *sftpConn
+}
+
+type sftpConn struct {
+	client *sftp.Client
+	done   chan struct{} // 连接断开后关闭
+}
+
+func (c *sftpConn) alive() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// NewSFTPPool 创建 SFTP 连接池，size 为连接数
+func NewSFTPPool(address string, config *ssh.ClientConfig, size int) *SFTPPool {
+	return &SFTPPool{address: address, config: config, slots: make([]sftpSlot, max(size, 1))}
+}
+
+// sftpDefaultTimeout 未配置时连接与握手的超时
+const sftpDefaultTimeout = 10 * time.Second
+
+func newSFTPConnection(c *sftpConfigElement) (*SFTPPool, error) {
+	config := &ssh.ClientConfig{
+		User:    c.User,
+		Timeout: sftpDefaultTimeout,
+	}
+	if c.Timeout > 0 {
+		config.Timeout = time.Duration(c.Timeout) * time.Second
+	}
+	if c.Password != "" {
+		config.Auth = append(config.Auth, ssh.Password(c.Password))
+	}
+	if c.PrivateKey != "" {
+		pem, err := os.ReadFile(c.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		var signer ssh.Signer
+		if c.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(c.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(pem)
+		}
+		if err != nil {
+			return nil, err
+		}
+		config.Auth = append(config.Auth, ssh.PublicKeys(signer))
+	}
+	switch {
+	case c.KnownHosts != "":
+		callback, err := knownhosts.New(c.KnownHosts)
+		if err != nil {
+			return nil, err
+		}
+		config.HostKeyCallback = callback
+	case c.InsecureIgnoreHostKey:
+		zap.L().Warn("SFTP 连接不校验主机密钥，可能遭受中间人攻击", zap.String("name", c.Name))
+		config.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, fmt.Errorf("%s: %w", c.Name, ErrKnownHostsRequired)
+	}
+	return NewSFTPPool(c.Address, config, cmp.Or(c.PoolSize, 2)), nil
+}
+
+// client 取得一个可用连接，连接断开或尚未建立时重新连接
+func (p *SFTPPool) client(ctx context.Context) (*sftpConn, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	slot := &p.slots[p.next]
+	p.next = (p.next + 1) % len(p.slots)
+	p.mu.Unlock()
+
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	if slot.conn != nil && slot.conn.alive() {
+		return slot.conn, nil
+	}
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		_ = conn.client.Close()
+		return nil, ErrPoolClosed
+	}
+	slot.conn = conn
+	return conn, nil
+}
+
+func (p *SFTPPool) dial(ctx context.Context) (*sftpConn, error) {
+	timeout := cmp.Or(p.config.Timeout, sftpDefaultTimeout)
+	dialer := net.Dialer{Timeout: timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", p.address)
+	if err != nil {
+		return nil, err
+	}
+	// ssh.ClientConfig.Timeout 只作用于 ssh.Dial，握手与 SFTP 初始化需要自行设置期限，
+	// 否则服务器在握手中途停止响应时会一直占用该连接位置；请求取消时同样中止握手
+	if err := netConn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { _ = netConn.Close() })
+	sshConn, channels, requests, err := ssh.NewClientConn(netConn, p.address, p.config)
+	if err != nil {
+		stop()
+		_ = netConn.Close()
+		return nil, cmp.Or(ctx.Err(), err)
+	}
+	sshClient := ssh.NewClient(sshConn, channels, requests)
+	client, err := sftp.NewClient(sshClient)
+	if !stop() {
+		// 请求已取消，连接已被关闭
+		if err == nil {
+			_ = client.Close()
+		}
+		_ = sshClient.Close()
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		_ = sshClient.Close()
+		return nil, err
+	}
+	if err := netConn.SetDeadline(time.Time{}); err != nil {
+		_ = client.Close()
+		_ = sshClient.Close()
+		return nil, err
+	}
+	conn := &sftpConn{client: client, done: make(chan struct{})}
+	go func() {
+		_ = client.Wait()
+		_ = sshClient.Close()
+		close(conn.done)
+	}()
+	return conn, nil
+}
+
+// do 在连接上执行操作，连接在操作期间断开时重新连接并重试一次。
+// 操作需可安全重复执行。
+func (p *SFTPPool) do(ctx context.Context, fn func(*sftp.Client) error) error {
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		conn, err := p.client(ctx)
+		if err != nil {
+			return err
+		}
+		err = fn(conn.client)
+		if err == nil || attempt > 0 || !errors.Is(err, sftp.ErrSSHFxConnectionLost) {
+			return err
+		}
+		zap.L().Warn("SFTP 连接断开，重新连接", zap.String("address", p.address), zap.Error(err))
+		_ = conn.client.Close()
+		<-conn.done
+	}
+}
+
+// Close 关闭连接池中的所有连接
+func (p *SFTPPool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	var errs []error
+	for i := range p.slots {
+		slot := &p.slots[i]
+		slot.mu.Lock()
+		if slot.conn != nil {
+			if err := slot.conn.client.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+				errs = append(errs, err)
+			}
+			slot.conn = nil
+		}
+		slot.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
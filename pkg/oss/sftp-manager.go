@@ -0,0 +1,41 @@
+package oss
+
+import (
+	"cube-go/pkg/config"
+)
+
+type sftpConfigElement struct {
+	Name       string `mapstructure:"name"`
+	Address    string `mapstructure:"address"` // 主机:端口
+	User       string `mapstructure:"user"`
+	Password   string `mapstructure:"password"`
+	PrivateKey string `mapstructure:"privateKey"` // 私钥文件路径
+	Passphrase string `mapstructure:"passphrase"` // 私钥口令
+	KnownHosts string `mapstructure:"knownHosts"` // known_hosts 文件路径，必须配置，除非显式设置 InsecureIgnoreHostKey
+	PoolSize   int    `mapstructure:"poolSize"`   // 连接数，默认 2
+	Timeout    int    `mapstructure:"timeout"`    // 连接与握手超时 单位秒，默认 10
+
+	InsecureIgnoreHostKey bool `mapstructure:"insecureIgnoreHostKey"` // 不校验主机密钥，仅用于测试环境
+}
+
+// initSFTPConnections 初始化SFTP连接池，连接在首次使用时建立
+func initSFTPConnections() (map[string]*SFTPPool, error) {
+	var cfgList []sftpConfigElement
+	err := config.Config.UnmarshalKey("sftp", &cfgList)
+	if err != nil {
+		return nil, err
+	}
+
+	connections := make(map[string]*SFTPPool, len(cfgList))
+	for _, c := range cfgList {
+		if _, exists := connections[c.Name]; exists {
+			return nil, ErrConnectionAlreadyExists
+		}
+		pool, err := newSFTPConnection(&c)
+		if err != nil {
+			return nil, err
+		}
+		connections[c.Name] = pool
+	}
+	return connections, nil
+}
@@ -0,0 +1,712 @@
+package oss
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"go.uber.org/zap"
+)
+
+// sftpMetaDir SFTP 存储中对象元数据文件所在目录
+const sftpMetaDir = internalDir + "/meta"
+
+// maxSymlinks 解析对象键时最多跟随的符号链接数
+const maxSymlinks = 40
+
+// SFTPStorageProvider SFTP 存储提供者，对象保存在服务器上 root 目录下，布局与本地存储一致。
+// 对象元数据以 JSON 文件保存在 root/.cube/meta 下，并记录写入时的大小与修改时间，
+// 文件在服务器上被直接修改后其内容类型与摘要失效。
+type SFTPStorageProvider struct {
+	pool  *SFTPPool
+	root  string
+	locks keyLocks
+
+	mu      sync.Mutex
+	absRoot string // root 的绝对路径，解析绝对路径的符号链接时按需获取
+}
+
+// sftpMetadata 对象元数据文件内容
+type sftpMetadata struct {
+	ObjectMetadata // SFTP 协议中修改时间精确到秒，ModTime 记录 Unix 秒
+}
+
+// NewSFTPStorageProvider 创建 SFTP 存储提供者，root 为服务器上的根目录，相对路径相对于登录目录
+func NewSFTPStorageProvider(pool *SFTPPool, root string) *SFTPStorageProvider {
+	return &SFTPStorageProvider{pool: pool, root: path.Clean(root)}
+}
+
+func (p *SFTPStorageProvider) Seekable() bool {
+	return true
+}
+
+// SaveObject 保存对象，先写入内部临时文件再移动到目标位置
+func (p *SFTPStorageProvider) SaveObject(ctx context.Context, reader io.ReadSeeker, objectKey string, options SaveObjectOptions) error {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return ErrInvalidObjectKey
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock := p.locks.lock(key)
+	defer unlock()
+	return p.pool.do(ctx, func(client *sftp.Client) error {
+		temp, meta, err := p.writeTemp(ctx, client, reader)
+		if err != nil {
+			return err
+		}
+		err = p.publish(client, key, temp, meta, options)
+		if err != nil {
+			_ = client.Remove(temp)
+		}
+		return err
+	})
+}
+
+// writeTemp 将内容写入内部临时文件，返回临时文件路径及探测到的内容类型与校验和
+func (p *SFTPStorageProvider) writeTemp(ctx context.Context, client *sftp.Client, reader io.ReadSeeker) (string, *ObjectMetadata, error) {
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return "", nil, err
+	}
+	dir := path.Join(p.root, tempDir)
+	if err := client.MkdirAll(dir); err != nil {
+		return "", nil, err
+	}
+	name := path.Join(dir, newInternalID(time.Now()))
+	file, err := client.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return "", nil, err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(file, io.TeeReader(reader, hash))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = client.Remove(name)
+		return "", nil, err
+	}
+	return name, &ObjectMetadata{
+		ContentType: detectMimeType(reader),
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// publish 校验写入条件后将临时文件移动到对象位置并写入元数据，需持有对象键的锁
+func (p *SFTPStorageProvider) publish(client *sftp.Client, key, temp string, meta *ObjectMetadata, options SaveObjectOptions) error {
+	if options.SHA256 != "" && !strings.EqualFold(options.SHA256, meta.SHA256) {
+		return ErrChecksumMismatch
+	}
+	target, err := p.resolve(client, key)
+	if err != nil {
+		return err
+	}
+	if err := p.mkdirAll(client, path.Dir(target)); err != nil {
+		return err
+	}
+	stat, err := client.Lstat(target)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if exists && stat.IsDir() {
+		return ErrFileAlreadyExists
+	}
+	switch {
+	case !exists && options.IfMatch != "":
+		return ErrPreconditionFailed
+	case exists && !options.Overwrite && options.IfMatch == "":
+		return ErrFileAlreadyExists
+	case exists && options.IfMatch != "" && !etagMatches(options.IfMatch, localETag(p.metadata(client, key, stat).SHA256, stat)):
+		return ErrPreconditionFailed
+	}
+
+	if !exists {
+		err = client.Rename(temp, target)
+	} else if _, ok := client.HasExtension("posix-rename@openssh.com"); ok {
+		err = client.PosixRename(temp, target)
+	} else if err = client.Remove(target); err == nil {
+		err = client.Rename(temp, target)
+	}
+	if err != nil {
+		return err
+	}
+
+	meta.UploadedAt = time.Now()
+	if !options.UploadedAt.IsZero() {
+		meta.UploadedAt = options.UploadedAt
+		if err := client.Chtimes(target, options.UploadedAt, options.UploadedAt); err != nil {
+			zap.L().Warn("保留对象修改时间失败", zap.String("key", key), zap.Error(err))
+		}
+	}
+	meta.OriginalName = options.OriginalName
+	meta.Uploader = options.Uploader
+	meta.Custom = options.Metadata
+	meta.Tags = options.Tags
+	if stat, err = client.Stat(target); err == nil {
+		err = p.putMetadata(client, key, &sftpMetadata{ObjectMetadata: *meta}, stat)
+	}
+	if err != nil {
+		// 对象已写入，元数据缺失时按未知内容处理
+		zap.L().Warn("写入对象元数据失败", zap.String("key", key), zap.Error(err))
+	}
+	return nil
+}
+
+// mkdirAll 逐级创建目录，路径中存在文件时返回 ErrFileAlreadyExists。dir 须为 resolve 的结果
+func (p *SFTPStorageProvider) mkdirAll(client *sftp.Client, dir string) error {
+	rel := strings.TrimPrefix(strings.TrimPrefix(dir, p.root), "/")
+	if rel == "" {
+		return client.MkdirAll(p.root)
+	}
+	current := p.root
+	for _, name := range strings.Split(rel, "/") {
+		current = path.Join(current, name)
+		stat, err := client.Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			if err = client.Mkdir(current); err == nil {
+				continue
+			}
+			// 并发创建时目录可能已由其他请求建立
+			stat, err = client.Lstat(current)
+		}
+		if err != nil {
+			return err
+		}
+		if !stat.IsDir() {
+			return ErrFileAlreadyExists
+		}
+	}
+	return nil
+}
+
+// DeleteObject 删除对象或整个目录，目标不存在时仍视为成功。不跟随最后一级的符号链接。
+func (p *SFTPStorageProvider) DeleteObject(ctx context.Context, objectKey string) error {
+	key, _, err := NormalizeObjectKey(objectKey, false)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock := p.locks.lock(key)
+	defer unlock()
+	return p.pool.do(ctx, func(client *sftp.Client) error {
+		parent, err := p.resolve(client, path.Dir(key))
+		if err != nil {
+			return err
+		}
+		if err := sftpRemoveAll(client, path.Join(parent, path.Base(key))); err != nil {
+			return err
+		}
+		metaPath := path.Join(p.root, sftpMetaDir, key)
+		if err := sftpRemoveAll(client, metaPath+".json"); err != nil {
+			return err
+		}
+		if err := sftpRemoveAll(client, metaPath); err != nil {
+			return err
+		}
+		p.pruneEmptyParents(client, p.root, key)
+		p.pruneEmptyParents(client, path.Join(p.root, sftpMetaDir), key)
+		return nil
+	})
+}
+
+// pruneEmptyParents 自下而上删除对象所在的空目录
+func (p *SFTPStorageProvider) pruneEmptyParents(client *sftp.Client, base, key string) {
+	for dir := path.Dir(key); dir != "."; dir = path.Dir(dir) {
+		if err := client.RemoveDirectory(path.Join(base, dir)); err != nil {
+			break
+		}
+	}
+}
+
+// sftpRemoveAll 递归删除文件或目录，不跟随符号链接，目标不存在时视为成功
+func sftpRemoveAll(client *sftp.Client, name string) error {
+	stat, err := client.Lstat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !stat.IsDir() {
+		return ignoreNotExist(client.Remove(name))
+	}
+	entries, err := client.ReadDir(name)
+	if err != nil {
+		return ignoreNotExist(err)
+	}
+	for _, entry := range entries {
+		if err := sftpRemoveAll(client, path.Join(name, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return ignoreNotExist(client.RemoveDirectory(name))
+}
+
+func ignoreNotExist(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// GetObject 获取对象，返回的文件可随机读取
+func (p *SFTPStorageProvider) GetObject(ctx context.Context, objectKey string, options GetObjectOptions) (io.ReadCloser, *GetObjectInfo, error) {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return nil, nil, ErrInvalidObjectKey
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if options.VersionID != "" {
+		return nil, nil, ErrResourceNotExists
+	}
+	var file *sftp.File
+	var info *GetObjectInfo
+	err = p.pool.do(ctx, func(client *sftp.Client) error {
+		target, err := p.resolve(client, key)
+		if err != nil {
+			return err
+		}
+		if file, err = client.Open(target); err != nil {
+			return err
+		}
+		stat, err := file.Stat()
+		if err == nil && stat.IsDir() {
+			err = ErrResourceNotExists
+		}
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+		meta := p.metadata(client, key, stat)
+		if meta.ContentType == "" {
+			meta.ContentType = detectMimeType(file)
+		}
+		info = &GetObjectInfo{
+			ContentType:   meta.ContentType,
+			Metadata:      meta.Custom,
+			ContentLength: stat.Size(),
+			AcceptRanges:  "bytes",
+			ETag:          localETag(meta.SHA256, stat),
+			SHA256:        meta.SHA256,
+			LastModified:  stat.ModTime(),
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, ErrResourceNotExists
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, info, nil
+}
+
+func (p *SFTPStorageProvider) StatObject(ctx context.Context, objectKey string, options GetObjectOptions) (*GetObjectInfo, error) {
+	reader, info, err := p.GetObject(ctx, objectKey, GetObjectOptions{VersionID: options.VersionID})
+	if err != nil {
+		return nil, err
+	}
+	_ = reader.Close()
+	return info, nil
+}
+
+// GetFileList 获取文件列表，缺少元数据的文件按扩展名推断类型
+func (p *SFTPStorageProvider) GetFileList(ctx context.Context, prefix string) ([]FileListElement, error) {
+	key, _, err := NormalizeObjectKey(prefix, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var list []FileListElement
+	err = p.pool.do(ctx, func(client *sftp.Client) error {
+		target, err := p.resolve(client, key)
+		if err != nil {
+			return err
+		}
+		stat, err := client.Stat(target)
+		if errors.Is(err, fs.ErrNotExist) {
+			list = []FileListElement{}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !stat.IsDir() {
+			return ErrPathIsNotDir
+		}
+		entries, err := client.ReadDir(target)
+		if err != nil {
+			return err
+		}
+		list = make([]FileListElement, 0, len(entries))
+		for _, entry := range entries {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if key == "" && entry.Name() == internalDir {
+				continue
+			}
+			objectKey := path.Join(key, entry.Name())
+			info := entry
+			if entry.Mode()&fs.ModeSymlink != 0 {
+				if info, err = p.followLink(client, objectKey); err != nil {
+					return err
+				}
+				if info == nil {
+					continue
+				}
+			}
+			if info.IsDir() {
+				objectKey += "/"
+			}
+			element := FileListElement{
+				Name:         entry.Name(),
+				Size:         info.Size(),
+				Type:         "dir",
+				LastModified: info.ModTime().Format(time.RFC3339),
+				ObjectKey:    objectKey,
+			}
+			if !info.IsDir() {
+				meta := p.metadata(client, objectKey, info)
+				contentType := meta.ContentType
+				if contentType == "" {
+					contentType = mime.TypeByExtension(path.Ext(entry.Name()))
+				}
+				element.Type = classifyMIME(contentType)
+				element.Metadata = meta.Custom
+				element.Tags = meta.Tags
+			}
+			list = append(list, element)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// walkObjects 递归遍历前缀下的所有对象，不跟随符号链接目录
+func (p *SFTPStorageProvider) walkObjects(ctx context.Context, prefix string, fn func(ObjectEntry) error) error {
+	key, _, err := NormalizeObjectKey(prefix, true)
+	if err != nil {
+		return err
+	}
+	var entries []ObjectEntry
+	err = p.pool.do(ctx, func(client *sftp.Client) error {
+		entries = entries[:0]
+		target, err := p.resolve(client, key)
+		if err != nil {
+			return err
+		}
+		stat, err := client.Stat(target)
+		if err != nil {
+			return err
+		}
+		if !stat.IsDir() {
+			entries = append(entries, ObjectEntry{Key: key, Size: stat.Size(), LastModified: stat.ModTime()})
+			return nil
+		}
+		return p.walkDir(ctx, client, target, key, &entries)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *SFTPStorageProvider) walkDir(ctx context.Context, client *sftp.Client, dir, key string, entries *[]ObjectEntry) error {
+	children, err := client.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := path.Join(key, child.Name())
+		if child.Mode()&fs.ModeSymlink != 0 {
+			// 不进入指向目录的符号链接，避免循环与重复遍历
+			info, err := p.followLink(client, name)
+			if err != nil {
+				return err
+			}
+			if info != nil && !info.IsDir() {
+				*entries = append(*entries, ObjectEntry{Key: name, Size: info.Size(), LastModified: info.ModTime()})
+			}
+			continue
+		}
+		if !child.IsDir() {
+			*entries = append(*entries, ObjectEntry{Key: name, Size: child.Size(), LastModified: child.ModTime()})
+			continue
+		}
+		if name == internalDir {
+			continue
+		}
+		if err := p.walkDir(ctx, client, path.Join(dir, child.Name()), name, entries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// followLink 返回符号链接指向的文件信息，链接失效或指向根目录之外时返回 nil，此时对象不可访问
+func (p *SFTPStorageProvider) followLink(client *sftp.Client, key string) (fs.FileInfo, error) {
+	target, err := p.resolve(client, key)
+	if errors.Is(err, ErrInvalidObjectKey) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := client.Stat(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return info, err
+}
+
+// GetMetadata 获取对象的自定义元数据与标签
+func (p *SFTPStorageProvider) GetMetadata(ctx context.Context, objectKey string) (*UserMetadata, error) {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return nil, ErrInvalidObjectKey
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var result *UserMetadata
+	err = p.pool.do(ctx, func(client *sftp.Client) error {
+		_, meta, err := p.stat(client, key)
+		if err != nil {
+			return err
+		}
+		result = &UserMetadata{Metadata: meta.Custom, Tags: meta.Tags}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (p *SFTPStorageProvider) storedMetadata(ctx context.Context, objectKey string) (*ObjectMetadata, error) {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return nil, ErrInvalidObjectKey
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var result *ObjectMetadata
+	err = p.pool.do(ctx, func(client *sftp.Client) error {
+		_, meta, err := p.stat(client, key)
+		if err != nil {
+			return err
+		}
+		result = &meta.ObjectMetadata
+		return nil
+	})
+	return result, err
+}
+
+// UpdateMetadata 更新对象的自定义元数据与标签，内容与修改时间不变
+func (p *SFTPStorageProvider) UpdateMetadata(ctx context.Context, objectKey string, update UserMetadata) error {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return ErrInvalidObjectKey
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock := p.locks.lock(key)
+	defer unlock()
+	return p.pool.do(ctx, func(client *sftp.Client) error {
+		stat, meta, err := p.stat(client, key)
+		if err != nil {
+			return err
+		}
+		if update.Metadata != nil {
+			meta.Custom = update.Metadata
+		}
+		if update.Tags != nil {
+			meta.Tags = update.Tags
+		}
+		return p.putMetadata(client, key, meta, stat)
+	})
+}
+
+// stat 获取对象文件信息与元数据，对象不存在或为目录时返回 ErrResourceNotExists
+func (p *SFTPStorageProvider) stat(client *sftp.Client, key string) (fs.FileInfo, *sftpMetadata, error) {
+	target, err := p.resolve(client, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	stat, err := client.Stat(target)
+	if errors.Is(err, fs.ErrNotExist) || err == nil && stat.IsDir() {
+		return nil, nil, ErrResourceNotExists
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return stat, p.metadata(client, key, stat), nil
+}
+
+// metadata 读取对象元数据，文件大小或修改时间与记录不符时丢弃内容类型与摘要，读取失败时返回空元数据
+func (p *SFTPStorageProvider) metadata(client *sftp.Client, key string, stat fs.FileInfo) *sftpMetadata {
+	meta := &sftpMetadata{}
+	file, err := client.Open(path.Join(p.root, sftpMetaDir, key) + ".json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return meta
+	}
+	if err == nil {
+		err = json.NewDecoder(file).Decode(meta)
+		_ = file.Close()
+	}
+	if err != nil {
+		zap.L().Warn("读取对象元数据失败", zap.String("key", key), zap.Error(err))
+		return &sftpMetadata{}
+	}
+	if meta.Size != stat.Size() || meta.ModTime != stat.ModTime().Unix() {
+		meta.ContentType = ""
+		meta.SHA256 = ""
+	}
+	return meta
+}
+
+// putMetadata 写入对象元数据，记录对象当前的大小与修改时间
+func (p *SFTPStorageProvider) putMetadata(client *sftp.Client, key string, meta *sftpMetadata, stat fs.FileInfo) error {
+	meta.Size = stat.Size()
+	meta.ModTime = stat.ModTime().Unix()
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	name := path.Join(p.root, sftpMetaDir, key) + ".json"
+	if err := client.MkdirAll(path.Dir(name)); err != nil {
+		return err
+	}
+	file, err := client.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// resolve 将对象键解析为服务器上的路径。逐级检查已存在的路径分量并跟随符号链接，
+// 链接指向根目录之外时返回 ErrInvalidObjectKey。
+// SFTP 没有相对于目录句柄打开文件的操作，检查与随后的打开之间路径可能被替换为符号链接，
+// 因此只能防止已存在的链接，不等同于本地存储使用的 os.Root；根目录不应允许不可信的用户在服务器上直接写入。
+func (p *SFTPStorageProvider) resolve(client *sftp.Client, key string) (string, error) {
+	var resolved []string
+	pending := strings.Split(key, "/")
+	missing := false
+	for links := 0; len(pending) > 0; {
+		name := pending[0]
+		pending = pending[1:]
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return "", ErrInvalidObjectKey
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+		current := path.Join(p.root, path.Join(resolved...), name)
+		if missing {
+			resolved = append(resolved, name)
+			continue
+		}
+		stat, err := client.Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			// 不存在的路径下不会再有符号链接
+			missing = true
+			resolved = append(resolved, name)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if stat.Mode()&fs.ModeSymlink == 0 {
+			resolved = append(resolved, name)
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return "", ErrInvalidObjectKey
+		}
+		target, err := client.ReadLink(current)
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) {
+			root, err := p.rootPath(client)
+			if err != nil {
+				return "", err
+			}
+			root = strings.TrimSuffix(root, "/")
+			rel, ok := strings.CutPrefix(path.Clean(target), root)
+			if !ok || rel != "" && !strings.HasPrefix(rel, "/") {
+				return "", ErrInvalidObjectKey
+			}
+			resolved, target = resolved[:0], rel
+		}
+		pending = append(strings.Split(target, "/"), pending...)
+	}
+	return path.Join(p.root, path.Join(resolved...)), nil
+}
+
+// rootPath 获取根目录的绝对路径
+func (p *SFTPStorageProvider) rootPath(client *sftp.Client) (string, error) {
+	if path.IsAbs(p.root) {
+		return p.root, nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.absRoot == "" {
+		root, err := client.RealPath(p.root)
+		if err != nil {
+			return "", err
+		}
+		p.absRoot = path.Clean(root)
+	}
+	return p.absRoot, nil
+}
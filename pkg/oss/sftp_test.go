@@ -0,0 +1,229 @@
+package oss_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cube-go/pkg/oss"
+	"cube-go/pkg/oss/osstest"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newSFTPProvider(t *testing.T) (*oss.SFTPStorageProvider, *osstest.SFTPServer, string) {
+	server := osstest.NewSFTPServer(t)
+	pool := oss.NewSFTPPool(server.Addr, server.Config, 2)
+	t.Cleanup(func() { _ = pool.Close() })
+	root := filepath.Join(t.TempDir(), "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return oss.NewSFTPStorageProvider(pool, root), server, root
+}
+
+func readObject(t *testing.T, p oss.StorageProvider, key string) (string, error) {
+	t.Helper()
+	reader, _, err := p.GetObject(context.Background(), key, oss.GetObjectOptions{})
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	return string(data), err
+}
+
+func TestSFTPSymlinkConfinement(t *testing.T) {
+	p, _, root := newSFTPProvider(t)
+	ctx := context.Background()
+	outside := filepath.Dir(root)
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "docs", "a.txt"), []byte("inside"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, target := range map[string]string{
+		"abs-out":  outside,
+		"rel-out":  "..",
+		"file-out": filepath.Join(outside, "secret"),
+		"abs-in":   filepath.Join(root, "docs"),
+		"rel-in":   "docs/a.txt",
+		"loop":     "loop",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, key := range []string{"abs-out/secret", "rel-out/secret", "file-out", "loop"} {
+		if _, err := readObject(t, p, key); !errors.Is(err, oss.ErrInvalidObjectKey) {
+			t.Errorf("GetObject(%q) = %v, want ErrInvalidObjectKey", key, err)
+		}
+	}
+	if err := p.SaveObject(ctx, strings.NewReader("x"), "abs-out/new", oss.SaveObjectOptions{}); !errors.Is(err, oss.ErrInvalidObjectKey) {
+		t.Errorf("SaveObject through escaping link = %v, want ErrInvalidObjectKey", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new")); !os.IsNotExist(err) {
+		t.Errorf("file created outside root: %v", err)
+	}
+	if _, err := p.GetFileList(ctx, "rel-out/"); !errors.Is(err, oss.ErrInvalidObjectKey) {
+		t.Errorf("GetFileList through escaping link = %v, want ErrInvalidObjectKey", err)
+	}
+
+	for _, key := range []string{"abs-in/a.txt", "rel-in"} {
+		if content, err := readObject(t, p, key); err != nil || content != "inside" {
+			t.Errorf("GetObject(%q) = %q, %v", key, content, err)
+		}
+	}
+
+	// 列表按链接指向的文件显示，不可访问的链接不列出
+	list, err := p.GetFileList(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := make(map[string]int64, len(list))
+	for _, element := range list {
+		listed[element.ObjectKey] = element.Size
+	}
+	if _, ok := listed["abs-in/"]; !ok || listed["rel-in"] != int64(len("inside")) || len(listed) != 3 {
+		t.Errorf("GetFileList(root) = %v, want docs/, abs-in/ and rel-in with the target size", listed)
+	}
+	// 遍历不进入指向目录的链接
+	var keys []string
+	err = oss.WalkObjects(ctx, p, "", func(entry oss.ObjectEntry) error {
+		keys = append(keys, entry.Key)
+		return nil
+	})
+	slices.Sort(keys)
+	if err != nil || strings.Join(keys, ",") != "docs/a.txt,rel-in" {
+		t.Errorf("WalkObjects = %v, %v, want docs/a.txt and rel-in", keys, err)
+	}
+
+	// 删除符号链接本身，不影响其指向的目录
+	if err := p.DeleteObject(ctx, "abs-out"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "abs-out")); !os.IsNotExist(err) {
+		t.Errorf("symlink not removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "secret")); err != nil {
+		t.Errorf("link target removed: %v", err)
+	}
+}
+
+func TestSFTPReconnect(t *testing.T) {
+	p, server, _ := newSFTPProvider(t)
+	ctx := context.Background()
+	if err := p.SaveObject(ctx, strings.NewReader("first"), "a.txt", oss.SaveObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		server.DropConnections()
+		if content, err := readObject(t, p, "a.txt"); err != nil || content != "first" {
+			t.Fatalf("GetObject after disconnect = %q, %v", content, err)
+		}
+	}
+	server.DropConnections()
+	if err := p.SaveObject(ctx, strings.NewReader("second"), "a.txt", oss.SaveObjectOptions{Overwrite: true}); err != nil {
+		t.Fatalf("SaveObject after disconnect: %v", err)
+	}
+	if content, err := readObject(t, p, "a.txt"); err != nil || content != "second" {
+		t.Fatalf("GetObject = %q, %v", content, err)
+	}
+}
+
+func TestSFTPOutOfBandChange(t *testing.T) {
+	p, _, root := newSFTPProvider(t)
+	ctx := context.Background()
+	if err := p.SaveObject(ctx, strings.NewReader("<html></html>"), "page", oss.SaveObjectOptions{Metadata: map[string]string{"owner": "lab"}}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := p.StatObject(ctx, "page", oss.GetObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(info.ContentType, "text/html") || info.SHA256 == "" {
+		t.Fatalf("info = %+v", info)
+	}
+
+	// 在服务器上直接修改文件后，记录的内容类型与摘要失效，自定义元数据保留
+	if err := os.WriteFile(filepath.Join(root, "page"), []byte("%PDF-1.7 changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err = p.StatObject(ctx, "page", oss.GetObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ContentType != "application/pdf" || info.SHA256 != "" || !strings.HasPrefix(info.ETag, "W/") {
+		t.Errorf("info after change = %+v", info)
+	}
+	if info.Metadata["owner"] != "lab" {
+		t.Errorf("metadata = %v", info.Metadata)
+	}
+}
+
+// 服务器接受连接后不再响应时，握手在超时后失败，不会一直占用连接
+func TestSFTPHandshakeTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var accepted []net.Conn
+	t.Cleanup(func() {
+		_ = listener.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range accepted {
+			_ = conn.Close()
+		}
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			accepted = append(accepted, conn)
+			mu.Unlock()
+		}
+	}()
+
+	config := &ssh.ClientConfig{User: "cube", HostKeyCallback: ssh.InsecureIgnoreHostKey(), Timeout: 200 * time.Millisecond}
+	pool := oss.NewSFTPPool(listener.Addr().String(), config, 1)
+	t.Cleanup(func() { _ = pool.Close() })
+	p := oss.NewSFTPStorageProvider(pool, "data")
+
+	start := time.Now()
+	if _, err := p.GetFileList(context.Background(), ""); err == nil {
+		t.Fatal("GetFileList succeeded against a stalled server")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("handshake took %v", elapsed)
+	}
+
+	// 请求取消时中止握手
+	ctx, cancel := context.WithCancel(context.Background())
+	config.Timeout = time.Minute
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start = time.Now()
+	if _, err := p.GetFileList(ctx, ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetFileList after cancel = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("canceled handshake took %v", elapsed)
+	}
+}
@@ -0,0 +1,621 @@
+package oss
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+type tieredConfig struct {
+	Hot           string `mapstructure:"hot"`           // 热层存储桶
+	Cold          string `mapstructure:"cold"`          // 冷层存储桶
+	ColdAfter     int    `mapstructure:"coldAfter"`     // 超过该天数未访问的对象迁移到冷层，0 表示不自动迁移
+	Interval      int    `mapstructure:"interval"`      // 迁移检查间隔，单位分钟
+	PromoteOnRead bool   `mapstructure:"promoteOnRead"` // 读取冷层对象时迁回热层
+	StateFile     string `mapstructure:"stateFile"`     // 访问记录与固定状态数据库
+}
+
+// 存储层
+const (
+	TierHot  = "hot"
+	TierCold = "cold"
+)
+
+const defaultTierInterval = 60 // 分钟
+
+var (
+	// ErrInvalidTiered 分层存储桶配置不正确
+	ErrInvalidTiered = errors.New("tiered bucket requires distinct non-tiered hot and cold buckets")
+	// ErrNotTiered 存储桶不是分层存储桶
+	ErrNotTiered = errors.New("not a tiered bucket")
+)
+
+var (
+	tierAccessBucket = []byte("access")
+	tierPinnedBucket = []byte("pinned")
+)
+
+// TierEntry 对象所在的存储层
+type TierEntry struct {
+	ObjectKey  string    `json:"object_key"`
+	Tier       string    `json:"tier"`
+	Size       int64     `json:"size"`
+	LastAccess time.Time `json:"last_access"`
+	Pinned     bool      `json:"pinned"`
+}
+
+// TierReport 一次迁移的结果
+type TierReport struct {
+	Demoted  int               `json:"demoted"`  // 迁移到冷层的对象数
+	Promoted int               `json:"promoted"` // 因固定而迁回热层的对象数
+	Failed   map[string]string `json:"failed"`
+}
+
+// TieredProvider 由热层与冷层组成的分层存储桶。
+// 写入总是落到热层，长期未访问的对象在保持对象键不变的情况下迁移到冷层，读取时依次查找热层与冷层。
+// 对象是否位于热层以热层中是否存在为准，数据库只记录访问时间与固定状态。
+type TieredProvider struct {
+	hot     StorageProvider
+	cold    StorageProvider
+	options tieredConfig
+	db      *bolt.DB
+	locks   keyLocks
+	changeNotifier
+
+	mu       sync.Mutex
+	accessed map[string]time.Time // 尚未写入数据库的访问时间
+}
+
+// NewTieredProvider 创建分层存储提供者
+func NewTieredProvider(hot, cold StorageProvider, options tieredConfig) (*TieredProvider, error) {
+	if hot == nil || cold == nil || hot == cold {
+		return nil, ErrInvalidTiered
+	}
+	for _, member := range []StorageProvider{hot, cold} {
+		if _, ok := As[*TieredProvider](member); ok {
+			return nil, ErrInvalidTiered
+		}
+	}
+	if trash, ok := As[TrashProvider](hot); ok && trash.TrashEnabled() {
+		zap.L().Warn("热层启用了回收站，迁移到冷层的对象会在热层回收站中保留一份")
+	}
+	if err := os.MkdirAll(filepath.Dir(options.StateFile), 0750); err != nil {
+		return nil, err
+	}
+	db, err := openBolt(options.StateFile, 0640)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(tierAccessBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(tierPinnedBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &TieredProvider{hot: hot, cold: cold, options: options, db: db, accessed: make(map[string]time.Time)}, nil
+}
+
+// Close 写入尚未保存的访问记录并关闭数据库，热层与冷层由各自的存储桶关闭
+func (p *TieredProvider) Close() error {
+	return errors.Join(p.flush(), p.db.Close())
+}
+
+// Seekable 与热层一致，从不可随机读取的冷层读取时会先缓存到临时文件
+func (p *TieredProvider) Seekable() bool {
+	return IsSeekable(p.hot)
+}
+
+// SaveObject 保存对象到热层，覆盖冷层中的对象时在写入热层后删除冷层副本
+func (p *TieredProvider) SaveObject(ctx context.Context, reader io.ReadSeeker, objectKey string, options SaveObjectOptions) error {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return ErrInvalidObjectKey
+	}
+	unlock := p.locks.lock(key)
+	defer unlock()
+
+	if _, err := p.hot.StatObject(ctx, key, GetObjectOptions{}); !errors.Is(err, ErrResourceNotExists) {
+		if err != nil {
+			return err
+		}
+		return p.saveHot(ctx, reader, key, options)
+	}
+	current, err := p.cold.StatObject(ctx, key, GetObjectOptions{})
+	if errors.Is(err, ErrResourceNotExists) {
+		return p.saveHot(ctx, reader, key, options)
+	}
+	if err != nil {
+		return err
+	}
+	if options.IfMatch != "" && !etagMatches(options.IfMatch, current.ETag) {
+		return ErrPreconditionFailed
+	}
+	if !options.Overwrite && options.IfMatch == "" {
+		return ErrFileAlreadyExists
+	}
+	options.Overwrite, options.IfMatch = true, ""
+	if err := p.saveHot(ctx, reader, key, options); err != nil {
+		return err
+	}
+	if err := p.cold.DeleteObject(ctx, key); err != nil {
+		zap.L().Error("删除冷层旧对象失败", zap.String("key", key), zap.Error(err))
+	}
+	return nil
+}
+
+func (p *TieredProvider) saveHot(ctx context.Context, reader io.ReadSeeker, key string, options SaveObjectOptions) error {
+	if err := p.hot.SaveObject(ctx, reader, key, options); err != nil {
+		return err
+	}
+	p.recordAccess(key)
+	return nil
+}
+
+// DeleteObject 从两层中删除对象，并清除访问记录与固定状态
+func (p *TieredProvider) DeleteObject(ctx context.Context, objectKey string) error {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil {
+		return err
+	}
+	if !isDir {
+		unlock := p.locks.lock(key)
+		defer unlock()
+	}
+	if err := errors.Join(p.hot.DeleteObject(ctx, objectKey), p.cold.DeleteObject(ctx, objectKey)); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	for name := range p.accessed {
+		if name == key || strings.HasPrefix(name, key+"/") {
+			delete(p.accessed, name)
+		}
+	}
+	p.mu.Unlock()
+	return p.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{tierAccessBucket, tierPinnedBucket} {
+			bucket := tx.Bucket(name)
+			for _, k := range boltKeysUnder(bucket, key) {
+				if err := bucket.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// GetObject 获取对象，热层中不存在时从冷层读取
+func (p *TieredProvider) GetObject(ctx context.Context, objectKey string, options GetObjectOptions) (io.ReadCloser, *GetObjectInfo, error) {
+	reader, info, err := p.hot.GetObject(ctx, objectKey, options)
+	if !errors.Is(err, ErrResourceNotExists) {
+		if err == nil {
+			p.recordAccess(objectKey)
+		}
+		return reader, info, err
+	}
+	if p.options.PromoteOnRead && options.VersionID == "" {
+		if err := p.promote(ctx, objectKey); err != nil && !errors.Is(err, ErrResourceNotExists) {
+			zap.L().Error("冷层对象迁回热层失败", zap.String("key", objectKey), zap.Error(err))
+		} else if err == nil {
+			return p.GetObject(ctx, objectKey, options)
+		}
+	}
+	reader, info, err = p.cold.GetObject(ctx, objectKey, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.recordAccess(objectKey)
+	if p.Seekable() && !IsSeekable(p.cold) {
+		return spoolObject(reader, info)
+	}
+	return reader, info, nil
+}
+
+func (p *TieredProvider) StatObject(ctx context.Context, objectKey string, options GetObjectOptions) (*GetObjectInfo, error) {
+	info, err := p.hot.StatObject(ctx, objectKey, options)
+	if errors.Is(err, ErrResourceNotExists) {
+		return p.cold.StatObject(ctx, objectKey, options)
+	}
+	return info, err
+}
+
+// GetFileList 合并两层的文件列表，迁移过程中两层都存在的对象以热层为准
+func (p *TieredProvider) GetFileList(ctx context.Context, prefix string) ([]FileListElement, error) {
+	list, err := p.hot.GetFileList(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	coldList, err := p.cold.GetFileList(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{}, len(list))
+	for _, item := range list {
+		seen[item.ObjectKey] = struct{}{}
+	}
+	for _, item := range coldList {
+		if _, exists := seen[item.ObjectKey]; !exists {
+			list = append(list, item)
+		}
+	}
+	slices.SortFunc(list, func(a, b FileListElement) int {
+		return strings.Compare(a.ObjectKey, b.ObjectKey)
+	})
+	return list, nil
+}
+
+// GetMetadata 获取对象所在层的自定义元数据与标签
+func (p *TieredProvider) GetMetadata(ctx context.Context, objectKey string) (*UserMetadata, error) {
+	provider, err := p.locate(ctx, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	metadata, ok := As[MetadataProvider](provider)
+	if !ok {
+		return nil, ErrMetadataNotSupported
+	}
+	return metadata.GetMetadata(ctx, objectKey)
+}
+
+// UpdateMetadata 更新对象所在层的自定义元数据与标签
+func (p *TieredProvider) UpdateMetadata(ctx context.Context, objectKey string, update UserMetadata) error {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return ErrInvalidObjectKey
+	}
+	unlock := p.locks.lock(key)
+	defer unlock()
+	provider, err := p.locate(ctx, key)
+	if err != nil {
+		return err
+	}
+	metadata, ok := As[MetadataProvider](provider)
+	if !ok {
+		return ErrMetadataNotSupported
+	}
+	return metadata.UpdateMetadata(ctx, key, update)
+}
+
+// locate 返回对象所在的层
+func (p *TieredProvider) locate(ctx context.Context, key string) (StorageProvider, error) {
+	_, err := p.hot.StatObject(ctx, key, GetObjectOptions{})
+	if err == nil {
+		return p.hot, nil
+	}
+	if !errors.Is(err, ErrResourceNotExists) {
+		return nil, err
+	}
+	if _, err := p.cold.StatObject(ctx, key, GetObjectOptions{}); err != nil {
+		return nil, err
+	}
+	return p.cold, nil
+}
+
+// Pin 固定或取消固定对象，固定的对象不会迁移到冷层，位于冷层时立即迁回热层
+func (p *TieredProvider) Pin(ctx context.Context, objectKey string, pinned bool) (*TierEntry, error) {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return nil, ErrInvalidObjectKey
+	}
+	if _, err := p.locate(ctx, key); err != nil {
+		return nil, err
+	}
+	err = p.db.Update(func(tx *bolt.Tx) error {
+		if pinned {
+			return tx.Bucket(tierPinnedBucket).Put([]byte(key), []byte{})
+		}
+		return tx.Bucket(tierPinnedBucket).Delete([]byte(key))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pinned {
+		if err := p.promote(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+	return p.entry(ctx, key)
+}
+
+func (p *TieredProvider) entry(ctx context.Context, key string) (*TierEntry, error) {
+	tier := TierHot
+	info, err := p.hot.StatObject(ctx, key, GetObjectOptions{})
+	if errors.Is(err, ErrResourceNotExists) {
+		tier = TierCold
+		info, err = p.cold.StatObject(ctx, key, GetObjectOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+	state, err := p.keyState(key)
+	if err != nil {
+		return nil, err
+	}
+	_, pinned := state.pinned[key]
+	return &TierEntry{ObjectKey: key, Tier: tier, Size: info.ContentLength, LastAccess: state.lastAccess(key, info.LastModified), Pinned: pinned}, nil
+}
+
+// List 列出前缀下所有对象所在的层
+func (p *TieredProvider) List(ctx context.Context, prefix string) ([]TierEntry, error) {
+	state, err := p.loadState()
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]TierEntry)
+	for _, tier := range []struct {
+		name     string
+		provider StorageProvider
+	}{{TierCold, p.cold}, {TierHot, p.hot}} {
+		err := WalkObjects(ctx, tier.provider, prefix, func(entry ObjectEntry) error {
+			_, pinned := state.pinned[entry.Key]
+			entries[entry.Key] = TierEntry{
+				ObjectKey:  entry.Key,
+				Tier:       tier.name,
+				Size:       entry.Size,
+				LastAccess: state.lastAccess(entry.Key, entry.LastModified),
+				Pinned:     pinned,
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	list := make([]TierEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	slices.SortFunc(list, func(a, b TierEntry) int {
+		return strings.Compare(a.ObjectKey, b.ObjectKey)
+	})
+	return list, nil
+}
+
+// Migrate 将超过 coldAfter 天未访问且未固定的对象迁移到冷层，并将位于冷层的固定对象迁回热层
+func (p *TieredProvider) Migrate(ctx context.Context) (*TierReport, error) {
+	state, err := p.loadState()
+	if err != nil {
+		return nil, err
+	}
+	report := &TierReport{Failed: make(map[string]string)}
+	if p.options.ColdAfter > 0 {
+		cutoff := time.Now().AddDate(0, 0, -p.options.ColdAfter)
+		var candidates []string
+		err := WalkObjects(ctx, p.hot, "", func(entry ObjectEntry) error {
+			if _, pinned := state.pinned[entry.Key]; !pinned && state.lastAccess(entry.Key, entry.LastModified).Before(cutoff) {
+				candidates = append(candidates, entry.Key)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range candidates {
+			moved, err := p.demote(ctx, key, cutoff)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if err != nil {
+				report.Failed[key] = err.Error()
+			} else if moved {
+				report.Demoted++
+			}
+		}
+	}
+	for key := range state.pinned {
+		if _, err := p.hot.StatObject(ctx, key, GetObjectOptions{}); !errors.Is(err, ErrResourceNotExists) {
+			continue
+		}
+		err := p.promote(ctx, key)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil && !errors.Is(err, ErrResourceNotExists) {
+			report.Failed[key] = err.Error()
+		} else if err == nil {
+			report.Promoted++
+		}
+	}
+	return report, nil
+}
+
+// demote 将对象复制到冷层后从热层删除，期间被访问或固定时放弃
+func (p *TieredProvider) demote(ctx context.Context, key string, cutoff time.Time) (moved bool, err error) {
+	defer func() {
+		if moved {
+			p.notifyChange(key)
+		}
+	}()
+	unlock := p.locks.lock(key)
+	defer unlock()
+	info, err := p.hot.StatObject(ctx, key, GetObjectOptions{})
+	if err != nil {
+		return false, err
+	}
+	state, err := p.keyState(key)
+	if err != nil {
+		return false, err
+	}
+	if _, pinned := state.pinned[key]; pinned || !state.lastAccess(key, info.LastModified).Before(cutoff) {
+		return false, nil
+	}
+	if err := copyObject(ctx, p.hot, p.cold, key); err != nil {
+		return false, err
+	}
+	return true, p.hot.DeleteObject(ctx, key)
+}
+
+// promote 将冷层中的对象迁回热层
+func (p *TieredProvider) promote(ctx context.Context, key string) error {
+	var copied bool
+	defer func() {
+		if copied {
+			p.notifyChange(key)
+		}
+	}()
+	unlock := p.locks.lock(key)
+	defer unlock()
+	if _, err := p.hot.StatObject(ctx, key, GetObjectOptions{}); !errors.Is(err, ErrResourceNotExists) {
+		return err
+	}
+	if err := copyObject(ctx, p.cold, p.hot, key); err != nil {
+		return err
+	}
+	copied = true
+	p.recordAccess(key)
+	return p.cold.DeleteObject(ctx, key)
+}
+
+func (p *TieredProvider) recordAccess(key string) {
+	p.mu.Lock()
+	p.accessed[key] = time.Now()
+	p.mu.Unlock()
+}
+
+// flush 将内存中的访问时间写入数据库
+func (p *TieredProvider) flush() error {
+	p.mu.Lock()
+	accessed := p.accessed
+	p.accessed = make(map[string]time.Time)
+	p.mu.Unlock()
+	if len(accessed) == 0 {
+		return nil
+	}
+	return p.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(tierAccessBucket)
+		for key, at := range accessed {
+			if err := bucket.Put([]byte(key), binary.BigEndian.AppendUint64(nil, uint64(at.Unix()))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type tierState struct {
+	access map[string]time.Time
+	pinned map[string]struct{}
+}
+
+// lastAccess 返回对象的最后访问时间，没有访问记录时以修改时间代替
+func (s *tierState) lastAccess(key string, modified time.Time) time.Time {
+	if at, ok := s.access[key]; ok {
+		return at
+	}
+	return modified
+}
+
+// loadState 读取所有访问记录与固定状态，包括尚未写入数据库的访问时间
+func (p *TieredProvider) loadState() (*tierState, error) {
+	if err := p.flush(); err != nil {
+		return nil, err
+	}
+	state := &tierState{access: make(map[string]time.Time), pinned: make(map[string]struct{})}
+	err := p.db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket(tierAccessBucket).ForEach(func(k, v []byte) error {
+			if len(v) == 8 {
+				state.access[string(k)] = time.Unix(int64(binary.BigEndian.Uint64(v)), 0)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(tierPinnedBucket).ForEach(func(k, _ []byte) error {
+			state.pinned[string(k)] = struct{}{}
+			return nil
+		})
+	})
+	return state, err
+}
+
+// keyState 读取单个对象的访问记录与固定状态
+func (p *TieredProvider) keyState(key string) (*tierState, error) {
+	if err := p.flush(); err != nil {
+		return nil, err
+	}
+	state := &tierState{access: make(map[string]time.Time), pinned: make(map[string]struct{})}
+	err := p.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(tierAccessBucket).Get([]byte(key)); len(v) == 8 {
+			state.access[key] = time.Unix(int64(binary.BigEndian.Uint64(v)), 0)
+		}
+		if tx.Bucket(tierPinnedBucket).Get([]byte(key)) != nil {
+			state.pinned[key] = struct{}{}
+		}
+		return nil
+	})
+	return state, err
+}
+
+// tierMigrator 定期保存访问记录并执行迁移
+type tierMigrator struct {
+	provider *TieredProvider
+	interval time.Duration
+	stop     chan struct{}
+	ctx      context.Context // 迁移使用，关闭时取消
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func startTierMigrator(provider *TieredProvider) *tierMigrator {
+	interval := provider.options.Interval
+	if interval <= 0 {
+		interval = defaultTierInterval
+	}
+	m := &tierMigrator{
+		provider: provider,
+		interval: time.Duration(interval) * time.Minute,
+		stop:     make(chan struct{}),
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.wg.Add(1)
+	go m.run()
+	return m
+}
+
+func (m *tierMigrator) run() {
+	defer m.wg.Done()
+	flush := time.NewTicker(time.Minute)
+	defer flush.Stop()
+	migrate := time.NewTicker(m.interval)
+	defer migrate.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-flush.C:
+			if err := m.provider.flush(); err != nil {
+				zap.L().Error("保存访问记录失败", zap.Error(err))
+			}
+		case <-migrate.C:
+			report, err := m.provider.Migrate(m.ctx)
+			if err != nil {
+				zap.L().Error("分层迁移失败", zap.Error(err))
+				continue
+			}
+			zap.L().Info("分层迁移完成", zap.Int("demoted", report.Demoted), zap.Int("promoted", report.Promoted), zap.Int("failed", len(report.Failed)))
+		}
+	}
+}
+
+func (m *tierMigrator) Close() error {
+	close(m.stop)
+	m.cancel()
+	m.wg.Wait()
+	return nil
+}
@@ -0,0 +1,102 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type trashConfig struct {
+	Enabled   bool `mapstructure:"enabled"`
+	Retention int  `mapstructure:"retention"` // 回收站保留天数，0 表示不自动清理
+}
+
+// TrashItem 回收站条目
+type TrashItem struct {
+	ID        string    `json:"id"`
+	ObjectKey string    `json:"object_key"`
+	DeletedAt time.Time `json:"deleted_at"`
+	Size      int64     `json:"size"`
+	Objects   int64     `json:"objects,omitempty"` // 目录条目包含的对象数
+}
+
+// TrashProvider 由支持回收站的存储提供者实现
+type TrashProvider interface {
+	TrashEnabled() bool
+	ListTrash(ctx context.Context) ([]TrashItem, error)
+	RestoreTrash(ctx context.Context, id string) (*TrashItem, error)
+	PurgeTrash(ctx context.Context, id string) error
+}
+
+var (
+	// ErrTrashDisabled 存储桶未启用回收站
+	ErrTrashDisabled = errors.New("trash disabled")
+	// ErrTrashItemNotFound 回收站条目不存在
+	ErrTrashItemNotFound = errors.New("trash item not found")
+)
+
+const (
+	trashDir      = internalDir + "/trash"
+	trashMetaName = "meta.json"
+	trashDataName = "object"
+)
+
+// trashJanitor 定期清理超过保留期的回收站条目
+type trashJanitor struct {
+	provider  TrashProvider
+	retention time.Duration
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func startTrashJanitor(provider TrashProvider, retentionDays int) *trashJanitor {
+	j := &trashJanitor{
+		provider:  provider,
+		retention: time.Duration(retentionDays) * 24 * time.Hour,
+		stop:      make(chan struct{}),
+	}
+	j.wg.Add(1)
+	go j.run()
+	return j
+}
+
+func (j *trashJanitor) run() {
+	defer j.wg.Done()
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		j.purgeExpired()
+		select {
+		case <-j.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *trashJanitor) purgeExpired() {
+	ctx := context.Background()
+	items, err := j.provider.ListTrash(ctx)
+	if err != nil {
+		zap.L().Error("读取回收站失败", zap.Error(err))
+		return
+	}
+	deadline := time.Now().Add(-j.retention)
+	for _, item := range items {
+		if item.DeletedAt.After(deadline) {
+			continue
+		}
+		if err := j.provider.PurgeTrash(ctx, item.ID); err != nil {
+			zap.L().Error("清理回收站失败", zap.String("id", item.ID), zap.Error(err))
+		}
+	}
+}
+
+func (j *trashJanitor) Close() error {
+	close(j.stop)
+	j.wg.Wait()
+	return nil
+}
@@ -0,0 +1,49 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type versioningConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Mode    string `mapstructure:"mode"` // S3 存储桶的版本实现：native 或 suffix
+}
+
+// S3 版本实现方式
+const (
+	S3VersioningNative = "native" // 使用 S3 原生版本控制
+	S3VersioningSuffix = "suffix" // 将历史版本复制到内部目录
+)
+
+// ObjectVersion 对象版本
+type ObjectVersion struct {
+	VersionID    string    `json:"version_id"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"last_modified"`
+	IsLatest     bool      `json:"is_latest"`
+}
+
+// VersionProvider 由支持多版本的存储提供者实现
+type VersionProvider interface {
+	VersioningEnabled() bool
+	ListVersions(ctx context.Context, objectKey string) ([]ObjectVersion, error)
+	RestoreVersion(ctx context.Context, objectKey string, versionID string) error
+	DeleteVersion(ctx context.Context, objectKey string, versionID string) error
+}
+
+var (
+	// ErrVersioningDisabled 存储桶未启用多版本
+	ErrVersioningDisabled = errors.New("versioning disabled")
+	// ErrVersionNotFound 版本不存在
+	ErrVersionNotFound = errors.New("version not found")
+)
+
+const versionsDir = internalDir + "/versions"
+
+// versionKey 返回历史版本在内部目录中的对象键
+func versionKey(key, versionID string) string {
+	return versionsDir + "/" + key + "@" + versionID
+}
@@ -0,0 +1,61 @@
+package oss_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"cube-go/pkg/oss"
+)
+
+func TestLocalVersionConcurrentSaves(t *testing.T) {
+	ctx := context.Background()
+	local := newLocalProvider(t, oss.LocalStorageOptions{Versioning: true, Metadata: "bolt"})
+	if err := local.SaveObject(ctx, strings.NewReader("v0"), "doc.txt", oss.SaveObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	const writers = 16
+	var wg sync.WaitGroup
+	var missing atomic.Int64
+	done := make(chan struct{})
+	go func() {
+		// 替换期间当前版本必须一直可读
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := local.StatObject(ctx, "doc.txt", oss.GetObjectOptions{}); err != nil {
+				missing.Add(1)
+			}
+		}
+	}()
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			content := fmt.Sprintf("v%d", i+1)
+			if err := local.SaveObject(ctx, strings.NewReader(content), "doc.txt", oss.SaveObjectOptions{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+
+	if n := missing.Load(); n > 0 {
+		t.Fatalf("current version missing %d times during replacement", n)
+	}
+	versions, err := local.ListVersions(ctx, "doc.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != writers+1 {
+		t.Fatalf("ListVersions returned %d versions, want %d", len(versions), writers+1)
+	}
+}
@@ -0,0 +1,49 @@
+package oss
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// ObjectEntry 遍历时得到的对象条目
+type ObjectEntry struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// objectWalker 由能够高效递归遍历对象的存储提供者实现
+type objectWalker interface {
+	walkObjects(ctx context.Context, prefix string, fn func(ObjectEntry) error) error
+}
+
+// WalkObjects 递归遍历前缀下的所有对象
+func WalkObjects(ctx context.Context, p StorageProvider, prefix string, fn func(ObjectEntry) error) error {
+	if walker, ok := p.(objectWalker); ok {
+		return walker.walkObjects(ctx, prefix, fn)
+	}
+	list, err := p.GetFileList(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, item := range list {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if item.Type == "dir" {
+			if err := WalkObjects(ctx, p, item.ObjectKey, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		entry := ObjectEntry{Key: strings.TrimPrefix(item.ObjectKey, "/"), Size: item.Size}
+		if modified, err := time.Parse(time.RFC3339, item.LastModified); err == nil {
+			entry.LastModified = modified
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
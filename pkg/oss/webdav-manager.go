@@ -0,0 +1,52 @@
+package oss
+
+import (
+	"net/http"
+	"time"
+
+	"cube-go/pkg/config"
+)
+
+type webdavConfigElement struct {
+	Name     string `mapstructure:"name"`
+	Endpoint string `mapstructure:"endpoint"` // WebDAV 根地址，存储桶的 path 相对于该地址
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	Timeout  int    `mapstructure:"timeout"` // 等待响应头的超时 单位秒，默认 30
+}
+
+// webdavConnection WebDAV 连接，同一连接下的存储桶共用 HTTP 客户端
+type webdavConnection struct {
+	endpoint string
+	options  WebDAVStorageOptions
+}
+
+// initWebDAVConnections 初始化WebDAV连接
+func initWebDAVConnections() (map[string]*webdavConnection, error) {
+	var cfgList []webdavConfigElement
+	err := config.Config.UnmarshalKey("webdav", &cfgList)
+	if err != nil {
+		return nil, err
+	}
+
+	connections := make(map[string]*webdavConnection, len(cfgList))
+	for _, c := range cfgList {
+		if _, exists := connections[c.Name]; exists {
+			return nil, ErrConnectionAlreadyExists
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.ResponseHeaderTimeout = 30 * time.Second
+		if c.Timeout > 0 {
+			transport.ResponseHeaderTimeout = time.Duration(c.Timeout) * time.Second
+		}
+		connections[c.Name] = &webdavConnection{
+			endpoint: c.Endpoint,
+			options: WebDAVStorageOptions{
+				Username: c.Username,
+				Password: c.Password,
+				Client:   &http.Client{Transport: transport},
+			},
+		}
+	}
+	return connections, nil
+}
@@ -0,0 +1,528 @@
+package oss
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebDAVStorageProvider WebDAV 存储提供者，对象保存在 endpoint 指向的集合下，
+// 条件与范围请求转发给服务端处理。
+type WebDAVStorageProvider struct {
+	client   *http.Client
+	endpoint *url.URL
+	username string
+	password string
+}
+
+// WebDAVStorageOptions WebDAV 存储提供者选项
+type WebDAVStorageOptions struct {
+	Username string
+	Password string
+	Client   *http.Client // 为空时使用 http.DefaultClient
+}
+
+// errWebDAVConflict PUT 或 MKCOL 的上级集合不存在
+var errWebDAVConflict = errors.New("webdav: parent collection does not exist")
+
+// webdavError 未映射到已有错误的 WebDAV 响应
+type webdavError struct {
+	method string
+	status string
+}
+
+func (e *webdavError) Error() string {
+	return "webdav " + e.method + ": " + e.status
+}
+
+// webdavPropfindBody 列表所需的属性
+const webdavPropfindBody = `<?xml version="1.0" encoding="utf-8"?>` +
+	`<d:propfind xmlns:d="DAV:"><d:prop>` +
+	`<d:resourcetype/><d:getcontentlength/><d:getcontenttype/><d:getlastmodified/><d:getetag/>` +
+	`</d:prop></d:propfind>`
+
+type webdavMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Prop   webdavProp `xml:"DAV: prop"`
+			Status string     `xml:"DAV: status"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+type webdavProp struct {
+	ResourceType struct {
+		Collection *struct{} `xml:"DAV: collection"`
+	} `xml:"DAV: resourcetype"`
+	ContentLength string `xml:"DAV: getcontentlength"`
+	ContentType   string `xml:"DAV: getcontenttype"`
+	LastModified  string `xml:"DAV: getlastmodified"`
+	ETag          string `xml:"DAV: getetag"`
+}
+
+// webdavEntry PROPFIND 返回的资源
+type webdavEntry struct {
+	key          string
+	collection   bool
+	size         int64
+	contentType  string
+	lastModified time.Time
+}
+
+// visible 判断资源能否作为对象出现在列表中，内部目录及无法作为对象键的名称不列出
+func (e *webdavEntry) visible() bool {
+	key, _, err := NormalizeObjectKey(e.key, false)
+	return err == nil && key == e.key
+}
+
+// NewWebDAVStorageProvider 创建 WebDAV 存储提供者，endpoint 为存放对象的集合地址
+func NewWebDAVStorageProvider(endpoint string, options WebDAVStorageOptions) (*WebDAVStorageProvider, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("webdav: endpoint must be an http or https URL")
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	client := options.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WebDAVStorageProvider{client: client, endpoint: u, username: options.Username, password: options.Password}, nil
+}
+
+// SaveObject 使用 PUT 上传对象，不覆盖时携带 If-None-Match: *，上级集合不存在时逐级创建后重试。
+// 不保存自定义元数据与标签，指定时返回 ErrMetadataNotSupported；原始文件名、上传者与上传时间同样不保存
+func (p *WebDAVStorageProvider) SaveObject(ctx context.Context, reader io.ReadSeeker, objectKey string, options SaveObjectOptions) error {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return ErrInvalidObjectKey
+	}
+	if len(options.Metadata) > 0 || len(options.Tags) > 0 {
+		return ErrMetadataNotSupported
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, reader)
+	if err != nil {
+		return err
+	}
+	if options.SHA256 != "" && !strings.EqualFold(options.SHA256, hex.EncodeToString(hash.Sum(nil))) {
+		return ErrChecksumMismatch
+	}
+	contentType := detectMimeType(reader)
+
+	err = p.put(ctx, key, reader, size, contentType, options)
+	if errors.Is(err, errWebDAVConflict) {
+		if err = p.mkcolAll(ctx, path.Dir(key)); err == nil {
+			err = p.put(ctx, key, reader, size, contentType, options)
+		}
+	}
+	return err
+}
+
+func (p *WebDAVStorageProvider) put(ctx context.Context, key string, reader io.ReadSeeker, size int64, contentType string, options SaveObjectOptions) error {
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	req, err := p.newRequest(ctx, http.MethodPut, key, false, io.NopCloser(reader))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	if options.IfMatch != "" {
+		req.Header.Set("If-Match", options.IfMatch)
+	} else if !options.Overwrite {
+		req.Header.Set("If-None-Match", "*")
+	}
+	resp, err := p.do(req)
+	if err != nil {
+		return err
+	}
+	defer drainBody(resp)
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	case http.StatusPreconditionFailed:
+		if options.IfMatch != "" {
+			return ErrPreconditionFailed
+		}
+		return ErrFileAlreadyExists
+	case http.StatusConflict:
+		return errWebDAVConflict
+	case http.StatusMethodNotAllowed:
+		// 目标为集合
+		return ErrFileAlreadyExists
+	}
+	return mapWebDAVError(resp)
+}
+
+// mkcolAll 自根集合起逐级创建集合，路径中存在同名对象时返回 ErrFileAlreadyExists
+func (p *WebDAVStorageProvider) mkcolAll(ctx context.Context, dir string) error {
+	names := []string{""}
+	for current := dir; current != "."; current = path.Dir(current) {
+		names = append(names, current)
+	}
+	slices.Reverse(names[1:])
+	for _, name := range names {
+		req, err := p.newRequest(ctx, "MKCOL", name, true, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := p.do(req)
+		if err != nil {
+			return err
+		}
+		drainBody(resp)
+		switch resp.StatusCode {
+		case http.StatusCreated:
+			continue
+		case http.StatusMethodNotAllowed:
+			// 已存在，确认是集合而不是对象
+			entries, err := p.propfind(ctx, name, "0")
+			if errors.Is(err, ErrResourceNotExists) || err == nil && !entries[0].collection {
+				return ErrFileAlreadyExists
+			}
+			if err != nil {
+				return err
+			}
+		default:
+			return mapWebDAVError(resp)
+		}
+	}
+	return nil
+}
+
+// DeleteObject 删除对象或集合，集合由服务端递归删除；目标不存在时仍视为成功。
+// WebDAV 的 DELETE 对集合总是递归执行，检查为空与删除之间可能有新对象写入，因此不删除变空的上级集合，
+// 变空的集合仍会出现在列表中
+func (p *WebDAVStorageProvider) DeleteObject(ctx context.Context, objectKey string) error {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil {
+		return err
+	}
+	return p.delete(ctx, key, isDir)
+}
+
+func (p *WebDAVStorageProvider) delete(ctx context.Context, key string, collection bool) error {
+	req, err := p.newRequest(ctx, http.MethodDelete, key, collection, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.do(req)
+	if err != nil {
+		return err
+	}
+	defer drainBody(resp)
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	case http.StatusMultiStatus:
+		// 集合中部分资源删除失败
+		return &webdavError{method: http.MethodDelete, status: resp.Status}
+	}
+	return mapWebDAVError(resp)
+}
+
+// GetObject 获取对象，范围与条件请求头原样转发
+func (p *WebDAVStorageProvider) GetObject(ctx context.Context, objectKey string, options GetObjectOptions) (io.ReadCloser, *GetObjectInfo, error) {
+	resp, err := p.read(ctx, http.MethodGet, objectKey, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Body, webdavObjectInfo(resp), nil
+}
+
+func (p *WebDAVStorageProvider) StatObject(ctx context.Context, objectKey string, options GetObjectOptions) (*GetObjectInfo, error) {
+	resp, err := p.read(ctx, http.MethodHead, objectKey, options)
+	if err != nil {
+		return nil, err
+	}
+	drainBody(resp)
+	return webdavObjectInfo(resp), nil
+}
+
+func (p *WebDAVStorageProvider) read(ctx context.Context, method, objectKey string, options GetObjectOptions) (*http.Response, error) {
+	key, isDir, err := NormalizeObjectKey(objectKey, false)
+	if err != nil || isDir {
+		return nil, ErrInvalidObjectKey
+	}
+	if options.VersionID != "" {
+		return nil, ErrResourceNotExists
+	}
+	req, err := p.newRequest(ctx, method, key, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	if options.Range != "" {
+		req.Header.Set("Range", options.Range)
+	}
+	conditions := options.Conditions
+	if conditions.IfMatch != "" {
+		req.Header.Set("If-Match", conditions.IfMatch)
+	}
+	if conditions.IfNoneMatch != "" {
+		req.Header.Set("If-None-Match", conditions.IfNoneMatch)
+	}
+	if conditions.IfModifiedSince != nil {
+		req.Header.Set("If-Modified-Since", conditions.IfModifiedSince.UTC().Format(http.TimeFormat))
+	}
+	if conditions.IfUnmodifiedSince != nil {
+		req.Header.Set("If-Unmodified-Since", conditions.IfUnmodifiedSince.UTC().Format(http.TimeFormat))
+	}
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+		return resp, nil
+	case http.StatusMethodNotAllowed:
+		// 目标为集合
+		drainBody(resp)
+		return nil, ErrResourceNotExists
+	}
+	drainBody(resp)
+	return nil, mapWebDAVError(resp)
+}
+
+// GetFileList 使用 Depth: 1 的 PROPFIND 获取集合的直接子项。
+// 子集合按服务端返回原样列出，不再逐个递归检查，因此删除对象后留下的空集合仍会显示
+func (p *WebDAVStorageProvider) GetFileList(ctx context.Context, prefix string) ([]FileListElement, error) {
+	key, _, err := NormalizeObjectKey(prefix, true)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := p.propfind(ctx, key, "1")
+	if errors.Is(err, ErrResourceNotExists) {
+		return []FileListElement{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !entries[0].collection {
+		return nil, ErrPathIsNotDir
+	}
+	list := make([]FileListElement, 0, len(entries)-1)
+	for _, entry := range entries[1:] {
+		if !entry.visible() {
+			continue
+		}
+		element := FileListElement{
+			Name:         path.Base(entry.key),
+			Size:         entry.size,
+			Type:         "dir",
+			LastModified: entry.lastModified.Format(time.RFC3339),
+			ObjectKey:    entry.key,
+		}
+		if entry.collection {
+			element.ObjectKey += "/"
+		} else {
+			contentType := entry.contentType
+			if contentType == "" {
+				contentType = mime.TypeByExtension(path.Ext(entry.key))
+			}
+			element.Type = classifyMIME(contentType)
+		}
+		list = append(list, element)
+	}
+	slices.SortFunc(list, func(a, b FileListElement) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return list, nil
+}
+
+// walkObjects 逐级 PROPFIND 递归遍历对象，许多服务端禁用了 Depth: infinity
+func (p *WebDAVStorageProvider) walkObjects(ctx context.Context, prefix string, fn func(ObjectEntry) error) error {
+	key, _, err := NormalizeObjectKey(prefix, true)
+	if err != nil {
+		return err
+	}
+	entries, err := p.propfind(ctx, key, "1")
+	if errors.Is(err, ErrResourceNotExists) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !entries[0].collection {
+		return fn(ObjectEntry{Key: key, Size: entries[0].size, LastModified: entries[0].lastModified})
+	}
+	for _, entry := range entries[1:] {
+		if !entry.visible() {
+			continue
+		}
+		if entry.collection {
+			err = p.walkObjects(ctx, entry.key, fn)
+		} else {
+			err = fn(ObjectEntry{Key: entry.key, Size: entry.size, LastModified: entry.lastModified})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// propfind 查询资源及其子项，返回结果的第一项为资源本身
+func (p *WebDAVStorageProvider) propfind(ctx context.Context, key string, depth string) ([]webdavEntry, error) {
+	req, err := p.newRequest(ctx, "PROPFIND", key, true, io.NopCloser(strings.NewReader(webdavPropfindBody)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer drainBody(resp)
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, mapWebDAVError(resp)
+	}
+	var multistatus webdavMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&multistatus); err != nil {
+		return nil, err
+	}
+
+	var self *webdavEntry
+	var entries []webdavEntry
+	for _, response := range multistatus.Responses {
+		entryKey, ok := p.hrefKey(response.Href)
+		if !ok {
+			continue
+		}
+		entry := webdavEntry{key: entryKey}
+		for _, propstat := range response.Propstat {
+			if !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+			prop := propstat.Prop
+			entry.collection = entry.collection || prop.ResourceType.Collection != nil
+			if prop.ContentLength != "" {
+				entry.size, _ = strconv.ParseInt(strings.TrimSpace(prop.ContentLength), 10, 64)
+			}
+			if prop.ContentType != "" {
+				entry.contentType = prop.ContentType
+			}
+			if modified, err := http.ParseTime(prop.LastModified); err == nil {
+				entry.lastModified = modified
+			}
+		}
+		if entryKey == key {
+			self = &entry
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if self == nil {
+		return nil, ErrResourceNotExists
+	}
+	return append([]webdavEntry{*self}, entries...), nil
+}
+
+// hrefKey 将响应中的 href 转换为对象键，不在根集合下的 href 返回 false
+func (p *WebDAVStorageProvider) hrefKey(href string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", false
+	}
+	rel, ok := strings.CutPrefix(u.Path, p.endpoint.Path)
+	if !ok || rel != "" && !strings.HasPrefix(rel, "/") {
+		return "", false
+	}
+	return strings.Trim(rel, "/"), true
+}
+
+func (p *WebDAVStorageProvider) newRequest(ctx context.Context, method, key string, collection bool, body io.ReadCloser) (*http.Request, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	u := *p.endpoint
+	u.Path = strings.TrimSuffix(path.Join(u.Path, key), "/")
+	if collection {
+		u.Path += "/"
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if p.username != "" || p.password != "" {
+		req.SetBasicAuth(p.username, p.password)
+	}
+	return req, nil
+}
+
+func (p *WebDAVStorageProvider) do(req *http.Request) (*http.Response, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if ctxErr := req.Context().Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// mapWebDAVError 将错误响应映射为对应的错误，条件与范围错误附带响应中的对象信息
+func mapWebDAVError(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return &ObjectResponseError{Err: ErrNotModified, Info: webdavObjectInfo(resp)}
+	case http.StatusNotFound, http.StatusGone:
+		return ErrResourceNotExists
+	case http.StatusPreconditionFailed:
+		return &ObjectResponseError{Err: ErrPreconditionFailed, Info: webdavObjectInfo(resp)}
+	case http.StatusRequestedRangeNotSatisfiable:
+		return &ObjectResponseError{Err: ErrInvalidRange, Info: webdavObjectInfo(resp)}
+	case http.StatusInsufficientStorage:
+		return ErrQuotaExceeded
+	default:
+		return &webdavError{method: resp.Request.Method, status: resp.Status}
+	}
+}
+
+func webdavObjectInfo(resp *http.Response) *GetObjectInfo {
+	info := &GetObjectInfo{
+		ContentType:   resp.Header.Get("Content-Type"),
+		ContentLength: resp.ContentLength,
+		ContentRange:  resp.Header.Get("Content-Range"),
+		AcceptRanges:  resp.Header.Get("Accept-Ranges"),
+		ETag:          resp.Header.Get("ETag"),
+	}
+	if info.ContentLength < 0 {
+		if length, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64); err == nil {
+			info.ContentLength = length
+		}
+	}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.LastModified = modified
+	}
+	return info
+}
+
+// drainBody 读尽并关闭响应体以复用连接
+func drainBody(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,51 @@
+package oss_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"cube-go/pkg/oss"
+	"cube-go/pkg/oss/osstest"
+)
+
+func TestWebDAVEscapedKeys(t *testing.T) {
+	p, err := oss.NewWebDAVStorageProvider(osstest.NewWebDAVServer(t)+"/bucket", oss.WebDAVStorageOptions{Username: "cube", Password: "cube"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	keys := []string{"活动 照片/a #1.txt", "活动 照片/100%.txt", "活动 照片/q?x=1&y.txt"}
+	for _, key := range keys {
+		if err := p.SaveObject(ctx, strings.NewReader(key), key, oss.SaveObjectOptions{}); err != nil {
+			t.Fatalf("SaveObject(%q): %v", key, err)
+		}
+		if content, err := readObject(t, p, key); err != nil || content != key {
+			t.Errorf("GetObject(%q) = %q, %v", key, content, err)
+		}
+	}
+	list, err := p.GetFileList(ctx, "活动 照片/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != len(keys) {
+		t.Fatalf("GetFileList = %+v", list)
+	}
+	for _, element := range list {
+		if _, err := p.StatObject(ctx, element.ObjectKey, oss.GetObjectOptions{}); err != nil {
+			t.Errorf("StatObject(%q) from listing: %v", element.ObjectKey, err)
+		}
+	}
+}
+
+func TestWebDAVUnauthorized(t *testing.T) {
+	p, err := oss.NewWebDAVStorageProvider(osstest.NewWebDAVServer(t), oss.WebDAVStorageOptions{Username: "cube", Password: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.SaveObject(context.Background(), strings.NewReader("x"), "a.txt", oss.SaveObjectOptions{})
+	if err == nil || errors.Is(err, oss.ErrFileAlreadyExists) || !strings.Contains(err.Error(), "401") {
+		t.Errorf("SaveObject with wrong password = %v, want 401 error", err)
+	}
+}
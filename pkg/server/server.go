@@ -6,26 +6,78 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"cube-go/pkg/config"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+type corsConfig struct {
+	AllowOrigins []string `mapstructure:"allowOrigins"`
+}
+
+type bucketCORSConfig struct {
+	Name string     `mapstructure:"name"`
+	CORS corsConfig `mapstructure:"cors"`
+}
+
 // InitCORS 初始化 CORS 中间件
+// 公开文件路由按存储桶配置的 cors 生效，其余路由使用 server.cors 配置
 func InitCORS() gin.HandlerFunc {
+	defaultOrigins := config.Config.GetStringSlice("server.cors.allowOrigins")
+	if len(defaultOrigins) == 0 {
+		defaultOrigins = []string{"*"}
+	}
+	defaultHandler := newCORSHandler(defaultOrigins)
+
+	var cfgList []bucketCORSConfig
+	if err := config.Config.UnmarshalKey("bucket", &cfgList); err != nil {
+		zap.L().Error("Parse bucket cors config failed", zap.Error(err))
+	}
+	bucketHandlers := make(map[string]gin.HandlerFunc, len(cfgList))
+	for _, c := range cfgList {
+		if len(c.CORS.AllowOrigins) > 0 {
+			bucketHandlers[c.Name] = newCORSHandler(c.CORS.AllowOrigins)
+		}
+	}
+
+	return func(c *gin.Context) {
+		if handler, ok := bucketHandlers[publicBucketName(c.Request.URL.Path)]; ok {
+			handler(c)
+			return
+		}
+		defaultHandler(c)
+	}
+}
+
+func newCORSHandler(origins []string) gin.HandlerFunc {
 	return cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
+		AllowOrigins:     origins,
+		AllowWildcard:    true,
 		AllowMethods:     []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Key", "Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since", "If-Range"},
-		ExposeHeaders:    []string{"Accept-Ranges", "Content-Range", "ETag"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Key", "Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since", "If-Range", "X-Checksum-SHA256"},
+		ExposeHeaders:    []string{"Accept-Ranges", "Content-Range", "ETag", "Retry-After", "Repr-Digest", "X-Checksum-SHA256"},
 		AllowCredentials: false,
 		MaxAge:           12 * time.Hour,
 	})
 }
 
+// publicBucketName 从公开文件路由中解析存储桶名称
+func publicBucketName(p string) string {
+	for _, prefix := range []string{"/files/", "/thumbnails/"} {
+		if rest, ok := strings.CutPrefix(p, prefix); ok {
+			name, _, _ := strings.Cut(rest, "/")
+			return name
+		}
+	}
+	return ""
+}
+
 // Run 运行 Http 服务器
 func Run(handler http.Handler, addr string) {
 	srv := &http.Server{
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"cube-go/pkg/oss"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// reindex 全量遍历存储桶重建对象索引并输出 JSON 格式的索引状态。
+// 索引数据库同一时间只能由一个进程打开，服务运行时请改用 POST /api/admin/index/rebuild。
+// 不启动后台任务，因此不会与后台的索引建立同时进行。
+// 返回 0 表示全部成功，2 表示失败。
+func reindex(args []string) int {
+	flags := flag.NewFlagSet("reindex", flag.ContinueOnError)
+	bucket := flags.String("bucket", "", "只重建指定存储桶的索引")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// 标准输出只保留 JSON 报告，日志改写到标准错误
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	zap.ReplaceGlobals(zap.New(zapcore.NewCore(zapcore.NewConsoleEncoder(encoderConfig), zapcore.Lock(os.Stderr), zap.InfoLevel)))
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	// 不启动后台任务，避免命令行工具清理回收站、迁移或复制对象
+	if err := oss.Init(ctx, oss.InitOptions{}); err != nil {
+		if errors.Is(err, oss.ErrDatabaseLocked) {
+			_, _ = fmt.Fprintln(os.Stderr, "server is running; use POST /api/admin/index/rebuild")
+		}
+		_, _ = fmt.Fprintln(os.Stderr, "init oss:", err)
+		return 2
+	}
+	defer func() { _ = oss.Close() }()
+
+	names := oss.Buckets.GetManagedBucketList()
+	if *bucket != "" {
+		names = []string{*bucket}
+	}
+	statuses := make(map[string]oss.IndexStatus, len(names))
+	code := 0
+	for _, name := range names {
+		provider, err := oss.Buckets.GetManagedBucket(name)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "reindex:", name+":", err)
+			return 2
+		}
+		indexed, ok := oss.As[*oss.IndexedProvider](provider)
+		if !ok {
+			if *bucket != "" {
+				_, _ = fmt.Fprintln(os.Stderr, "reindex:", name+":", oss.ErrIndexDisabled)
+				return 2
+			}
+			continue
+		}
+		if err := indexed.Rebuild(ctx); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "reindex:", name+":", err)
+			code = 2
+		}
+		status, err := indexed.Status()
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "reindex:", name+":", err)
+			return 2
+		}
+		statuses[name] = status
+	}
+	if len(statuses) == 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "reindex:", oss.ErrIndexDisabled)
+		return 2
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(statuses)
+	return code
+}